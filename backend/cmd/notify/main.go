@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -14,7 +17,9 @@ import (
 	"github.com/jianxcao/notify/backend/pkg/app"
 	"github.com/jianxcao/notify/backend/pkg/config"
 	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/media"
 	"github.com/jianxcao/notify/backend/pkg/server"
+	"github.com/jianxcao/notify/backend/pkg/store"
 )
 
 var (
@@ -54,8 +59,31 @@ func main() {
 	// 初始化日志系统
 	logger.Init()
 
+	// 打开本地数据库（出站队列等持久化数据）
+	dataDir := config.EnvCfg.DataDir(actualConfigFile)
+	st, err := store.Open(filepath.Join(dataDir, "notify.db"))
+	if err != nil {
+		logger.Fatal("打开数据库失败", "error", err)
+	}
+
+	// 打开图片缓存
+	mediaCache, err := media.Open(filepath.Join(dataDir, "media"), media.Options{
+		BaseURL:      config.EnvCfg.EXTERNAL_URL,
+		Secret:       config.EnvCfg.MEDIA_SECRET,
+		TTL:          config.EnvCfg.MEDIA_URL_TTL,
+		MaxSize:      int64(config.EnvCfg.MEDIA_CACHE_MAX_MB) << 20,
+		MaxImageSize: config.EnvCfg.MEDIA_MAX_IMAGE_MB << 20,
+		MaxDimension: config.EnvCfg.MEDIA_MAX_DIMENSION,
+	})
+	if err != nil {
+		logger.Fatal("打开图片缓存失败", "error", err)
+	}
+
 	// 创建通知应用
-	notificationApp := app.NewNotificationApp(configManager)
+	notificationApp, err := app.NewNotificationApp(configManager, st, mediaCache)
+	if err != nil {
+		logger.Fatal("创建通知应用失败", "error", err)
+	}
 
 	// 验证通知应用配置
 	if err := notificationApp.ValidateConfig(); err != nil {
@@ -85,7 +113,7 @@ func main() {
 
 	// 启动服务器
 	go func() {
-		if err := httpServer.Start(); err != nil {
+		if err := httpServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatal("启动HTTP服务器失败", "error", err)
 		}
 	}()
@@ -101,8 +129,15 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// 先停止接收新请求，再等待出站队列中正在进行的投递完成
 	if err := httpServer.Stop(ctx); err != nil {
-		logger.Fatal("强制关闭服务器", "error", err)
+		logger.Error("强制关闭服务器", "error", err)
+	}
+	if err := notificationApp.Close(ctx); err != nil {
+		logger.Warn("等待投递完成超时，未完成的投递将在下次启动时继续", "error", err)
+	}
+	if err := st.Close(); err != nil {
+		logger.Error("关闭数据库失败", "error", err)
 	}
 
 	logger.Info("服务器已关闭")
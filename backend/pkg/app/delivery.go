@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/circuit"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/outbox"
+	"github.com/jianxcao/notify/backend/pkg/ratelimit"
+)
+
+// deliver 出站队列的投递函数：查找通知服务实例并发送
+func (app *NotificationApp) deliver(ctx context.Context, d *outbox.Delivery) error {
+	notifierInstance, exists := app.getNotifier(d.Notifier)
+	if !exists {
+		// 通知服务已被删除或配置无效，重试没有意义
+		return outbox.Permanent(fmt.Errorf("通知服务 %s 不存在", d.Notifier))
+	}
+	if !notifierInstance.IsEnabled() {
+		return outbox.Permanent(fmt.Errorf("通知服务 %s 未启用", d.Notifier))
+	}
+
+	// 先检查熔断，熔断时不占用限速配额
+	breaker := app.breakers.Get(d.Notifier)
+	if ok, wait := breaker.Allow(); !ok {
+		return app.circuitOpen(d, wait)
+	}
+
+	targets := retryTargets(d)
+	limiter := app.limiter(d.Notifier)
+	if limiter != nil {
+		release, err := acquire(ctx, limiter, targets)
+		if err != nil {
+			// 推迟投递，释放半开状态的探测机会
+			breaker.Release()
+			return err
+		}
+		defer release()
+	}
+
+	// 上次的结果用于拆分发送的消息从失败的条目继续，避免重复发送已成功的部分
+	sendCtx := notifier.WithPreviousResults(notifier.WithPayload(ctx, app.payloadLoader(d.MessageID)), d.Results)
+	results := notifierInstance.Send(sendCtx, d.Message, targets)
+	d.Results = mergeResults(d.Results, results)
+	recordHealth(breaker, results)
+
+	err := results.Err()
+	if err == nil {
+		return nil
+	}
+	if !results.Retryable() {
+		// 所有失败都是服务商明确拒绝（目标不存在、参数错误等），重试不会成功
+		return outbox.Permanent(err)
+	}
+	// 服务商限流：暂停该通知服务的发送，并按服务商要求的时间重试
+	if after := results.RetryAfter(); after > 0 {
+		if limiter != nil {
+			limiter.Pause(after)
+		}
+		logger.Warn("通知服务被限流", "notifier", d.Notifier, "retryAfter", after)
+		return outbox.RetryAfter(err, after)
+	}
+	return err
+}
+
+// circuitOpen 通知服务已熔断：有下一组故障转移时按失败处理，立即转移；
+// 否则推迟到冷却结束，投递留在队列中不计入投递次数
+func (app *NotificationApp) circuitOpen(d *outbox.Delivery, wait time.Duration) error {
+	err := fmt.Errorf("通知服务 %s 已熔断，%s 后重新探测", d.Notifier, wait.Round(time.Second))
+	if appConfig, exists := app.configManager.GetConfig().NotificationApps[d.AppID]; exists && d.Escalation == 0 && len(appConfig.Fallback) > d.Stage {
+		return outbox.RetryAfter(err, wait)
+	}
+	d.LastError = err.Error()
+	return outbox.Delay(wait)
+}
+
+// recordHealth 根据发送结果更新通知服务的健康状态：任一目标成功即视为服务正常；
+// 只有可重试的失败（网络错误、服务端错误）计入失败，服务商限流和明确拒绝的目标
+// （目标不存在、参数错误等）说明服务本身可用，不计入
+func recordHealth(breaker *circuit.Breaker, results notifier.Results) {
+	var failures notifier.Results
+	for _, r := range results {
+		if r.Success {
+			breaker.Success()
+			return
+		}
+		if r.Retryable && r.RetryAfterMs == 0 {
+			failures = append(failures, r)
+		}
+	}
+	if err := failures.Err(); err != nil && results.RetryAfter() == 0 {
+		breaker.Failure(err.Error())
+		return
+	}
+	breaker.Release()
+}
+
+// maxInlineWait 限速等待不超过该时间时在当前协程中等待，更长时推迟投递，避免占用发送协程
+const maxInlineWait = time.Second
+
+// limiter 获取通知服务的限速器，配置错误时不限速
+func (app *NotificationApp) limiter(name string) *ratelimit.Limiter {
+	instance, exists := app.configManager.GetConfig().Notifiers[name]
+	if !exists {
+		return nil
+	}
+	limiter, err := app.limiters.Get(name, notifier.RateLimitFor(instance))
+	if err != nil {
+		logger.Warn("限速配置错误，不限速", "notifier", name, "error", err)
+		return nil
+	}
+	return limiter
+}
+
+// acquire 申请发送配额，需要等待较长时间时返回推迟投递的错误，投递留在队列中稍后发送
+func acquire(ctx context.Context, limiter *ratelimit.Limiter, targets []string) (func(), error) {
+	var waited time.Duration
+	for {
+		release, wait := limiter.Acquire(targets)
+		if wait == 0 {
+			return release, nil
+		}
+		if waited+wait > maxInlineWait {
+			return nil, outbox.Delay(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, outbox.Delay(wait)
+		case <-time.After(wait):
+		}
+		waited += wait
+	}
+}
+
+// retryTargets 返回本次需要发送的目标：上次部分目标已成功时只重试失败的目标，避免重复发送
+func retryTargets(d *outbox.Delivery) []string {
+	failed := d.Results.FailedTargets()
+	if len(failed) == 0 || len(failed) == len(d.Results) {
+		return d.Targets
+	}
+	for _, r := range d.Results {
+		if !r.Success && r.Target == "" {
+			// 存在无法定位目标的失败，只能整体重试
+			return d.Targets
+		}
+	}
+	return failed
+}
+
+// mergeResults 用本次结果覆盖之前同一目标的结果，保留之前已成功目标的结果
+func mergeResults(previous, current notifier.Results) notifier.Results {
+	merged := make(notifier.Results, 0, len(previous)+len(current))
+	for _, r := range previous {
+		replaced := false
+		for _, c := range current {
+			if c.Target == r.Target {
+				replaced = true
+				break
+			}
+		}
+		if !replaced && r.Success {
+			merged = append(merged, r)
+		}
+	}
+	return append(merged, current...)
+}
+
+// splitTargets 按目标拆分投递；targets 为空时使用通知服务默认目标，只投递一次
+func splitTargets(n notifier.Notifier, targets []string) [][]string {
+	cleaned := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if target = strings.TrimSpace(target); target != "" {
+			cleaned = append(cleaned, target)
+		}
+	}
+
+	if len(cleaned) == 0 {
+		return [][]string{nil}
+	}
+	if grouper, ok := n.(notifier.TargetGrouper); ok && grouper.GroupTargets() {
+		return [][]string{cleaned}
+	}
+
+	groups := make([][]string, len(cleaned))
+	for i, target := range cleaned {
+		groups[i] = []string{target}
+	}
+	return groups
+}
+
+// NotifierHealth 返回所有启用的通知服务的健康状态
+func (app *NotificationApp) NotifierHealth() map[string]circuit.Health {
+	var names []string
+	for name, instance := range app.configManager.GetConfig().Notifiers {
+		if instance.Enabled {
+			names = append(names, name)
+		}
+	}
+	return app.breakers.Snapshot(names)
+}
+
+// GetOutbox 获取出站队列
+func (app *NotificationApp) GetOutbox() *outbox.Outbox {
+	return app.outbox
+}
+
+// payloadLoader 从消息历史中读取投递对应消息的原始请求数据
+func (app *NotificationApp) payloadLoader(messageID string) func() map[string]any {
+	return func() map[string]any {
+		record, ok, err := app.history.Get(messageID)
+		if err != nil || !ok {
+			if err != nil {
+				logger.Error("读取原始请求数据失败", "messageId", messageID, "error", err)
+			}
+			return nil
+		}
+		return record.Payload
+	}
+}
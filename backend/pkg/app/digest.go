@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/digest"
+	"github.com/jianxcao/notify/backend/pkg/history"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/utils"
+)
+
+// digestMaxWait 解析汇总的最长等待时间
+func digestMaxWait(cfg *config.AppDigest) (time.Duration, error) {
+	maxWait, err := time.ParseDuration(cfg.MaxWait)
+	if err != nil {
+		return 0, fmt.Errorf("最长等待时间 %q 格式错误: %w", cfg.MaxWait, err)
+	}
+	if maxWait <= 0 {
+		return 0, fmt.Errorf("最长等待时间必须大于 0")
+	}
+	if cfg.MaxItems < 0 {
+		return 0, fmt.Errorf("最多条数不能小于 0")
+	}
+	return maxWait, nil
+}
+
+// digestKey 计算分组键，未配置时应用的所有消息为一组
+func (app *NotificationApp) digestKey(appConfig config.NotificationApp, req *map[string]any) string {
+	tmpl := appConfig.Digest.Key
+	if tmpl == "" {
+		return ""
+	}
+	key, err := app.renderTemplate(appConfig.AppID+"_digest_key", tmpl, req)
+	if err != nil {
+		logger.Warn("渲染汇总分组键失败", "app", appConfig.AppID, "error", err)
+	}
+	return strings.TrimSpace(key)
+}
+
+// bufferDigest 把消息加入汇总缓冲，条数达到上限时立即发送
+func (app *NotificationApp) bufferDigest(appConfig config.NotificationApp, record *history.Record, req *map[string]any, maxWait time.Duration) *SendResult {
+	key := app.digestKey(appConfig, req)
+	record.Status = history.MessageBatched
+	record.DigestKey = key
+	app.saveHistory(record)
+
+	item := digest.Item{
+		MessageID: record.ID,
+		Message:   record.Message,
+		Targets:   record.Targets,
+		Payload:   *req,
+		CreatedAt: time.Now(),
+	}
+	if batch := app.digests.Add(appConfig.AppID, key, item, maxWait, appConfig.Digest.MaxItems); batch != nil {
+		app.sendDigest(appConfig, *batch)
+	}
+	return &SendResult{MessageID: record.ID, Batched: true}
+}
+
+// digestData 汇总模板使用的数据
+func digestData(batch digest.Batch) map[string]any {
+	items := make([]any, len(batch.Items))
+	for i, item := range batch.Items {
+		msg := item.Message
+		items[i] = map[string]any{
+			"messageId": item.MessageID,
+			"title":     msg.Title,
+			"content":   msg.Content,
+			"url":       msg.URL,
+			"image":     msg.Image,
+			"level":     string(msg.Level),
+			"timestamp": msg.Timestamp,
+			"targets":   item.Targets,
+			"payload":   item.Payload,
+		}
+	}
+	return map[string]any{
+		"items": items,
+		"count": len(items),
+		"key":   batch.Key,
+	}
+}
+
+// renderDigest 生成汇总消息，只有一条消息时原样发送
+func (app *NotificationApp) renderDigest(appConfig config.NotificationApp, batch digest.Batch, data *map[string]any) (*notifier.NotificationMessage, []string, error) {
+	// 合并各条消息的目标
+	targets := []string{}
+	seen := map[string]bool{}
+	for _, item := range batch.Items {
+		for _, target := range item.Targets {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	if len(batch.Items) == 1 {
+		message := *batch.Items[0].Message
+		return &message, targets, nil
+	}
+
+	var message *notifier.NotificationMessage
+	if templateID := appConfig.Digest.TemplateID; templateID != "" {
+		digestApp := appConfig
+		digestApp.TemplateID = templateID
+		rendered, renderedTargets, err := app.renderWithTemplate(digestApp, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		message = rendered
+		if len(renderedTargets) > 0 {
+			targets = renderedTargets
+		}
+	} else {
+		message = defaultDigestMessage(batch)
+	}
+
+	// 未指定级别时使用各条消息中最高的级别
+	if message.Level == "" {
+		for _, item := range batch.Items {
+			if item.Message.Level.Rank() > message.Level.Rank() {
+				message.Level = item.Message.Level
+			}
+		}
+	}
+	return message, targets, nil
+}
+
+// defaultDigestMessage 未配置汇总模板时列出各条消息的标题
+func defaultDigestMessage(batch digest.Batch) *notifier.NotificationMessage {
+	items := batch.Items
+	first := items[0].Message
+
+	sameTitle := true
+	for _, item := range items[1:] {
+		if item.Message.Title != first.Title {
+			sameTitle = false
+			break
+		}
+	}
+
+	message := &notifier.NotificationMessage{
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Format:    notifier.FormatPlain,
+	}
+	if sameTitle {
+		message.Title = fmt.Sprintf("%s（%d 条）", first.Title, len(items))
+	} else {
+		message.Title = fmt.Sprintf("%s 等 %d 条消息", first.Title, len(items))
+	}
+
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line := item.Message.Title
+		if sameTitle || line == "" {
+			// 标题相同时用内容的第一行区分
+			line, _, _ = strings.Cut(strings.TrimSpace(item.Message.Content), "\n")
+		}
+		lines = append(lines, "• "+line)
+		if message.Image == "" {
+			message.Image = item.Message.Image
+		}
+	}
+	message.Content = strings.Join(lines, "\n")
+	return message
+}
+
+// sendDigest 合并分组中的消息并写入发送队列
+func (app *NotificationApp) sendDigest(appConfig config.NotificationApp, batch digest.Batch) {
+	data := digestData(batch)
+	record := &history.Record{
+		ID:         utils.NewID(),
+		AppID:      appConfig.AppID,
+		AppName:    appConfig.Name,
+		Payload:    data,
+		TemplateID: appConfig.Digest.TemplateID,
+		DigestKey:  batch.Key,
+	}
+	for _, item := range batch.Items {
+		record.Items = append(record.Items, item.MessageID)
+	}
+
+	message, targets, err := app.renderDigest(appConfig, batch, &data)
+	if err != nil {
+		record.Status = history.MessageError
+		record.Error = fmt.Sprintf("生成汇总消息失败: %v", err)
+		app.saveHistory(record)
+		logger.Error("生成汇总消息失败", "app", appConfig.AppID, "key", batch.Key, "error", err)
+		return
+	}
+	record.Message = message
+	record.Targets = targets
+
+	if _, err := app.dispatch(context.Background(), appConfig, record, &data, SendOptions{Async: true}); err != nil {
+		logger.Error("发送汇总消息失败", "app", appConfig.AppID, "key", batch.Key, "error", err)
+	}
+}
+
+// digestLoop 定期发送等待超时的汇总消息
+func (app *NotificationApp) digestLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.done:
+			return
+		case <-ticker.C:
+			app.flushDigests(false)
+		}
+	}
+}
+
+// flushDigests 发送等待超时的汇总消息，all 为 true 时发送全部缓冲的消息
+func (app *NotificationApp) flushDigests(all bool) {
+	for _, batch := range app.digests.Due(all) {
+		appConfig, exists := app.configManager.GetConfig().NotificationApps[batch.AppID]
+		if !exists {
+			logger.Warn("通知应用不存在，丢弃缓冲的消息", "app", batch.AppID, "count", len(batch.Items))
+			continue
+		}
+		if appConfig.Digest == nil {
+			// 缓冲后关闭了汇总，仍然合并发送已缓冲的消息
+			appConfig.Digest = &config.AppDigest{}
+		}
+		app.sendDigest(appConfig, batch)
+	}
+}
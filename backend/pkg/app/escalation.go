@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/history"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/outbox"
+)
+
+var (
+	// ErrMessageNotFound 消息不存在
+	ErrMessageNotFound = errors.New("消息不存在")
+	// ErrInvalidAckToken 确认链接的令牌错误
+	ErrInvalidAckToken = errors.New("确认链接无效")
+)
+
+// escalationStep 待发送的升级通知
+type escalationStep struct {
+	dispatch dispatch
+	at       time.Time
+}
+
+// hasFallback 应用是否配置了故障转移
+func (app *NotificationApp) hasFallback(appID string) bool {
+	appConfig, exists := app.configManager.GetConfig().NotificationApps[appID]
+	return exists && len(appConfig.Fallback) > 0
+}
+
+// advanceFallback 故障转移：当前阶段的投递首次尝试全部失败时发送到下一组通知服务，
+// 并取消当前阶段等待重试的投递，避免与下一组重复发送
+func (app *NotificationApp) advanceFallback(messageID string) {
+	app.fallbackMu.Lock()
+	defer app.fallbackMu.Unlock()
+
+	record, exists, err := app.history.Get(messageID)
+	if err != nil || !exists || record.Message == nil {
+		return
+	}
+	appConfig, exists := app.configManager.GetConfig().NotificationApps[record.AppID]
+	if !exists || len(appConfig.Fallback) == 0 {
+		return
+	}
+
+	for {
+		stage := 0
+		for _, ds := range record.Deliveries {
+			if ds.Escalation == 0 && ds.Stage > stage {
+				stage = ds.Stage
+			}
+		}
+		if stage >= len(appConfig.Fallback) {
+			return
+		}
+
+		var retrying []string
+		for _, ds := range record.Deliveries {
+			if ds.Escalation != 0 || ds.Stage != stage {
+				continue
+			}
+			switch ds.Status {
+			case history.DeliveryFailed:
+			case history.DeliveryRetrying:
+				retrying = append(retrying, ds.ID)
+			default:
+				// 仍在发送、已成功或被免打扰丢弃，不转移
+				return
+			}
+		}
+
+		if len(retrying) > 0 {
+			if _, err := app.outbox.Cancel(retrying...); err != nil {
+				logger.Warn("取消等待重试的投递失败", "messageId", messageID, "error", err)
+			}
+		}
+
+		next := dispatch{
+			route:     fmt.Sprintf("fallback-%d", stage+1),
+			notifiers: appConfig.Fallback[stage],
+			targets:   record.Targets,
+			message:   record.Message,
+		}
+		deliveries, statuses, _ := app.newDeliveries(context.Background(), record, []dispatch{next}, time.Time{}, stage+1, 0)
+		logger.Warn("通知服务发送失败，转移到下一组", "messageId", messageID, "stage", stage+1, "notifiers", next.notifiers)
+		if err := app.history.AppendDeliveries(messageID, statuses); err != nil {
+			logger.Error("更新消息历史失败", "messageId", messageID, "error", err)
+			return
+		}
+		record.Deliveries = append(record.Deliveries, statuses...)
+		if len(deliveries) > 0 {
+			if err := app.outbox.Enqueue(deliveries...); err != nil {
+				logger.Error("写入发送队列失败", "messageId", messageID, "error", err)
+			}
+			return
+		}
+		// 下一组全部无法发送（通知服务不存在等），继续转移
+	}
+}
+
+// planEscalation 为配置了升级的应用生成确认令牌和确认按钮，返回各升级步骤的发送时间
+func (app *NotificationApp) planEscalation(appConfig config.NotificationApp, record *history.Record, dispatches []dispatch, sendAt time.Time) []escalationStep {
+	if len(appConfig.Escalation) == 0 {
+		return nil
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		logger.Error("生成确认令牌失败", "error", err)
+		return nil
+	}
+	record.AckToken = hex.EncodeToString(token)
+
+	// 配置了外部访问地址时在消息中添加确认按钮
+	if base := strings.TrimSuffix(config.EnvCfg.EXTERNAL_URL, "/"); base != "" {
+		ack := notifier.Action{Label: "确认", URL: fmt.Sprintf("%s/api/v1/ack/%s?token=%s", base, record.ID, record.AckToken)}
+		record.Message.Actions = append(record.Message.Actions, ack)
+		seen := map[*notifier.NotificationMessage]bool{record.Message: true}
+		for _, d := range dispatches {
+			if !seen[d.message] {
+				seen[d.message] = true
+				d.message.Actions = append(d.message.Actions, ack)
+			}
+		}
+	}
+
+	if sendAt.IsZero() {
+		sendAt = time.Now()
+	}
+	steps := make([]escalationStep, 0, len(appConfig.Escalation))
+	for i, step := range appConfig.Escalation {
+		after, err := escalationDelay(step)
+		if err != nil {
+			logger.Warn("升级配置错误，忽略该步骤", "app", appConfig.AppID, "step", i+1, "error", err)
+			continue
+		}
+		message := *record.Message
+		message.Title = "【未确认】" + message.Title
+		message.Actions = append([]notifier.Action(nil), record.Message.Actions...)
+		steps = append(steps, escalationStep{
+			dispatch: dispatch{
+				route:     fmt.Sprintf("escalation-%d", i+1),
+				notifiers: step.Notifiers,
+				targets:   record.Targets,
+				message:   &message,
+			},
+			at: sendAt.Add(after),
+		})
+	}
+	return steps
+}
+
+// escalationDelay 解析升级步骤的等待时间
+func escalationDelay(step config.EscalationStep) (time.Duration, error) {
+	after, err := time.ParseDuration(step.After)
+	if err != nil {
+		return 0, fmt.Errorf("等待时间 %q 格式错误: %w", step.After, err)
+	}
+	if after <= 0 {
+		return 0, fmt.Errorf("等待时间必须大于 0")
+	}
+	return after, nil
+}
+
+// validateEscalation 校验故障转移和升级配置
+func validateEscalation(cfg *config.Config, name string, appConfig config.NotificationApp) error {
+	for i, group := range appConfig.Fallback {
+		if len(group) == 0 {
+			return fmt.Errorf("通知应用 %s 的第 %d 组故障转移未配置通知服务", name, i+1)
+		}
+		for _, notifierName := range group {
+			if _, exists := cfg.Notifiers[notifierName]; !exists {
+				return fmt.Errorf("通知应用 %s 的故障转移引用了不存在的通知服务实例: %s", name, notifierName)
+			}
+		}
+	}
+	for i, step := range appConfig.Escalation {
+		if _, err := escalationDelay(step); err != nil {
+			return fmt.Errorf("通知应用 %s 的第 %d 步升级配置错误: %w", name, i+1, err)
+		}
+		if len(step.Notifiers) == 0 {
+			return fmt.Errorf("通知应用 %s 的第 %d 步升级未配置通知服务", name, i+1)
+		}
+		for _, notifierName := range step.Notifiers {
+			if _, exists := cfg.Notifiers[notifierName]; !exists {
+				return fmt.Errorf("通知应用 %s 的升级引用了不存在的通知服务实例: %s", name, notifierName)
+			}
+		}
+	}
+	return nil
+}
+
+// Acknowledge 确认消息并取消尚未发送和等待重试的升级通知
+func (app *NotificationApp) Acknowledge(messageID, by string) (*history.Record, error) {
+	record, exists, err := app.history.Ack(messageID, by)
+	if err != nil {
+		return nil, fmt.Errorf("确认消息失败: %w", err)
+	}
+	if !exists {
+		return nil, ErrMessageNotFound
+	}
+
+	var pending []string
+	for _, ds := range record.Deliveries {
+		if ds.Escalation == 0 || ds.ID == "" {
+			continue
+		}
+		switch ds.Status {
+		case history.DeliveryScheduled, history.DeliveryQueued, history.DeliveryRetrying:
+			pending = append(pending, ds.ID)
+		}
+	}
+	if len(pending) > 0 {
+		cancelled, err := app.outbox.Cancel(pending...)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("消息已确认，取消升级通知", "messageId", messageID, "by", by, "cancelled", len(cancelled))
+	}
+
+	if fresh, ok, err := app.history.Get(messageID); err == nil && ok {
+		record = fresh
+	}
+	return record, nil
+}
+
+// AcknowledgeWithToken 通过消息中的确认链接确认消息
+func (app *NotificationApp) AcknowledgeWithToken(messageID, token, by string) (*history.Record, error) {
+	record, exists, err := app.history.Get(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("读取消息历史失败: %w", err)
+	}
+	if !exists {
+		return nil, ErrMessageNotFound
+	}
+	if record.AckToken == "" || subtle.ConstantTimeCompare([]byte(record.AckToken), []byte(token)) != 1 {
+		return nil, ErrInvalidAckToken
+	}
+	return app.Acknowledge(messageID, by)
+}
+
+// isFailedAttempt 投递的首次或后续尝试失败（进入死信或等待重试）
+func isFailedAttempt(d outbox.Delivery) bool {
+	return d.State == outbox.StateDead || (d.State == outbox.StatePending && d.Attempts > 0)
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/expr"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+)
+
+// filterRequest 按应用的过滤规则检查原始请求数据，返回丢弃原因，为空表示保留
+//
+// 先检查 deny，匹配任一表达式即丢弃；配置了 allow 时还需要匹配其中之一。
+// 表达式执行出错时视为不匹配并记录日志。
+func filterRequest(appConfig config.NotificationApp, req map[string]any) string {
+	filter := appConfig.Filter
+	if filter == nil {
+		return ""
+	}
+
+	env := make(map[string]any, len(req)+1)
+	for k, v := range req {
+		env[k] = v
+	}
+	env["payload"] = req
+
+	match := func(src string) bool {
+		if strings.TrimSpace(src) == "" {
+			return false
+		}
+		matched, err := expr.Match(src, env)
+		if err != nil {
+			logger.Warn("过滤规则匹配失败", "app", appConfig.AppID, "expr", src, "error", err)
+			return false
+		}
+		return matched
+	}
+
+	for _, src := range filter.Deny {
+		if match(src) {
+			return "deny: " + src
+		}
+	}
+	if len(filter.Allow) == 0 {
+		return ""
+	}
+	for _, src := range filter.Allow {
+		if match(src) {
+			return ""
+		}
+	}
+	return "allow: 未匹配任何规则"
+}
+
+// validateFilter 校验过滤规则的表达式
+func validateFilter(name string, appConfig config.NotificationApp) error {
+	if appConfig.Filter == nil {
+		return nil
+	}
+	for _, list := range [][]string{appConfig.Filter.Allow, appConfig.Filter.Deny} {
+		for _, src := range list {
+			if strings.TrimSpace(src) == "" {
+				continue
+			}
+			if _, err := expr.Compile(src); err != nil {
+				return fmt.Errorf("通知应用 %s 的过滤规则 %q 配置错误: %w", name, src, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/history"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/outbox"
+	"github.com/jianxcao/notify/backend/pkg/utils"
+)
+
+// GetHistory 获取消息历史
+func (app *NotificationApp) GetHistory() *history.History {
+	return app.history
+}
+
+// saveHistory 保存消息历史，失败时只记录日志，不影响发送
+func (app *NotificationApp) saveHistory(record *history.Record) {
+	if err := app.history.Save(record); err != nil {
+		logger.Error("保存消息历史失败", "messageId", record.ID, "error", err)
+	}
+}
+
+// onDeliveryUpdate 出站队列投递状态变化时同步到消息历史
+func (app *NotificationApp) onDeliveryUpdate(d outbox.Delivery) {
+	if err := app.history.UpdateDelivery(d); err != nil {
+		logger.Error("更新消息历史失败", "messageId", d.MessageID, "deliveryId", d.ID, "error", err)
+		return
+	}
+	// 故障转移：升级通知不参与
+	if d.Escalation == 0 && isFailedAttempt(d) && app.hasFallback(d.AppID) {
+		app.advanceFallback(d.MessageID)
+	}
+}
+
+// Resend 重新发送历史消息，notifiers 为空时使用原消息的通知服务
+func (app *NotificationApp) Resend(ctx context.Context, messageID string, notifiers []string, opts SendOptions) (*SendResult, error) {
+	original, exists, err := app.history.Get(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("读取消息历史失败: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("消息 %s 不存在", messageID)
+	}
+	if original.Message == nil {
+		return nil, fmt.Errorf("消息 %s 没有可发送的内容", messageID)
+	}
+
+	if len(notifiers) == 0 {
+		notifiers = original.Notifiers
+	}
+
+	message := *original.Message
+	message.Timestamp = time.Now().Format("2006-01-02 15:04:05")
+
+	record := &history.Record{
+		ID:         utils.NewID(),
+		AppID:      original.AppID,
+		AppName:    original.AppName,
+		Payload:    original.Payload,
+		PluginID:   original.PluginID,
+		TemplateID: original.TemplateID,
+		Message:    &message,
+		Targets:    original.Targets,
+		Notifiers:  notifiers,
+		ResendOf:   original.ID,
+	}
+	// 重发不再匹配路由规则，使用原消息和目标发送到指定的通知服务
+	return app.sendToNotifiers(ctx, record, []dispatch{{notifiers: notifiers, targets: original.Targets, message: &message}}, opts)
+}
+
+// pruneHistoryLoop 启动时及之后每小时按保留策略清理消息历史
+func (app *NotificationApp) pruneHistoryLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		app.pruneHistory()
+		select {
+		case <-app.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneHistory 清理过期的消息历史及对应的投递记录
+func (app *NotificationApp) pruneHistory() {
+	ids, err := app.history.Prune(config.EnvCfg.HISTORY_MAX_AGE, config.EnvCfg.HISTORY_MAX_COUNT)
+	if err != nil {
+		logger.Error("清理消息历史失败", "error", err)
+		return
+	}
+	for _, id := range ids {
+		if err := app.outbox.DeleteByMessage(id); err != nil {
+			logger.Error("清理投递记录失败", "messageId", id, "error", err)
+		}
+	}
+	if len(ids) > 0 {
+		logger.Info("已清理过期消息历史", "count", len(ids))
+	}
+}
@@ -3,127 +3,90 @@ package app
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 
+	"github.com/jianxcao/notify/backend/pkg/circuit"
 	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/digest"
+	"github.com/jianxcao/notify/backend/pkg/history"
 	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/media"
 	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/outbox"
 	"github.com/jianxcao/notify/backend/pkg/pluginmgr"
+	"github.com/jianxcao/notify/backend/pkg/ratelimit"
+	"github.com/jianxcao/notify/backend/pkg/store"
+	"github.com/jianxcao/notify/backend/pkg/throttle"
+	"github.com/jianxcao/notify/backend/pkg/tmplfunc"
+	"github.com/jianxcao/notify/backend/pkg/utils"
 )
 
-var funcMap = template.FuncMap{
-	"strContains":   strings.Contains,
-	"hasSuffix":     strings.HasSuffix,
-	"hasPrefix":     strings.HasPrefix,
-	"strHasSuffix":  strings.HasSuffix,
-	"strHasPrefix":  strings.HasPrefix,
-	"strIndex":      strings.Index,
-	"strLastIndex":  strings.LastIndex,
-	"strReplace":    strings.Replace,
-	"strReplaceAll": strings.ReplaceAll,
-	"strSplit":      strings.Split,
-	"strJoin":       strings.Join,
-	// 时间格式化函数
-	"formatTime": func(timeStr string, layout string) string {
-		if timeStr == "" {
-			return ""
-		}
-		// 解析RFC3339格式的时间字符串
-		t, err := time.Parse(time.RFC3339, timeStr)
-		if err != nil {
-			// 如果解析失败，尝试解析带纳秒的格式
-			t, err = time.Parse(time.RFC3339Nano, timeStr)
-			if err != nil {
-				return timeStr // 如果都解析失败，返回原字符串
-			}
-		}
-		// 转换为本地时间
-		localTime := t.Local()
-		return localTime.Format(layout)
-	},
-	"formatTimeUTC": func(timeStr string, layout string) string {
-		if timeStr == "" {
-			return ""
-		}
-		t, err := time.Parse(time.RFC3339, timeStr)
-		if err != nil {
-			t, err = time.Parse(time.RFC3339Nano, timeStr)
-			if err != nil {
-				return timeStr
-			}
-		}
-		return t.Format(layout)
-	},
-	// 数学运算函数
-	"mul": func(a, b interface{}) float64 {
-		var x, y float64
-		switch v := a.(type) {
-		case int:
-			x = float64(v)
-		case int64:
-			x = float64(v)
-		case float64:
-			x = v
-		case float32:
-			x = float64(v)
-		}
-		switch v := b.(type) {
-		case int:
-			y = float64(v)
-		case int64:
-			y = float64(v)
-		case float64:
-			y = v
-		case float32:
-			y = float64(v)
-		}
-		return x * y
-	},
-	"div": func(a, b interface{}) float64 {
-		var x, y float64
-		switch v := a.(type) {
-		case int:
-			x = float64(v)
-		case int64:
-			x = float64(v)
-		case float64:
-			x = v
-		case float32:
-			x = float64(v)
-		}
-		switch v := b.(type) {
-		case int:
-			y = float64(v)
-		case int64:
-			y = float64(v)
-		case float64:
-			y = v
-		case float32:
-			y = float64(v)
-		}
-		if y == 0 {
-			return 0
-		}
-		return x / y
-	},
+// 发送失败的分类，使用 errors.Is 判断，其余错误为内部错误
+var (
+	// ErrAppNotFound 通知应用不存在
+	ErrAppNotFound = errors.New("通知应用不存在")
+	// ErrAppDisabled 通知应用未启用
+	ErrAppDisabled = errors.New("通知应用未启用")
+	// ErrBadRequest 请求数据无法处理，例如模板执行失败
+	ErrBadRequest = errors.New("请求数据错误")
+	// ErrAppConfig 应用配置错误，例如模板不存在、插件未启用或未配置通知服务
+	ErrAppConfig = errors.New("应用配置错误")
+)
+
+// kindError 带有分类的错误，错误信息不变
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// withKind 为错误标记分类
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
 }
 
 // NotificationApp 通知应用
 type NotificationApp struct {
 	configManager *config.ConfigManager
-	notifiers     map[string]notifier.Notifier
 	pluginManager *pluginmgr.Manager
+	outbox        *outbox.Outbox
+	history       *history.History
+	media         *media.Cache
+	throttler     *throttle.Throttler
+	digests       *digest.Buffer
+	limiters      *ratelimit.Registry
+	breakers      *circuit.Registry
+	done          chan struct{} // 关闭时通知后台任务退出
+	fallbackMu    sync.Mutex    // 串行执行故障转移，避免同一阶段重复转移
+
+	mu        sync.RWMutex // 保护 notifiers，管理接口修改配置时会整体替换
+	notifiers map[string]notifier.Notifier
 }
 
 // NewNotificationApp 创建通知应用实例
-func NewNotificationApp(configManager *config.ConfigManager) *NotificationApp {
+func NewNotificationApp(configManager *config.ConfigManager, st *store.Store, mediaCache *media.Cache) (*NotificationApp, error) {
 	app := &NotificationApp{
 		configManager: configManager,
 		notifiers:     make(map[string]notifier.Notifier),
+		history:       history.New(st),
+		media:         mediaCache,
+		throttler:     throttle.New(st),
+		digests:       digest.New(st),
+		limiters:      ratelimit.NewRegistry(),
+		breakers: circuit.NewRegistry(circuit.Options{
+			Threshold:   config.EnvCfg.CIRCUIT_FAILURE_THRESHOLD,
+			Cooldown:    config.EnvCfg.CIRCUIT_COOLDOWN,
+			MaxCooldown: config.EnvCfg.CIRCUIT_MAX_COOLDOWN,
+		}),
+		done: make(chan struct{}),
 	}
 
 	// 初始化通知服务
@@ -132,227 +95,261 @@ func NewNotificationApp(configManager *config.ConfigManager) *NotificationApp {
 	// 初始化插件管理器
 	app.InitPlugins()
 
-	return app
+	// 初始化出站队列，恢复上次未完成的投递
+	app.outbox = outbox.New(st, app.deliver, outbox.Options{
+		Workers:     config.EnvCfg.OUTBOX_WORKERS,
+		MaxAttempts: config.EnvCfg.OUTBOX_MAX_ATTEMPTS,
+		OnUpdate:    app.onDeliveryUpdate,
+	})
+	if err := app.outbox.Start(); err != nil {
+		return nil, err
+	}
+
+	// 定期按保留策略清理消息历史
+	go app.pruneHistoryLoop()
+
+	// 定期发送去重窗口结束后的汇总消息
+	go app.throttleLoop()
+
+	// 定期发送等待超时的汇总消息
+	go app.digestLoop()
+
+	return app, nil
 }
 
-// initNotifiers 初始化通知服务
+// Close 停止接收新的通知，发送缓冲的汇总消息并等待正在进行的投递完成
+func (app *NotificationApp) Close(ctx context.Context) error {
+	close(app.done)
+	app.flushDigests(true)
+	return app.outbox.Stop(ctx)
+}
+
+// InitNotifiers 根据配置（重新）创建所有启用的通知服务实例
 func (app *NotificationApp) InitNotifiers() {
-	// 遍历所有通知服务实例
 	cfg := app.configManager.GetConfig()
+	notifiers := make(map[string]notifier.Notifier)
+	versions := make(map[string]string)
 	for instanceName, instance := range cfg.Notifiers {
 		if !instance.Enabled {
 			continue
 		}
+		// 配置变化后重置熔断状态
+		version, _ := json.Marshal(instance)
+		versions[instanceName] = string(version)
 
-		switch instance.Type {
-		case config.WechatWorkAPPBot:
-			// 将map[string]interface{}转换为WechatWorkConfig
-			if config, err := app.parseWechatWorkConfig(instance.Config); err == nil {
-				config.Enabled = instance.Enabled
-				wechatNotifier := notifier.NewWechatWorkNotifier(config)
-				app.notifiers[instanceName] = wechatNotifier
-			}
-		case config.WechatWorkWebhookBot:
-			// 将map[string]interface{}转换为WechatWorkWebhookConfig
-			if config, err := app.parseWechatWorkWebhookConfig(instance.Config); err == nil {
-				config.Enabled = instance.Enabled
-				wechatWebhookNotifier := notifier.NewWechatWorkWebhookNotifier(config)
-				app.notifiers[instanceName] = wechatWebhookNotifier
-			}
-		case config.TelegramAppBot:
-			// 将map[string]interface{}转换为TelegramConfig
-			if config, err := app.parseTelegramConfig(instance.Config); err == nil {
-				config.Enabled = instance.Enabled
-				telegramNotifier := notifier.NewTelegramNotifier(config)
-				app.notifiers[instanceName] = telegramNotifier
-			}
-		case config.DingTalkAppBot:
-			// 将map[string]interface{}转换为DingTalkConfig
-			if config, err := app.parseDingTalkConfig(instance.Config); err == nil {
-				config.Enabled = instance.Enabled
-				dingtalkNotifier := notifier.NewDingTalkNotifier(config)
-				app.notifiers[instanceName] = dingtalkNotifier
-			}
-		case config.FeishuAppBot:
-			// 将map[string]interface{}转换为FeishuConfig
-			if config, err := app.parseFeishuConfig(instance.Config); err == nil {
-				config.Enabled = instance.Enabled
-				feishuNotifier := notifier.NewFeishuNotifier(config)
-				app.notifiers[instanceName] = feishuNotifier
-			}
+		n, err := notifier.New(instance)
+		if err != nil {
+			logger.Warn("创建通知服务实例失败", "instance", instanceName, "type", instance.Type, "error", err)
+			continue
 		}
-	}
-	logger.Debug("notifiers", cfg.Notifiers)
-}
-
-// parseWechatWorkConfig 解析企业微信配置
-func (app *NotificationApp) parseWechatWorkConfig(configData map[string]interface{}) (config.WechatWorkConfig, error) {
-	cfg := config.WechatWorkConfig{}
-
-	if corpID, ok := configData["corp_id"].(string); ok {
-		cfg.CorpID = corpID
-	}
-	if agentID, ok := configData["agent_id"].(string); ok {
-		cfg.AgentID = agentID
-	}
-	if secret, ok := configData["secret"].(string); ok {
-		cfg.Secret = secret
-	}
-	if proxy, ok := configData["proxy"].(string); ok {
-		cfg.Proxy = proxy
-	}
-	if targets, ok := configData["targets"].(string); ok {
-		cfg.Targets = targets
+		notifiers[instanceName] = n
 	}
 
-	if cfg.CorpID == "" || cfg.AgentID == "" || cfg.Secret == "" {
-		return cfg, fmt.Errorf("企业微信配置不完整")
-	}
+	app.mu.Lock()
+	app.notifiers = notifiers
+	app.mu.Unlock()
+	app.breakers.Sync(versions)
+	logger.Debug("notifiers", "instances", len(notifiers))
+}
 
-	return cfg, nil
+// Media 返回图片缓存
+func (app *NotificationApp) Media() *media.Cache {
+	return app.media
 }
 
-// parseTelegramConfig 解析Telegram配置
-func (app *NotificationApp) parseTelegramConfig(configData map[string]interface{}) (config.TelegramConfig, error) {
-	cfg := config.TelegramConfig{}
+// getNotifier 获取通知服务实例
+func (app *NotificationApp) getNotifier(name string) (notifier.Notifier, bool) {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	n, exists := app.notifiers[name]
+	return n, exists
+}
 
-	if botToken, ok := configData["bot_token"].(string); ok {
-		cfg.BotToken = botToken
-	}
-	if chatID, ok := configData["chat_id"].(string); ok {
-		cfg.ChatID = chatID
-	}
-	if proxy, ok := configData["proxy"].(string); ok {
-		cfg.Proxy = proxy
-	}
+// InitPlugins 初始化插件系统
+func (app *NotificationApp) InitPlugins() {
+	// 创建插件管理器，插件目录为 plugins（相对于运行目录）
+	app.pluginManager = pluginmgr.NewManager(config.EnvCfg.PLUGINS_DIR)
 
-	if cfg.BotToken == "" {
-		return cfg, fmt.Errorf("Telegram配置不完整")
+	// 加载所有插件
+	if err := app.pluginManager.LoadAll(); err != nil {
+		logger.Error("加载插件失败: %v", err)
+		return
 	}
+}
 
-	return cfg, nil
+// SendOptions 发送选项
+type SendOptions struct {
+	// Async 只把投递写入发送队列后立即返回，不等待发送结果
+	Async bool
+	// SendAt 定时发送时间，为空表示立即发送
+	SendAt time.Time
+	// repeated 发送去重窗口的汇总消息，不再检查限流，值为被抑制的条数
+	repeated int
+	// escalation 未确认时按时间发送的升级通知
+	escalation []escalationStep
 }
 
-// parseWechatWorkWebhookConfig 解析企业微信群机器人配置
-func (app *NotificationApp) parseWechatWorkWebhookConfig(configData map[string]interface{}) (config.WechatWorkWebhookConfig, error) {
-	cfg := config.WechatWorkWebhookConfig{}
+// SendResult 发送结果
+type SendResult struct {
+	// MessageID 消息ID，可用于查询投递状态；消息无需发送时为空
+	MessageID string `json:"messageId,omitempty"`
+	// Skipped 插件判定该请求不需要通知
+	Skipped bool `json:"skipped,omitempty"`
+	// Filtered 被应用的过滤规则丢弃，Filter 为命中的规则
+	Filtered bool   `json:"filtered,omitempty"`
+	Filter   string `json:"filter,omitempty"`
+	// Throttled 被去重或限流抑制，Repeated 为当前窗口累计抑制的条数
+	Throttled bool `json:"throttled,omitempty"`
+	Repeated  int  `json:"repeated,omitempty"`
+	// Batched 已加入汇总缓冲，稍后与其它消息合并发送
+	Batched bool `json:"batched,omitempty"`
+	// Dropped 处于应用的免打扰时段，已丢弃
+	Dropped bool `json:"dropped,omitempty"`
+	// Status 消息整体状态，同步模式下为首次尝试后的结果
+	Status string `json:"status,omitempty"`
+	// Deliveries 各通知服务的投递状态及每个目标的发送结果
+	Deliveries []history.DeliveryStatus `json:"deliveries,omitempty"`
+}
 
-	if key, ok := configData["key"].(string); ok {
-		cfg.Key = key
-	}
-	if proxy, ok := configData["proxy"].(string); ok {
-		cfg.Proxy = proxy
+// Send 发送通知
+func (app *NotificationApp) Send(ctx context.Context, appConfig config.NotificationApp, req *map[string]any, opts SendOptions) (*SendResult, error) {
+	// 获取通知应用配置
+	appConfig, exists := app.configManager.GetConfig().NotificationApps[appConfig.AppID]
+	if !exists {
+		return nil, withKind(ErrAppNotFound, fmt.Errorf("通知应用 %s 不存在", appConfig.Name))
 	}
 
-	if cfg.Key == "" {
-		return cfg, fmt.Errorf("企业微信群机器人配置不完整：缺少 key")
+	if !appConfig.Enabled {
+		return nil, withKind(ErrAppDisabled, fmt.Errorf("通知应用 %s 未启用", appConfig.Name))
 	}
 
-	return cfg, nil
-}
-
-// parseDingTalkConfig 解析钉钉配置
-func (app *NotificationApp) parseDingTalkConfig(configData map[string]interface{}) (config.DingTalkConfig, error) {
-	cfg := config.DingTalkConfig{}
-
-	if accessToken, ok := configData["access_token"].(string); ok {
-		cfg.AccessToken = accessToken
+	// 记录本次请求，无论成功与否都写入消息历史
+	record := &history.Record{
+		ID:         utils.NewID(),
+		AppID:      appConfig.AppID,
+		AppName:    appConfig.Name,
+		Payload:    *req,
+		PluginID:   appConfig.PluginID,
+		TemplateID: appConfig.TemplateID,
+		Notifiers:  appConfig.Notifiers,
 	}
-	if secret, ok := configData["secret"].(string); ok {
-		cfg.Secret = secret
-	}
-	if proxy, ok := configData["proxy"].(string); ok {
-		cfg.Proxy = proxy
-	}
-	if targets, ok := configData["targets"].(string); ok {
-		cfg.Targets = targets
+	if record.PluginID != "" {
+		record.TemplateID = ""
 	}
 
-	if cfg.AccessToken == "" {
-		return cfg, fmt.Errorf("钉钉配置不完整")
-	}
-
-	return cfg, nil
-}
-
-// parseFeishuConfig 解析飞书配置
-func (app *NotificationApp) parseFeishuConfig(configData map[string]interface{}) (config.FeishuConfig, error) {
-	cfg := config.FeishuConfig{}
-
-	if appID, ok := configData["app_id"].(string); ok {
-		cfg.AppID = appID
-	}
-	if appSecret, ok := configData["app_secret"].(string); ok {
-		cfg.AppSecret = appSecret
+	// 过滤规则在插件和模板处理之前执行
+	if reason := filterRequest(appConfig, *req); reason != "" {
+		record.Status = history.MessageFiltered
+		record.Filter = reason
+		app.saveHistory(record)
+		return &SendResult{MessageID: record.ID, Filtered: true, Filter: reason}, nil
 	}
 
-	if proxy, ok := configData["proxy"].(string); ok {
-		cfg.Proxy = proxy
+	message, targets, err := app.render(ctx, appConfig, req)
+	if err != nil {
+		record.Status = history.MessageError
+		record.Error = err.Error()
+		app.saveHistory(record)
+		return nil, err
+	}
+	if message == nil {
+		record.Status = history.MessageSkipped
+		app.saveHistory(record)
+		return &SendResult{MessageID: record.ID, Skipped: true}, nil
+	}
+	record.Message = message
+	record.Targets = targets
+
+	// 去重与限流：被抑制的消息只记录历史，计数在下一条消息或汇总消息中报告
+	repeated := opts.repeated
+	if appConfig.Throttle != nil && opts.repeated == 0 {
+		if rule, err := throttleRule(appConfig.Throttle); err != nil {
+			logger.Warn("去重配置错误，忽略限流", "app", appConfig.AppID, "error", err)
+		} else {
+			record.DedupKey = app.throttleKey(appConfig, req, message)
+			decision := app.throttler.Allow(appConfig.AppID, record.DedupKey, rule, *req)
+			if !decision.Allowed {
+				record.Status = history.MessageThrottled
+				app.saveHistory(record)
+				return &SendResult{MessageID: record.ID, Throttled: true, Repeated: decision.Repeated}, nil
+			}
+			repeated = decision.Repeated
+		}
 	}
-	if targets, ok := configData["targets"].(string); ok {
-		cfg.Targets = targets
+	if repeated > 0 {
+		noteRepeated(message, repeated)
+		record.Repeated = repeated
 	}
 
-	// 需要配置应用ID和密钥
-	if cfg.AppID == "" || cfg.AppSecret == "" {
-		return cfg, fmt.Errorf("飞书配置不完整：需要配置AppID和AppSecret")
+	// 汇总发送：消息先进入缓冲，超时或条数达到上限时合并发送；指定了发送时间的消息单独发送
+	if appConfig.Digest != nil && opts.SendAt.IsZero() {
+		if maxWait, err := digestMaxWait(appConfig.Digest); err != nil {
+			logger.Warn("汇总配置错误，逐条发送", "app", appConfig.AppID, "error", err)
+		} else {
+			return app.bufferDigest(appConfig, record, req, maxWait), nil
+		}
 	}
 
-	return cfg, nil
+	return app.dispatch(ctx, appConfig, record, req, opts)
 }
 
-// InitPlugins 初始化插件系统
-func (app *NotificationApp) InitPlugins() {
-	// 创建插件管理器，插件目录为 plugins（相对于运行目录）
-	app.pluginManager = pluginmgr.NewManager(config.EnvCfg.PLUGINS_DIR)
-
-	// 加载所有插件
-	if err := app.pluginManager.LoadAll(); err != nil {
-		logger.Error("加载插件失败: %v", err)
-		return
+// dispatch 按路由规则选择通知服务并发送记录中的消息，规则可以使用其它模板和目标
+func (app *NotificationApp) dispatch(ctx context.Context, appConfig config.NotificationApp, record *history.Record, req *map[string]any, opts SendOptions) (*SendResult, error) {
+	// 应用的免打扰时段：丢弃或推迟整条消息
+	if appConfig.QuietHours != nil {
+		at := opts.SendAt
+		if at.IsZero() {
+			at = time.Now()
+		}
+		drop, sendAt := checkQuietHours(appConfig.QuietHours, record.Message.Level, at, appConfig.AppID)
+		if drop {
+			record.Status = history.MessageDropped
+			app.saveHistory(record)
+			return &SendResult{MessageID: record.ID, Dropped: true}, nil
+		}
+		if sendAt != at {
+			opts.SendAt = sendAt
+		}
 	}
-}
 
-// Send 发送通知
-func (app *NotificationApp) Send(ctx context.Context, appConfig config.NotificationApp, req *map[string]any) error {
-	// 获取通知应用配置
-	appConfig, exists := app.configManager.GetConfig().NotificationApps[appConfig.AppID]
-	if !exists {
-		return fmt.Errorf("通知应用 %s 不存在", appConfig.Name)
-	}
+	dispatches := app.resolveRoutes(appConfig, req, record.Message, record.Targets)
+	record.Notifiers = dispatchNotifiers(dispatches)
 
-	if !appConfig.Enabled {
-		return fmt.Errorf("通知应用 %s 未启用", appConfig.Name)
-	}
+	opts.escalation = app.planEscalation(appConfig, record, dispatches, opts.SendAt)
+
+	return app.sendToNotifiers(ctx, record, dispatches, opts)
+}
 
+// render 使用插件或模板生成通知消息，插件判定无需通知时返回 nil 消息
+func (app *NotificationApp) render(ctx context.Context, appConfig config.NotificationApp, req *map[string]any) (*notifier.NotificationMessage, []string, error) {
 	// 检查是否配置了插件，优先使用插件处理
 	if appConfig.PluginID != "" {
-		return app.sendWithPlugin(ctx, appConfig, req)
+		return app.renderWithPlugin(ctx, appConfig, req)
 	}
 
 	// 如果没有配置插件，使用模板处理
-	return app.sendWithTemplate(ctx, appConfig, req)
+	return app.renderWithTemplate(appConfig, req)
 }
 
-// sendWithPlugin 使用插件处理并发送通知
-func (app *NotificationApp) sendWithPlugin(ctx context.Context, appConfig config.NotificationApp, req *map[string]any) error {
+// renderWithPlugin 使用插件处理生成通知消息
+func (app *NotificationApp) renderWithPlugin(ctx context.Context, appConfig config.NotificationApp, req *map[string]any) (*notifier.NotificationMessage, []string, error) {
 	if app.pluginManager == nil {
-		return fmt.Errorf("插件管理器未初始化")
+		return nil, nil, fmt.Errorf("插件管理器未初始化")
 	}
 
 	// 检查插件是否存在和启用
 	if !app.pluginManager.IsPluginEnabled(appConfig.PluginID) {
-		return fmt.Errorf("插件 %s 不存在或未启用", appConfig.PluginID)
+		return nil, nil, withKind(ErrAppConfig, fmt.Errorf("插件 %s 不存在或未启用", appConfig.PluginID))
 	}
 
 	// 使用插件处理数据
 	output, err := app.pluginManager.ProcessChain(ctx, appConfig.PluginID, *req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("插件处理失败: %w", err)
+	}
 	if !output.IsNotify {
 		logger.Info("插件结果不需要通知")
-		return nil
-	}
-	if err != nil {
-		return fmt.Errorf("插件处理失败: %w", err)
+		return nil, nil, nil
 	}
 
 	// 将插件输出转换为通知消息
@@ -362,6 +359,15 @@ func (app *NotificationApp) sendWithPlugin(ctx context.Context, appConfig config
 		Image:     output.Image,
 		URL:       output.URL,
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Format:    notifier.ParseFormat(output.Format),
+		Level:     notifier.ParseLevel(output.Level),
+		Images:    output.Images,
+	}
+	for _, action := range output.Actions {
+		message.Actions = append(message.Actions, notifier.Action{Label: action.Label, URL: action.URL})
+	}
+	for _, field := range output.Fields {
+		message.Fields = append(message.Fields, notifier.Field{Key: field.Key, Value: field.Value})
 	}
 
 	// 如果插件输出没有图片，使用应用默认图片
@@ -375,26 +381,26 @@ func (app *NotificationApp) sendWithPlugin(ctx context.Context, appConfig config
 		targets = output.Targets
 	}
 
-	return app.sendToNotifiers(ctx, appConfig, message, targets)
+	return message, targets, nil
 }
 
-// sendWithTemplate 使用模板处理并发送通知
-func (app *NotificationApp) sendWithTemplate(ctx context.Context, appConfig config.NotificationApp, req *map[string]any) error {
+// renderWithTemplate 使用模板生成通知消息
+func (app *NotificationApp) renderWithTemplate(appConfig config.NotificationApp, req *map[string]any) (*notifier.NotificationMessage, []string, error) {
 	// 根据TemplateID查找模板内容
 	template, err := app.getTemplateContent(appConfig.TemplateID)
 	if err != nil {
-		return fmt.Errorf("获取模板失败: %w", err)
+		return nil, nil, withKind(ErrAppConfig, fmt.Errorf("获取模板失败: %w", err))
 	}
 
 	title, err := app.renderTemplate(appConfig.TemplateID+"_title", template.Title, req)
 	if err != nil {
-		return fmt.Errorf("渲染消息模板失败: %w", err)
+		return nil, nil, fmt.Errorf("渲染消息模板失败: %w", err)
 	}
 
 	// 渲染消息模板
 	content, err := app.renderTemplate(appConfig.TemplateID+"_content", template.Content, req)
 	if err != nil {
-		return fmt.Errorf("渲染消息模板失败: %w", err)
+		return nil, nil, fmt.Errorf("渲染消息模板失败: %w", err)
 	}
 
 	url, _ := app.renderTemplate(appConfig.TemplateID+"_url", template.URL, req)
@@ -416,96 +422,248 @@ func (app *NotificationApp) sendWithTemplate(ctx context.Context, appConfig conf
 		Image:     image,
 		URL:       url,
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Format:    notifier.ParseFormat(template.Format),
 	}
+	app.renderRichFields(appConfig.TemplateID, template, req, message)
 
-	return app.sendToNotifiers(ctx, appConfig, message, targets)
+	return message, targets, nil
 }
 
-// sendToNotifiers 发送消息到所有配置的通知服务
-func (app *NotificationApp) sendToNotifiers(ctx context.Context, appConfig config.NotificationApp, message *notifier.NotificationMessage, targets []string) error {
-	if len(appConfig.Notifiers) == 0 {
-		return fmt.Errorf("通知应用 %s 未配置任何通知服务", appConfig.Name)
+// renderRichFields 渲染模板中的级别、图片、按钮和键值字段，可选项渲染失败时忽略
+func (app *NotificationApp) renderRichFields(templateID string, tmpl *config.MessageTemplate, req *map[string]any, message *notifier.NotificationMessage) {
+	if tmpl.Level != "" {
+		level, _ := app.renderTemplate(templateID+"_level", tmpl.Level, req)
+		message.Level = notifier.ParseLevel(level)
 	}
 
-	// 发送到配置的通知服务 - 并发发送，最多10个协程
-	const maxConcurrentNotifiers = 10
-
-	var (
-		errors    []error
-		errorsMux sync.Mutex // 保护errors切片的并发安全
-		wg        sync.WaitGroup
-		semaphore = make(chan struct{}, maxConcurrentNotifiers) // 信号量，限制并发数
-	)
+	if tmpl.Images != "" {
+		images, _ := app.renderTemplate(templateID+"_images", tmpl.Images, req)
+		message.Images = append(message.Images, notifier.SplitImageList(images)...)
+	}
 
-	// 遍历所有通知服务，为每个启动一个协程
-	for _, notifierName := range appConfig.Notifiers {
-		// 提前检查通知服务是否存在和启用
-		notifierInstance, exists := app.notifiers[notifierName]
-		if !exists {
-			errorsMux.Lock()
-			errors = append(errors, fmt.Errorf("通知服务 %s 不存在", notifierName))
-			errorsMux.Unlock()
+	for i, action := range tmpl.Actions {
+		url, _ := app.renderTemplate(fmt.Sprintf("%s_action_%d_url", templateID, i), action.URL, req)
+		if url = strings.TrimSpace(url); url == "" {
+			// 链接为空的按钮不显示，便于按条件生成按钮
 			continue
 		}
+		label, _ := app.renderTemplate(fmt.Sprintf("%s_action_%d_label", templateID, i), action.Label, req)
+		message.Actions = append(message.Actions, notifier.Action{Label: strings.TrimSpace(label), URL: url})
+	}
 
-		if !notifierInstance.IsEnabled() {
-			errorsMux.Lock()
-			errors = append(errors, fmt.Errorf("通知服务 %s 未启用", notifierName))
-			errorsMux.Unlock()
+	for i, field := range tmpl.Fields {
+		key, _ := app.renderTemplate(fmt.Sprintf("%s_field_%d_key", templateID, i), field.Key, req)
+		value, _ := app.renderTemplate(fmt.Sprintf("%s_field_%d_value", templateID, i), field.Value, req)
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || value == "" {
+			// 值为空的字段不显示
 			continue
 		}
+		message.Fields = append(message.Fields, notifier.Field{Key: key, Value: value})
+	}
+}
+
+// sendToNotifiers 按路由分组发送消息到各通知服务
+//
+// 每个 (通知服务, 目标) 作为一条投递写入出站队列，由队列负责发送和失败重试；
+// 同步模式下等待每条投递完成首次尝试后汇总结果，配置了故障转移时继续等待备用通知服务。
+func (app *NotificationApp) sendToNotifiers(ctx context.Context, record *history.Record, dispatches []dispatch, opts SendOptions) (*SendResult, error) {
+	if len(dispatchNotifiers(dispatches)) == 0 {
+		err := withKind(ErrAppConfig, fmt.Errorf("通知应用 %s 未配置任何通知服务", record.AppName))
+		record.Status = history.MessageError
+		record.Error = err.Error()
+		app.saveHistory(record)
+		return nil, err
+	}
+
+	deliveries, statuses, waitIDs := app.newDeliveries(ctx, record, dispatches, opts.SendAt, 0, 0)
+	record.Deliveries = append(record.Deliveries, statuses...)
+
+	// 升级通知在指定时间后发送，消息确认后取消
+	for i, step := range opts.escalation {
+		escalated, escalatedStatuses, _ := app.newDeliveries(ctx, record, []dispatch{step.dispatch}, step.at, 0, i+1)
+		deliveries = append(deliveries, escalated...)
+		record.Deliveries = append(record.Deliveries, escalatedStatuses...)
+	}
+
+	// 先写历史再入队，保证投递结果回调时能找到记录
+	app.saveHistory(record)
+
+	if len(deliveries) > 0 {
+		if err := app.outbox.Enqueue(deliveries...); err != nil {
+			record.Status = history.MessageError
+			record.Error = err.Error()
+			app.saveHistory(record)
+			return nil, fmt.Errorf("写入发送队列失败: %w", err)
+		}
+	}
+
+	// 通知服务不存在等未进入队列的失败也需要故障转移
+	if len(waitIDs) == 0 {
+		app.advanceFallback(record.ID)
+	}
+
+	// 同步模式：等待每条投递完成首次尝试，未完成的保持排队状态；定时发送的投递不等待
+	if !opts.Async {
+		app.waitDeliveries(ctx, record, waitIDs)
+	}
+
+	return &SendResult{
+		MessageID:  record.ID,
+		Status:     history.Summarize(record.Deliveries),
+		Deliveries: record.Deliveries,
+	}, nil
+}
+
+// newDeliveries 为各路由分组创建投递，返回出站队列的投递、对应的历史状态和同步模式下需要等待的投递ID
+//
+// stage 为故障转移的阶段，escalation 为升级的步骤；sendAt 不为空时定时发送。
+// 消息中的内网图片和 data: URI 按各通知服务的图片限制缓存后替换为公网链接，
+// 未配置外部访问地址时由通知服务自行上传；记录中的原消息不变。
+func (app *NotificationApp) newDeliveries(ctx context.Context, record *history.Record, dispatches []dispatch, sendAt time.Time, stage, escalation int) ([]*outbox.Delivery, []history.DeliveryStatus, []string) {
+	deliveries := []*outbox.Delivery{}
+	statuses := []history.DeliveryStatus{}
+	waitIDs := []string{}
+	now := time.Now()
+	cfg := app.configManager.GetConfig()
+
+	for _, route := range dispatches {
+		for _, notifierName := range route.notifiers {
+			status := history.DeliveryStatus{
+				Notifier:   notifierName,
+				Route:      route.route,
+				Stage:      stage,
+				Escalation: escalation,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
 
-		// 为每个有效的通知服务启动协程
-		wg.Add(1)
-		go func(name string, notifier notifier.Notifier) {
-			defer wg.Done()
+			// 提前检查通知服务是否存在和启用
+			notifierInstance, exists := app.getNotifier(notifierName)
+			var err error
+			if !exists {
+				err = fmt.Errorf("通知服务 %s 不存在", notifierName)
+			} else if !notifierInstance.IsEnabled() {
+				err = fmt.Errorf("通知服务 %s 未启用", notifierName)
+			}
+			if err != nil {
+				status.Status = history.DeliveryFailed
+				status.Error = err.Error()
+				statuses = append(statuses, status)
+				continue
+			}
 
-			// 获取信号量，控制并发数
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			// 通知服务的免打扰时段
+			deliverAt := sendAt
+			if quiet := cfg.Notifiers[notifierName].QuietHours; quiet != nil {
+				at := deliverAt
+				if at.IsZero() {
+					at = now
+				}
+				drop, deferred := checkQuietHours(quiet, route.message.Level, at, notifierName)
+				if drop {
+					status.Status = history.DeliveryDropped
+					status.Error = "免打扰时段内丢弃"
+					statuses = append(statuses, status)
+					continue
+				}
+				if deferred != at {
+					deliverAt = deferred
+				}
+			}
 
-			// 发送通知
-			if err := notifier.Send(ctx, message, targets); err != nil {
-				errorsMux.Lock()
-				errors = append(errors, fmt.Errorf("通知服务 %s 发送失败: %w", name, err))
-				errorsMux.Unlock()
+			message := app.media.Rewrite(ctx, route.message, notifier.ImageLimitsOf(notifierInstance))
+			for _, group := range splitTargets(notifierInstance, route.targets) {
+				d := &outbox.Delivery{
+					// 序号按记录中已有的投递递增，故障转移和升级追加的投递不会重复
+					ID:            fmt.Sprintf("%s-%03d", record.ID, len(record.Deliveries)+len(statuses)),
+					MessageID:     record.ID,
+					AppID:         record.AppID,
+					Notifier:      notifierName,
+					Route:         route.route,
+					Stage:         stage,
+					Escalation:    escalation,
+					Targets:       group,
+					Message:       message,
+					NextAttemptAt: deliverAt,
+				}
+				deliveries = append(deliveries, d)
+				groupStatus := status
+				groupStatus.ID = d.ID
+				groupStatus.Targets = group
+				groupStatus.Status = history.DeliveryQueued
+				if deliverAt.After(now) {
+					groupStatus.Status = history.DeliveryScheduled
+					groupStatus.NextAttemptAt = &deliverAt
+				} else {
+					waitIDs = append(waitIDs, d.ID)
+				}
+				statuses = append(statuses, groupStatus)
 			}
-		}(notifierName, notifierInstance)
+		}
 	}
+	return deliveries, statuses, waitIDs
+}
 
-	// 等待所有协程完成
-	wg.Wait()
+// waitDeliveries 等待投递完成首次尝试并更新记录中的状态
+//
+// 首次尝试全部失败且配置了故障转移时，继续等待下一组通知服务的首次尝试。
+func (app *NotificationApp) waitDeliveries(ctx context.Context, record *history.Record, waitIDs []string) {
+	done := map[string]outbox.Delivery{}
+	for len(waitIDs) > 0 && ctx.Err() == nil {
+		waited := app.outbox.Wait(ctx, waitIDs)
+		for id, d := range waited {
+			done[id] = d
+		}
+		waitIDs = nil
 
-	// 如果有错误，返回合并的错误信息
-	if len(errors) > 0 {
-		var errorMsg string
-		for i, err := range errors {
-			if i > 0 {
-				errorMsg += "\n "
+		if !app.hasFallback(record.AppID) {
+			break
+		}
+		// 等待结果先于投递回调返回，先同步到消息历史再判断是否需要转移
+		for _, d := range waited {
+			if err := app.history.UpdateDelivery(d); err != nil {
+				logger.Error("更新消息历史失败", "messageId", d.MessageID, "deliveryId", d.ID, "error", err)
+			}
+		}
+		// 投递结果回调可能已经完成了故障转移，从消息历史中读取追加的投递
+		app.advanceFallback(record.ID)
+		fresh, ok, err := app.history.Get(record.ID)
+		if err != nil || !ok {
+			break
+		}
+		record.Deliveries = fresh.Deliveries
+		for _, ds := range record.Deliveries {
+			if _, waited := done[ds.ID]; ds.ID != "" && !waited && ds.Escalation == 0 && ds.Status == history.DeliveryQueued {
+				waitIDs = append(waitIDs, ds.ID)
 			}
-			errorMsg += err.Error()
 		}
-		return fmt.Errorf("发送通知时发生错误: %s", errorMsg)
 	}
 
-	return nil
+	// 消息历史的更新可能晚于等待结果，以等待到的状态为准；故障转移取消的投递以消息历史为准
+	for i := range record.Deliveries {
+		if d, ok := done[record.Deliveries[i].ID]; ok && record.Deliveries[i].Status != history.DeliveryCancelled {
+			record.Deliveries[i] = history.NewDeliveryStatus(d)
+		}
+	}
 }
 
 // renderTemplate 渲染消息模板
 func (app *NotificationApp) renderTemplate(name, templateStr string, data *map[string]any) (string, error) {
 	if templateStr == "" {
 		// 如果没有模板，使用默认格式
-		return "", fmt.Errorf("模板不能为空")
+		return "", withKind(ErrAppConfig, fmt.Errorf("模板不能为空"))
 	}
 
-	tmpl, err := template.New(name).Funcs(funcMap).Parse(templateStr)
+	tmpl, err := template.New(name).Funcs(tmplfunc.FuncMap).Parse(templateStr)
 	if err != nil {
-		return "", fmt.Errorf("解析模板失败: %w", err)
+		return "", withKind(ErrAppConfig, fmt.Errorf("解析模板失败: %w", err))
 	}
 
+	// 模板能解析但执行失败通常是请求数据不符合模板的要求
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, *data); err != nil {
-		return "", fmt.Errorf("执行模板失败: %w", err)
+		return "", withKind(ErrBadRequest, fmt.Errorf("执行模板失败: %w", err))
 	}
 	txt := buf.String()
 	txt = strings.ReplaceAll(txt, "<no value>", "")
@@ -519,6 +677,8 @@ func (app *NotificationApp) GetNotificationApps() map[string]config.Notification
 
 // GetNotifiers 获取所有通知服务
 func (app *NotificationApp) GetNotifiers() map[string]notifier.Notifier {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
 	return app.notifiers
 }
 
@@ -548,31 +708,20 @@ func (app *NotificationApp) ValidateConfig() error {
 		if !instance.Enabled {
 			continue
 		}
+		if err := validateQuietHours(instance.QuietHours); err != nil {
+			return fmt.Errorf("通知服务实例 %s 的免打扰配置错误: %w", instanceName, err)
+		}
+		if _, err := ratelimit.New(notifier.RateLimitFor(instance)); err != nil {
+			return fmt.Errorf("通知服务实例 %s 的限速配置错误: %w", instanceName, err)
+		}
 
-		switch instance.Type {
-		case config.WechatWorkAPPBot:
-			if _, err := app.parseWechatWorkConfig(instance.Config); err != nil {
-				return fmt.Errorf("通知服务实例 %s (企业微信应用) 配置错误: %v", instanceName, err)
-			}
-		case config.WechatWorkWebhookBot:
-			if _, err := app.parseWechatWorkWebhookConfig(instance.Config); err != nil {
-				return fmt.Errorf("通知服务实例 %s (企业微信群机器人) 配置错误: %v", instanceName, err)
-			}
-		case config.TelegramAppBot:
-			if _, err := app.parseTelegramConfig(instance.Config); err != nil {
-				return fmt.Errorf("通知服务实例 %s (Telegram) 配置错误: %v", instanceName, err)
-			}
-		case config.DingTalkAppBot:
-			if _, err := app.parseDingTalkConfig(instance.Config); err != nil {
-				return fmt.Errorf("通知服务实例 %s (钉钉) 配置错误: %v", instanceName, err)
-			}
-		case config.FeishuAppBot:
-			if _, err := app.parseFeishuConfig(instance.Config); err != nil {
-				return fmt.Errorf("通知服务实例 %s (飞书) 配置错误: %v", instanceName, err)
-			}
-		default:
+		info, exists := notifier.Lookup(instance.Type)
+		if !exists {
 			return fmt.Errorf("通知服务实例 %s 使用了未知的类型: %s", instanceName, instance.Type)
 		}
+		if err := notifier.Validate(instance); err != nil {
+			return fmt.Errorf("通知服务实例 %s (%s) 配置错误: %v", instanceName, info.DisplayName, err)
+		}
 	}
 
 	// 验证通知应用配置
@@ -581,28 +730,66 @@ func (app *NotificationApp) ValidateConfig() error {
 			continue
 		}
 
-		// 验证应用ID不为空
-		if appConfig.AppID == "" {
-			return fmt.Errorf("通知应用 %s 的 app_id 不能为空", name)
+		if err := ValidateApp(app.configManager.GetConfig(), name, appConfig); err != nil {
+			return err
 		}
+	}
 
-		// 验证是否配置了通知服务
-		// if len(appConfig.Notifiers) == 0 {
-		// 	return fmt.Errorf("通知应用 %s 未配置任何通知服务", name)
-		// }
+	return nil
+}
 
-		// 验证引用的通知服务实例是否存在
-		for _, notifierName := range appConfig.Notifiers {
-			if _, exists := app.configManager.GetConfig().Notifiers[notifierName]; !exists {
-				return fmt.Errorf("通知应用 %s 引用了不存在的通知服务实例: %s", name, notifierName)
-			}
+// ValidateApp 验证单个通知应用的配置：引用的通知服务和模板、路由和过滤表达式、升级、去重、免打扰和汇总规则，
+// 启动时和通过管理接口保存应用时都会调用，name 用于错误信息
+func ValidateApp(cfg *config.Config, name string, appConfig config.NotificationApp) error {
+	// 验证应用ID不为空
+	if appConfig.AppID == "" {
+		return fmt.Errorf("通知应用 %s 的 app_id 不能为空", name)
+	}
+
+	// 验证是否配置了通知服务
+	// if len(appConfig.Notifiers) == 0 {
+	// 	return fmt.Errorf("通知应用 %s 未配置任何通知服务", name)
+	// }
+
+	// 验证引用的通知服务实例是否存在
+	for _, notifierName := range appConfig.Notifiers {
+		if _, exists := cfg.Notifiers[notifierName]; !exists {
+			return fmt.Errorf("通知应用 %s 引用了不存在的通知服务实例: %s", name, notifierName)
 		}
+	}
 
-		// 验证应用级别的认证配置
-		if appConfig.Auth != nil && appConfig.Auth.Enabled && appConfig.Auth.Token == "" {
-			return fmt.Errorf("通知应用 %s 启用了认证但未配置token", name)
+	if err := validateRoutes(cfg, name, appConfig); err != nil {
+		return err
+	}
+	if err := validateFilter(name, appConfig); err != nil {
+		return err
+	}
+	if err := validateEscalation(cfg, name, appConfig); err != nil {
+		return err
+	}
+	if appConfig.Throttle != nil {
+		if _, err := throttleRule(appConfig.Throttle); err != nil {
+			return fmt.Errorf("通知应用 %s 的去重配置错误: %w", name, err)
+		}
+	}
+	if err := validateQuietHours(appConfig.QuietHours); err != nil {
+		return fmt.Errorf("通知应用 %s 的免打扰配置错误: %w", name, err)
+	}
+	if appConfig.Digest != nil {
+		if _, err := digestMaxWait(appConfig.Digest); err != nil {
+			return fmt.Errorf("通知应用 %s 的汇总配置错误: %w", name, err)
+		}
+		if id := appConfig.Digest.TemplateID; id != "" {
+			if _, exists := cfg.Templates[id]; !exists {
+				return fmt.Errorf("通知应用 %s 的汇总引用了不存在的模板: %s", name, id)
+			}
 		}
 	}
 
+	// 验证应用级别的认证配置
+	if appConfig.Auth != nil && appConfig.Auth.Enabled && appConfig.Auth.Token == "" {
+		return fmt.Errorf("通知应用 %s 启用了认证但未配置token", name)
+	}
+
 	return nil
 }
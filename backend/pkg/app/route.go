@@ -0,0 +1,170 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/expr"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+)
+
+// dispatch 一组通知服务及其发送的消息和目标，由路由规则生成
+type dispatch struct {
+	route     string // 匹配的路由规则名称，默认路由为空
+	notifiers []string
+	targets   []string
+	message   *notifier.NotificationMessage
+}
+
+// messageEnv 构建条件表达式使用的数据
+//
+// 请求数据的字段放在顶层，payload 和 message 分别为原始请求和渲染后的消息；
+// 请求中没有的 title、content、level、url、image、fields、targets 取自渲染后的消息。
+func messageEnv(req map[string]any, message *notifier.NotificationMessage, targets []string) map[string]any {
+	fields := make(map[string]any, len(message.Fields))
+	for _, field := range message.Fields {
+		fields[field.Key] = field.Value
+	}
+	targetList := make([]any, len(targets))
+	for i, target := range targets {
+		targetList[i] = target
+	}
+	msg := map[string]any{
+		"title":   message.Title,
+		"content": message.Content,
+		"level":   string(message.Level),
+		"url":     message.URL,
+		"image":   message.Image,
+		"format":  string(message.Format),
+		"fields":  fields,
+		"targets": targetList,
+	}
+
+	env := make(map[string]any, len(req)+len(msg)+2)
+	for k, v := range msg {
+		env[k] = v
+	}
+	for k, v := range req {
+		env[k] = v
+	}
+	env["payload"] = req
+	env["message"] = msg
+	return env
+}
+
+// resolveRoutes 按顺序匹配应用的路由规则，返回需要发送的通知服务分组
+//
+// 规则匹配后默认停止，设置了 continue 时继续匹配后续规则；没有规则匹配时使用应用的通知服务（默认路由）。
+// 表达式错误或模板渲染失败的规则视为不匹配并记录日志，不影响其它规则。
+func (app *NotificationApp) resolveRoutes(appConfig config.NotificationApp, req *map[string]any, message *notifier.NotificationMessage, targets []string) []dispatch {
+	defaultRoute := dispatch{notifiers: appConfig.Notifiers, targets: targets, message: message}
+	if len(appConfig.Routes) == 0 {
+		return []dispatch{defaultRoute}
+	}
+
+	env := messageEnv(*req, message, targets)
+	var dispatches []dispatch
+	for i, route := range appConfig.Routes {
+		name := route.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		matched, err := expr.Match(route.Match, env)
+		if err != nil {
+			logger.Warn("路由规则匹配失败", "app", appConfig.AppID, "route", name, "error", err)
+			continue
+		}
+		if !matched {
+			continue
+		}
+
+		d := dispatch{route: name, notifiers: route.Notifiers, targets: targets, message: message}
+		// 使用其它模板重新渲染
+		if route.TemplateID != "" && route.TemplateID != appConfig.TemplateID {
+			routeApp := appConfig
+			routeApp.TemplateID = route.TemplateID
+			routeMessage, routeTargets, err := app.renderWithTemplate(routeApp, req)
+			if err != nil {
+				logger.Warn("路由规则渲染模板失败", "app", appConfig.AppID, "route", name, "template", route.TemplateID, "error", err)
+				continue
+			}
+			d.message, d.targets = routeMessage, routeTargets
+		}
+		if route.Targets != "" {
+			rendered, err := app.renderTemplate(fmt.Sprintf("%s_route_%d_targets", appConfig.AppID, i), route.Targets, req)
+			if err != nil {
+				logger.Warn("路由规则渲染目标失败", "app", appConfig.AppID, "route", name, "error", err)
+				continue
+			}
+			d.targets = splitList(rendered)
+		}
+		dispatches = append(dispatches, d)
+
+		if !route.Continue {
+			break
+		}
+	}
+
+	if len(dispatches) == 0 {
+		return []dispatch{defaultRoute}
+	}
+	return dispatches
+}
+
+// splitList 拆分逗号分隔的列表，去掉空项
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// dispatchNotifiers 汇总所有分组的通知服务（去重），用于消息历史
+func dispatchNotifiers(dispatches []dispatch) []string {
+	notifiers := []string{}
+	seen := map[string]bool{}
+	for _, d := range dispatches {
+		for _, name := range d.notifiers {
+			if !seen[name] {
+				seen[name] = true
+				notifiers = append(notifiers, name)
+			}
+		}
+	}
+	return notifiers
+}
+
+// validateRoutes 校验路由规则的表达式、通知服务和模板
+func validateRoutes(cfg *config.Config, name string, appConfig config.NotificationApp) error {
+	for i, route := range appConfig.Routes {
+		routeName := route.Name
+		if routeName == "" {
+			routeName = fmt.Sprintf("#%d", i+1)
+		}
+		if strings.TrimSpace(route.Match) != "" {
+			if _, err := expr.Compile(route.Match); err != nil {
+				return fmt.Errorf("通知应用 %s 的路由规则 %s 配置错误: %w", name, routeName, err)
+			}
+		}
+		// 匹配后没有通知服务会丢弃消息，也不会落到默认路由
+		if len(route.Notifiers) == 0 {
+			return fmt.Errorf("通知应用 %s 的路由规则 %s 未配置通知服务", name, routeName)
+		}
+		for _, notifierName := range route.Notifiers {
+			if _, exists := cfg.Notifiers[notifierName]; !exists {
+				return fmt.Errorf("通知应用 %s 的路由规则 %s 引用了不存在的通知服务实例: %s", name, routeName, notifierName)
+			}
+		}
+		if route.TemplateID != "" {
+			if _, exists := cfg.Templates[route.TemplateID]; !exists {
+				return fmt.Errorf("通知应用 %s 的路由规则 %s 引用了不存在的模板: %s", name, routeName, route.TemplateID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/schedule"
+)
+
+// checkQuietHours 检查 at 时刻发送的消息是否处于免打扰时段，返回是否丢弃及推迟后的发送时间
+//
+// 不在时段内时返回原时间；配置错误时记录日志并忽略免打扰。
+func checkQuietHours(q *config.QuietHours, level notifier.Level, at time.Time, owner string) (drop bool, sendAt time.Time) {
+	action, until, quiet, err := schedule.CheckConfig(q, level, at)
+	if err != nil {
+		logger.Warn("免打扰配置错误，忽略免打扰", "owner", owner, "error", err)
+		return false, at
+	}
+	if !quiet {
+		return false, at
+	}
+	if action == schedule.ActionDrop {
+		return true, at
+	}
+	return false, until
+}
+
+// validateQuietHours 校验免打扰配置
+func validateQuietHours(q *config.QuietHours) error {
+	if q == nil {
+		return nil
+	}
+	_, err := schedule.Parse(q)
+	return err
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+)
+
+// TestResult 应用测试结果：渲染后的消息、匹配的路由及各通知服务的发送结果
+type TestResult struct {
+	// Skipped 插件判定该请求不需要通知
+	Skipped bool `json:"skipped,omitempty"`
+	// Filtered 被应用的过滤规则丢弃，Filter 为命中的规则
+	Filtered bool   `json:"filtered,omitempty"`
+	Filter   string `json:"filter,omitempty"`
+	// Message 渲染后的消息和目标
+	Message *notifier.NotificationMessage `json:"message,omitempty"`
+	Targets []string                      `json:"targets,omitempty"`
+	// Dispatches 路由规则选择的通知服务分组
+	Dispatches []TestDispatch `json:"dispatches,omitempty"`
+}
+
+// TestDispatch 一组通知服务的测试结果
+type TestDispatch struct {
+	Route     string                        `json:"route,omitempty"` // 匹配的路由规则名称，默认路由为空
+	Notifiers []string                      `json:"notifiers"`
+	Targets   []string                      `json:"targets,omitempty"`
+	Message   *notifier.NotificationMessage `json:"message"`
+	Results   []NotifierTestResult          `json:"results,omitempty"` // 只有实际发送或预览时才有结果
+}
+
+// NotifierTestResult 单个通知服务的测试发送或预览结果
+type NotifierTestResult struct {
+	Notifier string           `json:"notifier"`
+	Error    string           `json:"error,omitempty"` // 通知服务不存在或未启用等无法发送的原因
+	Results  notifier.Results `json:"results,omitempty"`
+	// Requests 预览模式下通知服务将要发送的 HTTP 请求
+	Requests []notifier.CapturedRequest `json:"requests,omitempty"`
+}
+
+// Test 使用示例数据执行应用的过滤、插件或模板渲染和路由规则，send 为 true 时直接发送到匹配的通知服务
+//
+// 测试发送不经过出站队列，不检查去重、汇总、免打扰、限速和熔断，也不写入消息历史。
+func (app *NotificationApp) Test(ctx context.Context, appID string, req map[string]any, send bool) (*TestResult, error) {
+	if !send {
+		return app.test(ctx, appID, req, nil)
+	}
+	return app.test(ctx, appID, req, app.testSend)
+}
+
+// Preview 预览模式：与 Test 相同地处理请求，各通知服务格式化消息并构造请求但不发出，
+// 结果中包含每个通知服务将要发送的请求（密钥已隐藏）
+func (app *NotificationApp) Preview(ctx context.Context, appID string, req map[string]any) (*TestResult, error) {
+	// 整个预览都使用预览模式的 context，转存图片时不下载内网图片、不写入图片缓存；
+	// 各通知服务发送时再使用记录自己请求的 context
+	ctx, _ = notifier.WithDryRun(ctx, nil)
+	return app.test(ctx, appID, req, app.previewSend)
+}
+
+// test 执行过滤、渲染和路由，send 不为空时对每个匹配的通知服务调用 send
+func (app *NotificationApp) test(ctx context.Context, appID string, req map[string]any, send func(context.Context, string, *notifier.NotificationMessage, []string) NotifierTestResult) (*TestResult, error) {
+	appConfig, exists := app.configManager.GetConfig().NotificationApps[appID]
+	if !exists {
+		return nil, fmt.Errorf("通知应用 %s 不存在", appID)
+	}
+	if req == nil {
+		req = map[string]any{}
+	}
+
+	if reason := filterRequest(appConfig, req); reason != "" {
+		return &TestResult{Filtered: true, Filter: reason}, nil
+	}
+
+	message, targets, err := app.render(ctx, appConfig, &req)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return &TestResult{Skipped: true}, nil
+	}
+
+	result := &TestResult{Message: message, Targets: targets}
+	ctx = notifier.WithPayload(ctx, func() map[string]any { return req })
+	for _, d := range app.resolveRoutes(appConfig, &req, message, targets) {
+		td := TestDispatch{Route: d.route, Notifiers: d.notifiers, Targets: d.targets, Message: d.message}
+		if send != nil {
+			for _, name := range d.notifiers {
+				message := d.message
+				if n, exists := app.getNotifier(name); exists {
+					message = app.media.Rewrite(ctx, message, notifier.ImageLimitsOf(n))
+				}
+				td.Results = append(td.Results, send(ctx, name, message, d.targets))
+			}
+		}
+		result.Dispatches = append(result.Dispatches, td)
+	}
+	return result, nil
+}
+
+// testSend 直接调用通知服务发送测试消息
+func (app *NotificationApp) testSend(ctx context.Context, name string, message *notifier.NotificationMessage, targets []string) NotifierTestResult {
+	result := NotifierTestResult{Notifier: name}
+	n, exists := app.getNotifier(name)
+	switch {
+	case !exists:
+		result.Error = fmt.Sprintf("通知服务 %s 不存在或配置无效", name)
+	case !n.IsEnabled():
+		result.Error = fmt.Sprintf("通知服务 %s 未启用", name)
+	default:
+		result.Results = n.Send(ctx, message, targets)
+	}
+	return result
+}
+
+// previewSend 使用新建的通知服务实例在预览模式下发送，按投递时的方式拆分目标；
+// 不使用正在运行的实例，避免预览返回的模拟访问令牌被缓存
+func (app *NotificationApp) previewSend(ctx context.Context, name string, message *notifier.NotificationMessage, targets []string) NotifierTestResult {
+	result := NotifierTestResult{Notifier: name}
+	instance, exists := app.configManager.GetConfig().Notifiers[name]
+	if !exists {
+		result.Error = fmt.Sprintf("通知服务 %s 不存在", name)
+		return result
+	}
+	if !instance.Enabled {
+		result.Error = fmt.Sprintf("通知服务 %s 未启用", name)
+		return result
+	}
+	n, err := notifier.New(instance)
+	if err != nil {
+		result.Error = fmt.Sprintf("通知服务 %s 配置无效: %v", name, err)
+		return result
+	}
+
+	dryCtx, rec := notifier.WithDryRun(ctx, notifier.SecretValues(instance))
+	for _, group := range splitTargets(n, targets) {
+		result.Results = append(result.Results, n.Send(dryCtx, message, group)...)
+	}
+	result.Requests = rec.Requests()
+	return result
+}
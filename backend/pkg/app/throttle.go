@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/throttle"
+)
+
+// throttleKey 计算去重键，未配置或渲染失败时使用标题和内容的哈希
+func (app *NotificationApp) throttleKey(appConfig config.NotificationApp, req *map[string]any, message *notifier.NotificationMessage) string {
+	if tmpl := appConfig.Throttle.Key; tmpl != "" {
+		key, err := app.renderTemplate(appConfig.AppID+"_throttle_key", tmpl, req)
+		if key = strings.TrimSpace(key); err == nil && key != "" {
+			return key
+		}
+		logger.Warn("渲染去重键失败，使用消息内容计算", "app", appConfig.AppID, "error", err)
+	}
+	sum := sha256.Sum256([]byte(message.Title + "\n" + message.Content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// throttleRule 把应用配置转换为限流规则
+func throttleRule(cfg *config.AppThrottle) (throttle.Rule, error) {
+	window, err := time.ParseDuration(cfg.Window)
+	if err != nil {
+		return throttle.Rule{}, fmt.Errorf("时间窗口 %q 格式错误: %w", cfg.Window, err)
+	}
+	if window <= 0 {
+		return throttle.Rule{}, fmt.Errorf("时间窗口必须大于 0")
+	}
+	if cfg.Max < 0 {
+		return throttle.Rule{}, fmt.Errorf("窗口内最多发送条数不能小于 0")
+	}
+	return throttle.Rule{Window: window, Max: cfg.Max, Summary: cfg.Summary, Persist: cfg.Persist}, nil
+}
+
+// noteRepeated 在消息中报告被抑制的重复次数
+func noteRepeated(message *notifier.NotificationMessage, repeated int) {
+	note := fmt.Sprintf("（重复 %d 次）", repeated)
+	if message.Content == "" {
+		message.Content = note
+		return
+	}
+	message.Content += "\n\n" + note
+}
+
+// throttleLoop 定期发送窗口已结束的汇总消息
+func (app *NotificationApp) throttleLoop() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.done:
+			return
+		case <-ticker.C:
+			app.flushThrottled()
+		}
+	}
+}
+
+// flushThrottled 用最近一条被抑制消息的请求数据重新处理并发送汇总，不再检查限流
+func (app *NotificationApp) flushThrottled() {
+	for _, s := range app.throttler.Due() {
+		appConfig, exists := app.configManager.GetConfig().NotificationApps[s.AppID]
+		if !exists || !appConfig.Enabled {
+			continue
+		}
+		payload := s.Payload
+		if payload == nil {
+			payload = map[string]any{}
+		}
+		_, err := app.Send(context.Background(), appConfig, &payload, SendOptions{Async: true, repeated: s.Suppressed})
+		if err != nil {
+			logger.Warn("发送汇总消息失败", "app", s.AppID, "key", s.Key, "repeated", s.Suppressed, "error", err)
+		}
+	}
+}
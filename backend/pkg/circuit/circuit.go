@@ -0,0 +1,193 @@
+// Package circuit 通知服务的熔断器和健康状态：连续失败达到阈值后熔断，冷却期内不再请求服务商，
+// 冷却结束后放行一次探测请求（半开），探测成功恢复，失败则加倍冷却时间
+package circuit
+
+import (
+	"sync"
+	"time"
+)
+
+// State 熔断器状态
+type State string
+
+const (
+	StateClosed   State = "closed"    // 正常
+	StateOpen     State = "open"      // 已熔断，冷却期内不发送
+	StateHalfOpen State = "half-open" // 冷却结束，正在探测
+)
+
+// probeWait 半开状态下等待探测结果时，其它请求推迟的时间
+const probeWait = 5 * time.Second
+
+// Options 熔断配置
+type Options struct {
+	Threshold   int           // 连续失败多少次后熔断，小于 1 表示不熔断
+	Cooldown    time.Duration // 首次熔断的冷却时间
+	MaxCooldown time.Duration // 连续熔断时冷却时间加倍的上限
+}
+
+// Health 通知服务的健康状态
+type Health struct {
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"` // 本次熔断的开始时间
+	RetryAt             *time.Time `json:"retryAt,omitempty"`  // 冷却结束、开始探测的时间
+}
+
+// Breaker 单个通知服务的熔断器
+type Breaker struct {
+	opts Options
+
+	mu       sync.Mutex
+	health   Health
+	cooldown time.Duration
+	probing  bool
+}
+
+func newBreaker(opts Options) *Breaker {
+	return &Breaker{opts: opts, health: Health{State: StateClosed}, cooldown: opts.Cooldown}
+}
+
+// Allow 判断是否可以发送，不可以时返回需要等待的时间；半开状态只放行一个探测请求
+func (b *Breaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.health.State {
+	case StateOpen:
+		now := time.Now()
+		if now.Before(*b.health.RetryAt) {
+			return false, b.health.RetryAt.Sub(now)
+		}
+		b.health.State = StateHalfOpen
+		b.probing = true
+		return true, 0
+	case StateHalfOpen:
+		if b.probing {
+			return false, probeWait
+		}
+		b.probing = true
+		return true, 0
+	}
+	return true, 0
+}
+
+// Success 记录一次成功的发送，恢复正常状态
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.health.State = StateClosed
+	b.health.ConsecutiveFailures = 0
+	b.health.LastSuccessAt = &now
+	b.health.OpenedAt = nil
+	b.health.RetryAt = nil
+	b.cooldown = b.opts.Cooldown
+	b.probing = false
+}
+
+// Failure 记录一次失败的发送，连续失败达到阈值或探测失败时熔断
+func (b *Breaker) Failure(errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.health.ConsecutiveFailures++
+	b.health.LastErrorAt = &now
+	b.health.LastError = errMsg
+
+	switch {
+	case b.health.State == StateHalfOpen:
+		// 探测失败，加倍冷却时间
+		b.cooldown *= 2
+		if b.opts.MaxCooldown > 0 && b.cooldown > b.opts.MaxCooldown {
+			b.cooldown = b.opts.MaxCooldown
+		}
+		b.open(now)
+	case b.health.State == StateClosed && b.opts.Threshold > 0 && b.health.ConsecutiveFailures >= b.opts.Threshold:
+		b.health.OpenedAt = &now
+		b.open(now)
+	}
+	b.probing = false
+}
+
+// Release 放行的请求没有得到成功或失败的结果（例如被限速推迟），允许下一次探测
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// open 进入熔断状态，调用方需持有锁
+func (b *Breaker) open(now time.Time) {
+	retryAt := now.Add(b.cooldown)
+	b.health.State = StateOpen
+	b.health.RetryAt = &retryAt
+}
+
+// Health 返回当前健康状态
+func (b *Breaker) Health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.health
+}
+
+// Registry 按通知服务名称保存熔断器
+type Registry struct {
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+	versions map[string]string
+}
+
+// NewRegistry 创建熔断器注册表
+func NewRegistry(opts Options) *Registry {
+	return &Registry{opts: opts, breakers: make(map[string]*Breaker), versions: make(map[string]string)}
+}
+
+// Get 获取通知服务的熔断器，不存在时创建
+func (r *Registry) Get(name string) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[name]
+	if !ok {
+		b = newBreaker(r.opts)
+		r.breakers[name] = b
+	}
+	return b
+}
+
+// Sync 配置变化后同步：删除已不存在的通知服务，配置版本变化的通知服务重置状态
+func (r *Registry) Sync(versions map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name := range r.breakers {
+		if version, ok := versions[name]; !ok || version != r.versions[name] {
+			delete(r.breakers, name)
+		}
+	}
+	r.versions = versions
+}
+
+// Snapshot 返回所有通知服务的健康状态，names 中尚未发送过的通知服务为正常状态
+func (r *Registry) Snapshot(names []string) map[string]Health {
+	snapshot := make(map[string]Health, len(names))
+	for _, name := range names {
+		r.mu.Lock()
+		b, ok := r.breakers[name]
+		r.mu.Unlock()
+		if ok {
+			snapshot[name] = b.Health()
+		} else {
+			snapshot[name] = Health{State: StateClosed}
+		}
+	}
+	return snapshot
+}
@@ -7,16 +7,9 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// NotifiersType 通知服务类型，具体类型由 notifier 包中的各通知服务注册
 type NotifiersType string
 
-var (
-	WechatWorkAPPBot     NotifiersType = "wechatWorkAPPBot"
-	WechatWorkWebhookBot NotifiersType = "wechatWorkWebhookBot"
-	TelegramAppBot       NotifiersType = "telegramAppBot"
-	DingTalkAppBot       NotifiersType = "dingTalkAppBot"
-	FeishuAppBot         NotifiersType = "feishuAppBot"
-)
-
 // LoggerConfig 日志配置
 type LoggerConfig struct {
 	Level  string `yaml:"level" json:"level"`   // debug, info, warn, error
@@ -33,53 +26,34 @@ type Config struct {
 
 // NotifierInstance 通知服务实例配置
 type NotifierInstance struct {
-	Type    NotifiersType          `yaml:"type" json:"type" binding:"required"`
-	Enabled bool                   `yaml:"enabled" json:"enabled"`
-	Config  map[string]interface{} `yaml:",inline" json:"config"`
-}
-
-// WechatWorkConfig 企业微信应用配置
-type WechatWorkConfig struct {
-	Enabled bool   `yaml:"enabled" json:"enabled"`
-	CorpID  string `yaml:"corp_id" json:"corpId"`
-	AgentID string `yaml:"agent_id" json:"agentId"`
-	Secret  string `yaml:"secret" json:"secret"`
-	Targets string `yaml:"targets" json:"targets"`
-	Proxy   string `yaml:"proxy" json:"proxy"` // 代理服务器地址，格式: http://proxy.example.com:8080
+	Type       NotifiersType          `yaml:"type" json:"type" binding:"required"`
+	Enabled    bool                   `yaml:"enabled" json:"enabled"`
+	QuietHours *QuietHours            `yaml:"quiet_hours,omitempty" json:"quietHours,omitempty"` // 免打扰时段
+	RateLimit  *RateLimit             `yaml:"rate_limit,omitempty" json:"rateLimit,omitempty"`   // 发送限速，为空时使用该类型的默认值
+	Config     map[string]interface{} `yaml:",inline" json:"config"`
 }
 
-// WechatWorkWebhookConfig 企业微信群机器人配置
-type WechatWorkWebhookConfig struct {
-	Enabled bool   `yaml:"enabled" json:"enabled"`
-	Key     string `yaml:"key" json:"key"`     // 群机器人的 key
-	Proxy   string `yaml:"proxy" json:"proxy"` // 代理服务器地址，格式: http://proxy.example.com:8080
+// RateLimit 通知服务的发送限速，超出限制的投递在队列中等待而不是失败
+type RateLimit struct {
+	Rate        string `yaml:"rate,omitempty" json:"rate,omitempty"`               // 整个通知服务的速率，例如 30/s、20/m，0 表示不限制
+	Burst       int    `yaml:"burst,omitempty" json:"burst,omitempty"`             // 允许的突发条数，默认 1
+	PerTarget   string `yaml:"per_target,omitempty" json:"perTarget,omitempty"`    // 每个目标（群、用户）的速率，0 表示不限制
+	Concurrency int    `yaml:"concurrency,omitempty" json:"concurrency,omitempty"` // 同时进行的请求数，0 表示不限制
 }
 
-// TelegramConfig Telegram配置
-type TelegramConfig struct {
-	Enabled  bool   `yaml:"enabled" json:"enabled"`
-	BotToken string `yaml:"bot_token" json:"botToken"`
-	ChatID   string `yaml:"chat_id" json:"chatId"` // 新增chatId字段
-	Proxy    string `yaml:"proxy" json:"proxy"`    // 代理服务器地址，格式: http://proxy.example.com:8080
+// QuietHours 免打扰时段，时段内的消息推迟到时段结束后发送或直接丢弃
+type QuietHours struct {
+	Timezone string        `yaml:"timezone,omitempty" json:"timezone,omitempty"` // 时区，例如 Asia/Shanghai，为空时使用服务器时区
+	Windows  []QuietWindow `yaml:"windows" json:"windows"`
+	Action   string        `yaml:"action,omitempty" json:"action,omitempty"` // defer（默认，推迟发送）或 drop（丢弃）
+	Bypass   string        `yaml:"bypass,omitempty" json:"bypass,omitempty"` // 不低于该级别的消息不受限制，例如 error
 }
 
-// DingTalkConfig 钉钉配置
-type DingTalkConfig struct {
-	Enabled     bool   `yaml:"enabled" json:"enabled"`
-	AccessToken string `yaml:"access_token" json:"accessToken"`
-	Secret      string `yaml:"secret" json:"secret"`
-	Targets     string `yaml:"targets" json:"targets"`
-	Proxy       string `yaml:"proxy" json:"proxy"` // 代理服务器地址，格式: http://proxy.example.com:8080
-}
-
-// FeishuConfig 飞书配置
-type FeishuConfig struct {
-	Enabled   bool   `yaml:"enabled" json:"enabled"`
-	AppID     string `yaml:"app_id" json:"appId"`         // 飞书应用ID
-	AppSecret string `yaml:"app_secret" json:"appSecret"` // 飞书应用密钥
-
-	Targets string `yaml:"targets" json:"targets"` // 默认发送目标(用户ID或群ID)
-	Proxy   string `yaml:"proxy" json:"proxy"`     // 代理服务器地址，格式: http://proxy.example.com:8080
+// QuietWindow 免打扰时段
+type QuietWindow struct {
+	Start string   `yaml:"start" json:"start"`                   // 开始时间 HH:MM
+	End   string   `yaml:"end" json:"end"`                       // 结束时间 HH:MM，不大于开始时间时表示跨天
+	Days  []string `yaml:"days,omitempty" json:"days,omitempty"` // 生效的星期（mon、tue...），按开始时间所在的日期判断，为空表示每天
 }
 
 // NotificationApp 通知应用配置
@@ -93,6 +67,106 @@ type NotificationApp struct {
 	PluginID     string   `yaml:"plugin_id,omitempty" json:"pluginId,omitempty"` // 关联的插件ID
 	DefaultImage string   `yaml:"default_image" json:"defaultImage"`             // 默认图片URL
 	Auth         *AppAuth `yaml:"auth,omitempty" json:"auth,omitempty"`          // 可选字段
+	// Routes 按顺序匹配的路由规则，没有规则匹配时使用 Notifiers 和 TemplateID（默认路由）
+	Routes []AppRoute `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// Filter 在插件和模板处理之前按原始请求数据丢弃消息
+	Filter *AppFilter `yaml:"filter,omitempty" json:"filter,omitempty"`
+	// Throttle 去重与限流，为空时不限制
+	Throttle *AppThrottle `yaml:"throttle,omitempty" json:"throttle,omitempty"`
+	// Digest 汇总发送，为空时逐条发送
+	Digest *AppDigest `yaml:"digest,omitempty" json:"digest,omitempty"`
+	// QuietHours 免打扰时段，对应用的所有通知服务生效
+	QuietHours *QuietHours `yaml:"quiet_hours,omitempty" json:"quietHours,omitempty"`
+	// Fallback 故障转移：发送到的通知服务首次尝试全部失败时，依次发送到的备用通知服务组
+	Fallback [][]string `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	// Escalation 升级：消息发送后在指定时间内未确认时依次通知的通知服务
+	Escalation []EscalationStep `yaml:"escalation,omitempty" json:"escalation,omitempty"`
+}
+
+// EscalationStep 升级步骤
+type EscalationStep struct {
+	After     string   `yaml:"after" json:"after"`         // 消息发送后多久仍未确认时通知，例如 15m
+	Notifiers []string `yaml:"notifiers" json:"notifiers"` // 通知的通知服务
+}
+
+// AppDigest 通知应用的汇总配置：缓冲渲染后的消息，超时或条数达到上限时合并为一条发送
+type AppDigest struct {
+	Key        string `yaml:"key,omitempty" json:"key,omitempty"`                // 分组键，支持模板语法，为空时应用的所有消息合并
+	MaxWait    string `yaml:"max_wait" json:"maxWait"`                           // 第一条消息进入缓冲后最多等待的时间，例如 5m
+	MaxItems   int    `yaml:"max_items,omitempty" json:"maxItems,omitempty"`     // 条数达到后立即发送，0 表示不限制
+	TemplateID string `yaml:"template_id,omitempty" json:"templateId,omitempty"` // 汇总消息模板，数据为 items、count、key，为空时列出各条消息的标题
+}
+
+// AppThrottle 通知应用的去重与限流配置
+type AppThrottle struct {
+	Key     string `yaml:"key,omitempty" json:"key,omitempty"`         // 去重键，支持模板语法，为空时使用标题和内容的哈希
+	Window  string `yaml:"window" json:"window"`                       // 时间窗口，例如 10m、1h
+	Max     int    `yaml:"max,omitempty" json:"max,omitempty"`         // 窗口内最多发送的条数，默认 1
+	Summary bool   `yaml:"summary,omitempty" json:"summary,omitempty"` // 窗口结束时汇总发送被抑制的消息，否则在下一条消息中报告
+	Persist bool   `yaml:"persist,omitempty" json:"persist,omitempty"` // 状态持久化，重启后保留
+}
+
+// AppFilter 通知应用的过滤规则，表达式语法与路由规则相同
+type AppFilter struct {
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"` // 配置后只处理匹配任一表达式的请求
+	Deny  []string `yaml:"deny,omitempty" json:"deny,omitempty"`   // 丢弃匹配任一表达式的请求，优先于 allow
+}
+
+// AppRoute 通知应用的路由规则
+type AppRoute struct {
+	Name       string   `yaml:"name,omitempty" json:"name"`
+	Match      string   `yaml:"match,omitempty" json:"match"`            // 匹配表达式，为空时总是匹配
+	Notifiers  []string `yaml:"notifiers" json:"notifiers"`              // 匹配后发送的通知服务
+	Targets    string   `yaml:"targets,omitempty" json:"targets"`        // 覆盖模板渲染的目标，支持模板语法，多个用逗号分隔
+	TemplateID string   `yaml:"template_id,omitempty" json:"templateId"` // 覆盖应用的模板
+	Continue   bool     `yaml:"continue,omitempty" json:"continue"`      // 匹配后继续匹配后续规则，默认停止
+}
+
+// UsesNotifier 应用（包括路由规则、故障转移和升级）是否使用了指定的通知服务
+func (app NotificationApp) UsesNotifier(name string) bool {
+	for _, n := range app.Notifiers {
+		if n == name {
+			return true
+		}
+	}
+	for _, route := range app.Routes {
+		for _, n := range route.Notifiers {
+			if n == name {
+				return true
+			}
+		}
+	}
+	for _, group := range app.Fallback {
+		for _, n := range group {
+			if n == name {
+				return true
+			}
+		}
+	}
+	for _, step := range app.Escalation {
+		for _, n := range step.Notifiers {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UsesTemplate 应用（包括路由规则和汇总）是否使用了指定的模板
+func (app NotificationApp) UsesTemplate(templateID string) bool {
+	if app.TemplateID == templateID {
+		return true
+	}
+	if app.Digest != nil && app.Digest.TemplateID == templateID {
+		return true
+	}
+	for _, route := range app.Routes {
+		if route.TemplateID == templateID {
+			return true
+		}
+	}
+	return false
 }
 
 // AppAuth 通知应用的认证配置
@@ -110,6 +184,24 @@ type MessageTemplate struct {
 	Image   string `yaml:"image" json:"image"`     // 图片
 	URL     string `yaml:"url" json:"url"`         // 链接
 	Targets string `yaml:"targets" json:"targets"` // 目标
+
+	Format  string           `yaml:"format,omitempty" json:"format"`   // 内容格式：plain/markdown/html，为空时由通知服务决定
+	Level   string           `yaml:"level,omitempty" json:"level"`     // 消息级别：info/success/warning/error/critical
+	Images  string           `yaml:"images,omitempty" json:"images"`   // 更多图片，多个用逗号或换行分隔
+	Actions []TemplateAction `yaml:"actions,omitempty" json:"actions"` // 按钮
+	Fields  []TemplateField  `yaml:"fields,omitempty" json:"fields"`   // 键值字段
+}
+
+// TemplateAction 模板中的按钮，名称和链接均支持模板语法
+type TemplateAction struct {
+	Label string `yaml:"label" json:"label"`
+	URL   string `yaml:"url" json:"url"`
+}
+
+// TemplateField 模板中的键值字段，键和值均支持模板语法
+type TemplateField struct {
+	Key   string `yaml:"key" json:"key"`
+	Value string `yaml:"value" json:"value"`
 }
 
 // ConfigManager 配置管理器
@@ -222,7 +314,7 @@ func (cm *ConfigManager) DeleteTemplate(templateID string) error {
 func (cm *ConfigManager) GetAppsUsingTemplate(templateID string) []string {
 	var apps []string
 	for appID, app := range cm.config.NotificationApps {
-		if app.TemplateID == templateID {
+		if app.UsesTemplate(templateID) {
 			apps = append(apps, appID)
 		}
 	}
@@ -356,11 +448,8 @@ func (cm *ConfigManager) GetAppsUsingNotifier(notifierName string) []string {
 
 	appsUsingNotifier := []string{}
 	for appName, appConfig := range cm.config.NotificationApps {
-		for _, notifier := range appConfig.Notifiers {
-			if notifier == notifierName {
-				appsUsingNotifier = append(appsUsingNotifier, appName)
-				break
-			}
+		if appConfig.UsesNotifier(notifierName) {
+			appsUsingNotifier = append(appsUsingNotifier, appName)
 		}
 	}
 	return appsUsingNotifier
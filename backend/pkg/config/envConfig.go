@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
@@ -16,6 +18,33 @@ type EnvConfig struct {
 	PORT            string `default:":7879"`
 	STATIC_DIR      string `default:"/app/static"`
 	PLUGINS_DIR     string `default:"/config/plugins"`
+	// 数据目录（出站队列等持久化数据），为空时使用配置文件所在目录下的 data 目录
+	DATA_DIR            string
+	OUTBOX_WORKERS      int `default:"10"`
+	OUTBOX_MAX_ATTEMPTS int `default:"8"`
+	// 熔断：通知服务连续失败多少次后暂停发送（0 表示不熔断），首次冷却时间和连续熔断时的最长冷却时间
+	CIRCUIT_FAILURE_THRESHOLD int           `default:"5"`
+	CIRCUIT_COOLDOWN          time.Duration `default:"1m"`
+	CIRCUIT_MAX_COOLDOWN      time.Duration `default:"30m"`
+	// 消息历史保留策略，0 表示不按该维度清理
+	HISTORY_MAX_AGE   time.Duration `default:"720h"`
+	HISTORY_MAX_COUNT int           `default:"10000"`
+	// 外部访问地址（例如 https://notify.example.com），配置后内网图片会缓存并替换为 /api/v1/media 的签名链接
+	EXTERNAL_URL string
+	// 图片缓存：链接有效期、缓存总大小、单张图片大小和最长边像素（超出时压缩或缩小，0 表示不限制）
+	MEDIA_SECRET        string
+	MEDIA_URL_TTL       time.Duration `default:"168h"`
+	MEDIA_CACHE_MAX_MB  int           `default:"512"`
+	MEDIA_MAX_IMAGE_MB  int           `default:"5"`
+	MEDIA_MAX_DIMENSION int           `default:"0"`
+}
+
+// DataDir 获取数据目录
+func (c *EnvConfig) DataDir(configFile string) string {
+	if c.DATA_DIR != "" {
+		return c.DATA_DIR
+	}
+	return filepath.Join(filepath.Dir(configFile), "data")
 }
 
 func NewEnvConfig() *EnvConfig {
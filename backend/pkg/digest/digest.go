@@ -0,0 +1,114 @@
+// Package digest 消息汇总：按分组键缓冲渲染后的消息，等待超时或条数达到上限时合并为一条发送。
+// 缓冲区写入数据库，进程重启后继续等待
+package digest
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/store"
+)
+
+const bucketDigest = "digest"
+
+// Item 缓冲的单条消息
+type Item struct {
+	MessageID string                        `json:"messageId"` // 消息历史中的记录ID
+	Message   *notifier.NotificationMessage `json:"message"`
+	Targets   []string                      `json:"targets"`
+	Payload   map[string]any                `json:"payload,omitempty"`
+	CreatedAt time.Time                     `json:"createdAt"`
+}
+
+// Batch 一个分组的缓冲消息
+type Batch struct {
+	AppID     string    `json:"appId"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"createdAt"`
+	Deadline  time.Time `json:"deadline"` // 第一条消息进入缓冲后最多等待到该时间
+	Items     []Item    `json:"items"`
+}
+
+// Buffer 汇总缓冲区
+type Buffer struct {
+	store *store.Store
+
+	mu      sync.Mutex
+	batches map[string]*Batch
+}
+
+// New 创建缓冲区并加载上次未发送的消息
+func New(st *store.Store) *Buffer {
+	b := &Buffer{store: st, batches: make(map[string]*Batch)}
+	err := st.ForEach(bucketDigest, func(key string, data []byte) error {
+		var batch Batch
+		if err := json.Unmarshal(data, &batch); err != nil {
+			logger.Warn("解析汇总缓冲失败", "key", key, "error", err)
+			return nil
+		}
+		b.batches[key] = &batch
+		return nil
+	})
+	if err != nil {
+		logger.Error("加载汇总缓冲失败", "error", err)
+	}
+	return b
+}
+
+func batchKey(appID, key string) string {
+	return appID + "/" + key
+}
+
+// Add 把消息加入分组，条数达到 maxItems（大于 0 时）时取出并返回整个分组
+func (b *Buffer) Add(appID, key string, item Item, maxWait time.Duration, maxItems int) *Batch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := batchKey(appID, key)
+	batch, ok := b.batches[id]
+	if !ok {
+		now := time.Now()
+		batch = &Batch{AppID: appID, Key: key, CreatedAt: now, Deadline: now.Add(maxWait)}
+		b.batches[id] = batch
+	}
+	batch.Items = append(batch.Items, item)
+
+	if maxItems > 0 && len(batch.Items) >= maxItems {
+		b.remove(id)
+		return batch
+	}
+	if err := b.store.Put(bucketDigest, id, batch); err != nil {
+		logger.Warn("保存汇总缓冲失败", "key", id, "error", err)
+	}
+	return nil
+}
+
+// Due 取出等待已超时的分组，all 为 true 时取出全部分组（关闭时使用）
+func (b *Buffer) Due(all bool) []Batch {
+	now := time.Now()
+	var due []Batch
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, batch := range b.batches {
+		if !all && now.Before(batch.Deadline) {
+			continue
+		}
+		due = append(due, *batch)
+		b.remove(id)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	return due
+}
+
+// remove 删除分组，调用方需持有锁
+func (b *Buffer) remove(id string) {
+	delete(b.batches, id)
+	if err := b.store.Delete(bucketDigest, id); err != nil {
+		logger.Warn("删除汇总缓冲失败", "key", id, "error", err)
+	}
+}
@@ -0,0 +1,652 @@
+// Package expr 路由规则和过滤规则使用的条件表达式
+//
+// 语法示例：
+//
+//	severity == "error"
+//	Event startsWith "library." && Item.Type != "Episode"
+//	fields.hostname in ["pve1", "pve2"] or not (level in ["info", "success"])
+//	title matches "(?i)backup (failed|error)"
+//
+// 支持的运算：== != > >= < <=、startsWith、endsWith、contains、matches（正则）、in、not in，
+// 逻辑运算 && || !（也可以写作 and or not）以及括号。
+// 标识符按 "." 分隔的路径在数据中查找（键名先精确匹配，再忽略大小写匹配），不存在时为 null；
+// 比较时两边都是数字（或数字字符串）按数值比较，否则按字符串比较。
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Expr 编译后的表达式
+type Expr struct {
+	src  string
+	root node
+}
+
+// String 返回表达式源码
+func (e *Expr) String() string {
+	return e.src
+}
+
+var cache sync.Map // 源码 -> *Expr
+
+// Compile 编译表达式，相同的表达式只编译一次
+func Compile(src string) (*Expr, error) {
+	if e, ok := cache.Load(src); ok {
+		return e.(*Expr), nil
+	}
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("表达式 %q 错误: %w", src, err)
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("表达式 %q 错误: 位置 %d 处多余的 %q", src, p.tok.pos, p.tok.text)
+	}
+	e := &Expr{src: src, root: root}
+	cache.Store(src, e)
+	return e, nil
+}
+
+// Match 编译并判断表达式是否成立，空表达式总是成立
+func Match(src string, env map[string]any) (bool, error) {
+	if strings.TrimSpace(src) == "" {
+		return true, nil
+	}
+	e, err := Compile(src)
+	if err != nil {
+		return false, err
+	}
+	return e.Match(env)
+}
+
+// Match 判断表达式在给定数据上是否成立
+func (e *Expr) Match(env map[string]any) (bool, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, fmt.Errorf("表达式 %q 求值失败: %w", e.src, err)
+	}
+	return truthy(v), nil
+}
+
+// ===== 词法分析 =====
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp     // 比较和逻辑运算符
+	tokLParen // (
+	tokRParen // )
+	tokLBrack // [
+	tokRBrack // ]
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{tokLParen, "(", start}, nil
+	case c == ')':
+		l.pos++
+		return token{tokRParen, ")", start}, nil
+	case c == '[':
+		l.pos++
+		return token{tokLBrack, "[", start}, nil
+	case c == ']':
+		l.pos++
+		return token{tokRBrack, "]", start}, nil
+	case c == ',':
+		l.pos++
+		return token{tokComma, ",", start}, nil
+	case c == '"' || c == '\'':
+		return l.readString(c)
+	case c >= '0' && c <= '9' || c == '-' && l.pos+1 < len(l.src) && l.src[l.pos+1] >= '0' && l.src[l.pos+1] <= '9':
+		l.pos++
+		for l.pos < len(l.src) && (l.src[l.pos] >= '0' && l.src[l.pos] <= '9' || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{tokNumber, l.src[start:l.pos], start}, nil
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{tokIdent, l.src[start:l.pos], start}, nil
+	}
+
+	for _, op := range []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "!", "="} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			if op == "=" {
+				op = "=="
+			}
+			return token{tokOp, op, start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("位置 %d 处无法识别的字符 %q", start, c)
+}
+
+func (l *lexer) readString(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == quote:
+			l.pos++
+			return token{tokString, sb.String(), start}, nil
+		case c == '\\' && l.pos+1 < len(l.src):
+			l.pos++
+			switch e := l.src[l.pos]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+		l.pos++
+	}
+	return token{}, fmt.Errorf("位置 %d 处的字符串没有结束", start)
+}
+
+// ===== 语法分析 =====
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+	if p.err != nil {
+		p.tok = token{kind: tokEOF, pos: p.lex.pos}
+	}
+}
+
+// keyword 判断当前 token 是否为指定关键字（忽略大小写）
+func (p *parser) keyword(words ...string) bool {
+	if p.tok.kind != tokIdent {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(p.tok.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && (p.tok.kind == tokOp && p.tok.text == "||" || p.keyword("or")) {
+		p.next()
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left = &logicNode{and: false, left: left, right: right}
+		}
+	}
+	return left, p.check(err)
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	for err == nil && (p.tok.kind == tokOp && p.tok.text == "&&" || p.keyword("and")) {
+		p.next()
+		var right node
+		if right, err = p.parseNot(); err == nil {
+			left = &logicNode{and: true, left: left, right: right}
+		}
+	}
+	return left, p.check(err)
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.tok.kind == tokOp && p.tok.text == "!" || p.keyword("not") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+// compareSymbols 符号形式的比较运算符
+var compareSymbols = map[string]bool{"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true}
+
+// compareOps 关键字形式的比较运算符，键为小写
+var compareOps = map[string]string{
+	"startswith": "startsWith",
+	"endswith":   "endsWith",
+	"contains":   "contains",
+	"matches":    "matches",
+	"in":         "in",
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	var op string
+	switch {
+	case p.tok.kind == tokOp && compareSymbols[p.tok.text]:
+		op = p.tok.text
+	case p.tok.kind == tokIdent && compareOps[strings.ToLower(p.tok.text)] != "":
+		op = compareOps[strings.ToLower(p.tok.text)]
+	case p.keyword("not"):
+		// not in / not contains 等
+		p.next()
+		inner, ok := compareOps[strings.ToLower(p.tok.text)]
+		if p.tok.kind != tokIdent || !ok {
+			return nil, fmt.Errorf("位置 %d 处 not 后缺少 in、contains 等运算符", p.tok.pos)
+		}
+		p.next()
+		right, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: &compareNode{op: inner, left: left, right: right}}, nil
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	n := &compareNode{op: op, left: left, right: right}
+	if op == "matches" {
+		// 常量正则在编译表达式时编译并保存在节点中，动态正则每次求值时编译
+		if lit, ok := right.(*literalNode); ok {
+			if n.re, err = compileRegexp(toString(lit.value)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseValue() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokString:
+		p.next()
+		return &literalNode{value: tok.text}, nil
+	case tokNumber:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d 处无效的数字 %q", tok.pos, tok.text)
+		}
+		return &literalNode{value: f}, nil
+	case tokIdent:
+		p.next()
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return &pathNode{path: strings.Split(tok.text, ".")}, nil
+	case tokLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, fmt.Errorf("位置 %d 处缺少 )", p.tok.pos)
+		}
+		p.next()
+		return inner, nil
+	case tokLBrack:
+		p.next()
+		list := &listNode{}
+		for p.tok.kind != tokRBrack {
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, item)
+			if p.tok.kind == tokComma {
+				p.next()
+			} else if p.tok.kind != tokRBrack {
+				return nil, fmt.Errorf("位置 %d 处缺少 ] 或 ,", p.tok.pos)
+			}
+		}
+		p.next()
+		return list, nil
+	case tokEOF:
+		return nil, fmt.Errorf("表达式不完整")
+	}
+	return nil, fmt.Errorf("位置 %d 处不应出现 %q", tok.pos, tok.text)
+}
+
+func (p *parser) check(err error) error {
+	if err != nil {
+		return err
+	}
+	return p.err
+}
+
+// ===== 求值 =====
+
+type node interface {
+	eval(env map[string]any) (any, error)
+}
+
+type literalNode struct{ value any }
+
+func (n *literalNode) eval(map[string]any) (any, error) { return n.value, nil }
+
+type pathNode struct{ path []string }
+
+func (n *pathNode) eval(env map[string]any) (any, error) {
+	return Lookup(env, n.path), nil
+}
+
+type listNode struct{ items []node }
+
+func (n *listNode) eval(env map[string]any) (any, error) {
+	values := make([]any, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type notNode struct{ operand node }
+
+func (n *notNode) eval(env map[string]any) (any, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicNode) eval(env map[string]any) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// 短路求值
+	if truthy(left) != n.and {
+		return truthy(left), nil
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp // matches 右边为常量时预先编译的正则
+}
+
+func (n *compareNode) eval(env map[string]any) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case ">", ">=", "<", "<=":
+		if left == nil || right == nil {
+			return false, nil
+		}
+		c := compare(left, right)
+		switch n.op {
+		case ">":
+			return c > 0, nil
+		case ">=":
+			return c >= 0, nil
+		case "<":
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	case "startsWith":
+		return left != nil && strings.HasPrefix(toString(left), toString(right)), nil
+	case "endsWith":
+		return left != nil && strings.HasSuffix(toString(left), toString(right)), nil
+	case "contains":
+		if list, ok := left.([]any); ok {
+			return inList(right, list), nil
+		}
+		return left != nil && strings.Contains(toString(left), toString(right)), nil
+	case "matches":
+		re := n.re
+		if re == nil {
+			if re, err = compileRegexp(toString(right)); err != nil {
+				return nil, err
+			}
+		}
+		return left != nil && re.MatchString(toString(left)), nil
+	case "in":
+		if list, ok := right.([]any); ok {
+			return inList(left, list), nil
+		}
+		return left != nil && strings.Contains(toString(right), toString(left)), nil
+	}
+	return nil, fmt.Errorf("未知的运算符 %s", n.op)
+}
+
+// compileRegexp 编译正则；不做全局缓存，否则来自请求数据的动态正则会使缓存无限增长
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("无效的正则表达式 %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// Lookup 按路径查找值，键名先精确匹配再忽略大小写匹配，数组可以用数字下标
+func Lookup(data any, path []string) any {
+	current := data
+	for _, key := range path {
+		switch v := current.(type) {
+		case map[string]any:
+			value, ok := v[key]
+			if !ok {
+				for k, val := range v {
+					if strings.EqualFold(k, key) {
+						value, ok = val, true
+						break
+					}
+				}
+			}
+			if !ok {
+				return nil
+			}
+			current = value
+		case map[string]string:
+			value, ok := v[key]
+			if !ok {
+				return nil
+			}
+			current = value
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			current = v[i]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// truthy 判断值是否为真：null、false、空字符串、"false"、0 和空集合为假
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != "" && !strings.EqualFold(x, "false") && x != "0"
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	if f, ok := toNumber(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// toNumber 转换为数值，字符串只有完整的数字才能转换
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	if x, ok := a.(bool); ok {
+		return x == truthy(b)
+	}
+	if y, ok := b.(bool); ok {
+		return y == truthy(a)
+	}
+	return toString(a) == toString(b)
+}
+
+func compare(a, b any) int {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+func inList(v any, list []any) bool {
+	for _, item := range list {
+		if equal(v, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+var testEnv = map[string]any{
+	"severity": "error",
+	"title":    "Backup FAILED on nas",
+	"count":    float64(12),
+	"port":     "8080",
+	"enabled":  true,
+	"Event":    "library.new",
+	"Item":     map[string]any{"Type": "Movie", "Name": "Dune"},
+	"fields":   map[string]any{"hostname": "pve1"},
+	"tags":     []any{"prod", "db"},
+	"pattern":  "^Backup",
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// 比较运算
+		{`severity == "error"`, true},
+		{`severity != "error"`, false},
+		{`count > 10`, true},
+		{`count <= 10`, false},
+		{`port == 8080`, true},
+		{`port > 100`, true},
+		{`enabled == true`, true},
+		{`enabled`, true},
+		{`Event startsWith "library."`, true},
+		{`Event endsWith ".new"`, true},
+		{`title contains "FAILED"`, true},
+		{`tags contains "db"`, true},
+		{`fields.hostname in ["pve1", "pve2"]`, true},
+		{`fields.hostname not in ["pve1", "pve2"]`, false},
+		{`Item.Type != "Episode"`, true},
+		{`tags.0 == "prod"`, true},
+
+		// 键名先精确匹配，再忽略大小写匹配；关键字忽略大小写
+		{`item.name == "Dune"`, true},
+		{`SEVERITY == "error" AND Count > 1`, true},
+
+		// 正则：常量和来自数据的动态正则
+		{`title matches "(?i)backup (failed|error)"`, true},
+		{`title matches "^failed"`, false},
+		{`title matches pattern`, true},
+
+		// 缺少的字段为 null：相等比较为假，不等比较为真，大小比较和字符串运算都为假
+		{`missing == "x"`, false},
+		{`missing != "x"`, true},
+		{`missing == null`, true},
+		{`missing > 0`, false},
+		{`missing < 0`, false},
+		{`missing startsWith ""`, false},
+		{`missing contains ""`, false},
+		{`missing matches ".*"`, false},
+		{`missing in ["a"]`, false},
+		{`Item.Missing.Deep == null`, true},
+		{`not missing`, true},
+	}
+	for _, tt := range tests {
+		got, err := Match(tt.src, testEnv)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// && 优先于 ||
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+		{`false && false || true`, true},
+		// ! 只作用于紧跟的比较
+		{`!severity == "info"`, true},
+		{`not severity == "error" or count > 10`, true},
+		{`not (severity == "error" or count > 10)`, false},
+		{`fields.hostname in ["pve1", "pve2"] or not (severity in ["info", "success"])`, true},
+	}
+	for _, tt := range tests {
+		got, err := Match(tt.src, testEnv)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyExpressionMatches(t *testing.T) {
+	for _, src := range []string{"", "   "} {
+		if ok, err := Match(src, testEnv); err != nil || !ok {
+			t.Errorf("空表达式应总是成立: %q -> %v, %v", src, ok, err)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{`severity ==`, "不完整"},
+		{`(severity == "error"`, "缺少 )"},
+		{`severity in ["a" "b"]`, "缺少 ] 或 ,"},
+		{`severity == "error" extra`, "多余"},
+		{`title matches "(unclosed"`, "无效的正则表达式"},
+		{`severity not == "x"`, "not 后缺少"},
+		{`"unterminated`, ""},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		if err == nil {
+			t.Errorf("%s: 应编译失败", tt.src)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: 错误 %q 应包含 %q", tt.src, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDynamicRegexpError(t *testing.T) {
+	env := map[string]any{"title": "x", "pattern": "(bad"}
+	if _, err := Match(`title matches pattern`, env); err == nil {
+		t.Error("来自数据的无效正则应在求值时返回错误")
+	}
+}
+
+func TestCompileCachesExpression(t *testing.T) {
+	a, err := Compile(`severity == "error"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Compile(`severity == "error"`)
+	if a != b {
+		t.Error("相同的表达式应只编译一次")
+	}
+	if a.String() != `severity == "error"` {
+		t.Errorf("String() = %q", a.String())
+	}
+}
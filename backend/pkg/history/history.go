@@ -0,0 +1,387 @@
+package history
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/outbox"
+	"github.com/jianxcao/notify/backend/pkg/store"
+)
+
+const bucketRecords = "history_records"
+
+// 单个投递的状态
+const (
+	DeliveryQueued    = "queued"    // 排队中，尚未尝试
+	DeliveryScheduled = "scheduled" // 定时发送或免打扰推迟，等待发送时间
+	DeliveryDropped   = "dropped"   // 免打扰时段内丢弃
+	DeliveryCancelled = "cancelled" // 已取消（故障转移到下一组或消息已确认）
+	DeliverySending   = "sending"   // 发送中
+	DeliveryRetrying  = "retrying"  // 发送失败，等待重试
+	DeliverySent      = "sent"      // 已发送
+	DeliveryFailed    = "failed"    // 最终失败
+)
+
+// 消息整体状态
+const (
+	MessagePending   = "pending"   // 仍有投递未完成
+	MessageSent      = "sent"      // 全部发送成功
+	MessagePartial   = "partial"   // 部分发送成功
+	MessageFailed    = "failed"    // 全部失败
+	MessageSkipped   = "skipped"   // 插件判定无需通知
+	MessageFiltered  = "filtered"  // 被应用的过滤规则丢弃
+	MessageThrottled = "throttled" // 被去重或限流抑制
+	MessageBatched   = "batched"   // 已加入汇总缓冲，与其它消息合并发送
+	MessageDropped   = "dropped"   // 免打扰时段内丢弃
+	MessageError     = "error"     // 处理失败（模板渲染、插件处理等），未进入发送队列
+)
+
+// DeliveryStatus 单个通知服务、单个目标的投递状态
+type DeliveryStatus struct {
+	ID            string           `json:"id,omitempty"` // 出站队列中的投递ID，未进入队列时为空
+	Notifier      string           `json:"notifier"`
+	Route         string           `json:"route,omitempty"`      // 匹配的路由规则名称，默认路由为空
+	Stage         int              `json:"stage,omitempty"`      // 故障转移的阶段，0 为首次发送
+	Escalation    int              `json:"escalation,omitempty"` // 升级的步骤，0 表示不是升级通知
+	Targets       []string         `json:"targets"`
+	Status        string           `json:"status"`
+	Attempts      int              `json:"attempts"`
+	Error         string           `json:"error,omitempty"`
+	Results       notifier.Results `json:"results,omitempty"` // 各目标的发送结果
+	CreatedAt     time.Time        `json:"createdAt"`
+	UpdatedAt     time.Time        `json:"updatedAt"`
+	SentAt        *time.Time       `json:"sentAt,omitempty"`
+	NextAttemptAt *time.Time       `json:"nextAttemptAt,omitempty"`
+}
+
+// Record 一次通知请求的完整记录
+type Record struct {
+	ID         string                        `json:"id"`
+	AppID      string                        `json:"appId"`
+	AppName    string                        `json:"appName"`
+	Status     string                        `json:"status"`
+	Error      string                        `json:"error,omitempty"`
+	Filter     string                        `json:"filter,omitempty"`     // 丢弃消息的过滤规则
+	DedupKey   string                        `json:"dedupKey,omitempty"`   // 去重键
+	Repeated   int                           `json:"repeated,omitempty"`   // 本条消息报告的被抑制条数
+	DigestKey  string                        `json:"digestKey,omitempty"`  // 汇总分组键
+	Items      []string                      `json:"items,omitempty"`      // 汇总消息包含的消息ID
+	AckToken   string                        `json:"ackToken,omitempty"`   // 确认链接的令牌，配置了升级时生成
+	AckedAt    *time.Time                    `json:"ackedAt,omitempty"`    // 确认时间
+	AckedBy    string                        `json:"ackedBy,omitempty"`    // 确认人
+	Payload    map[string]any                `json:"payload,omitempty"`    // 原始请求数据
+	PluginID   string                        `json:"pluginId,omitempty"`   // 处理使用的插件
+	TemplateID string                        `json:"templateId,omitempty"` // 处理使用的模板
+	Message    *notifier.NotificationMessage `json:"message,omitempty"`    // 渲染后的消息
+	Targets    []string                      `json:"targets"`
+	Notifiers  []string                      `json:"notifiers"`
+	ResendOf   string                        `json:"resendOf,omitempty"` // 重发时记录原消息ID
+	CreatedAt  time.Time                     `json:"createdAt"`
+	UpdatedAt  time.Time                     `json:"updatedAt"`
+	Deliveries []DeliveryStatus              `json:"deliveries"`
+}
+
+// Filter 历史记录查询条件
+type Filter struct {
+	AppID    string
+	Notifier string
+	Status   string
+	Keyword  string // 匹配标题或内容
+	From     time.Time
+	To       time.Time
+	Offset   int
+	Limit    int
+}
+
+// match 判断记录是否满足查询条件（时间范围由调用方处理）
+func (f Filter) match(r *Record) bool {
+	if f.AppID != "" && r.AppID != f.AppID {
+		return false
+	}
+	if f.Status != "" && r.Status != f.Status {
+		return false
+	}
+	if f.Notifier != "" {
+		found := false
+		for _, n := range r.Notifiers {
+			if n == f.Notifier {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Keyword != "" {
+		if r.Message == nil {
+			return false
+		}
+		keyword := strings.ToLower(f.Keyword)
+		if !strings.Contains(strings.ToLower(r.Message.Title), keyword) &&
+			!strings.Contains(strings.ToLower(r.Message.Content), keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// History 消息历史存储
+type History struct {
+	store *store.Store
+}
+
+// New 创建消息历史存储
+func New(st *store.Store) *History {
+	return &History{store: st}
+}
+
+// Save 保存（或覆盖）一条记录，并根据投递状态汇总整体状态
+func (h *History) Save(r *Record) error {
+	now := time.Now()
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+	r.UpdatedAt = now
+	if r.Status == "" || r.Status == MessagePending {
+		r.Status = Summarize(r.Deliveries)
+	}
+	return h.store.Put(bucketRecords, r.ID, r)
+}
+
+// Get 获取一条记录
+func (h *History) Get(id string) (*Record, bool, error) {
+	var r Record
+	ok, err := h.store.Get(bucketRecords, id, &r)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return &r, true, nil
+}
+
+// List 按时间倒序查询记录，返回当前页记录和满足条件的总数
+func (h *History) List(f Filter) ([]Record, int, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+
+	records := []Record{}
+	total := 0
+	err := h.store.ForEachReverse(bucketRecords, func(_ string, data []byte) error {
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil
+		}
+		if !f.To.IsZero() && r.CreatedAt.After(f.To) {
+			return nil
+		}
+		if !f.From.IsZero() && r.CreatedAt.Before(f.From) {
+			// 记录按时间倒序排列，之后的都更早
+			return store.ErrStop
+		}
+		if !f.match(&r) {
+			return nil
+		}
+		total++
+		if total > f.Offset && len(records) < f.Limit {
+			// 列表中不返回原始请求数据，需要时查询详情
+			r.Payload = nil
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, total, err
+}
+
+// UpdateDelivery 根据出站队列的投递结果更新记录
+func (h *History) UpdateDelivery(d outbox.Delivery) error {
+	return h.store.Update(func(tx *store.Tx) error {
+		var r Record
+		ok, err := tx.Get(bucketRecords, d.MessageID, &r)
+		if err != nil || !ok {
+			return err
+		}
+		status := NewDeliveryStatus(d)
+		replaced := false
+		for i := range r.Deliveries {
+			if r.Deliveries[i].ID == d.ID {
+				if r.Deliveries[i].UpdatedAt.After(status.UpdatedAt) {
+					// 回调乱序到达（例如取消先于发送结果同步），保留较新的状态
+					return nil
+				}
+				r.Deliveries[i] = status
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			r.Deliveries = append(r.Deliveries, status)
+		}
+		r.Status = Summarize(r.Deliveries)
+		r.UpdatedAt = time.Now()
+		return tx.Put(bucketRecords, r.ID, r)
+	})
+}
+
+// AppendDeliveries 在记录中追加投递（故障转移、升级），与投递结果的更新互不覆盖
+func (h *History) AppendDeliveries(messageID string, deliveries []DeliveryStatus) error {
+	return h.store.Update(func(tx *store.Tx) error {
+		var r Record
+		ok, err := tx.Get(bucketRecords, messageID, &r)
+		if err != nil || !ok {
+			return err
+		}
+		r.Deliveries = append(r.Deliveries, deliveries...)
+		r.Status = Summarize(r.Deliveries)
+		r.UpdatedAt = time.Now()
+		return tx.Put(bucketRecords, r.ID, r)
+	})
+}
+
+// Ack 确认消息，已确认的消息保持首次确认的时间和确认人；记录不存在时返回 false
+func (h *History) Ack(messageID, by string) (*Record, bool, error) {
+	var r Record
+	var ok bool
+	err := h.store.Update(func(tx *store.Tx) error {
+		var err error
+		ok, err = tx.Get(bucketRecords, messageID, &r)
+		if err != nil || !ok || r.AckedAt != nil {
+			return err
+		}
+		now := time.Now()
+		r.AckedAt = &now
+		r.AckedBy = by
+		r.UpdatedAt = now
+		return tx.Put(bucketRecords, r.ID, r)
+	})
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return &r, true, nil
+}
+
+// Prune 按保留时长和数量清理记录，返回被删除的记录ID；仍有投递未完成的记录不会被清理
+func (h *History) Prune(maxAge time.Duration, maxCount int) ([]string, error) {
+	var expired []string
+	kept := 0
+	cutoff := time.Now().Add(-maxAge)
+	err := h.store.ForEachReverse(bucketRecords, func(key string, data []byte) error {
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			expired = append(expired, key)
+			return nil
+		}
+		if r.Status == MessagePending {
+			return nil
+		}
+		kept++
+		if (maxCount > 0 && kept > maxCount) || (maxAge > 0 && r.CreatedAt.Before(cutoff)) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil || len(expired) == 0 {
+		return nil, err
+	}
+
+	err = h.store.Update(func(tx *store.Tx) error {
+		for _, id := range expired {
+			if err := tx.Delete(bucketRecords, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return expired, err
+}
+
+// NewDeliveryStatus 将出站队列记录转换为对外展示的状态
+func NewDeliveryStatus(d outbox.Delivery) DeliveryStatus {
+	ds := DeliveryStatus{
+		ID:         d.ID,
+		Notifier:   d.Notifier,
+		Route:      d.Route,
+		Stage:      d.Stage,
+		Escalation: d.Escalation,
+		Targets:    d.Targets,
+		Attempts:   d.Attempts,
+		Error:      d.LastError,
+		Results:    d.Results,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+		SentAt:     d.SentAt,
+	}
+	switch d.State {
+	case outbox.StateSent:
+		ds.Status = DeliverySent
+	case outbox.StateDead:
+		ds.Status = DeliveryFailed
+	case outbox.StateSending:
+		ds.Status = DeliverySending
+	case outbox.StateCancelled:
+		ds.Status = DeliveryCancelled
+	default:
+		ds.Status = DeliveryQueued
+		if d.Attempts > 0 {
+			ds.Status = DeliveryRetrying
+		} else if !d.Throttled && d.NextAttemptAt.After(time.Now()) {
+			// 限速推迟的投递仍为排队状态，只有定时发送的为 scheduled
+			ds.Status = DeliveryScheduled
+		}
+		next := d.NextAttemptAt
+		ds.NextAttemptAt = &next
+	}
+	return ds
+}
+
+// Effective 返回决定消息整体状态的投递：升级通知不计入；
+// 发生故障转移时之前各组失败或被取消的投递已由后一组接替，也不计入
+func Effective(deliveries []DeliveryStatus) []DeliveryStatus {
+	lastStage := 0
+	for _, d := range deliveries {
+		if d.Escalation == 0 && d.Stage > lastStage {
+			lastStage = d.Stage
+		}
+	}
+
+	effective := make([]DeliveryStatus, 0, len(deliveries))
+	for _, d := range deliveries {
+		if d.Escalation > 0 {
+			continue
+		}
+		if d.Stage < lastStage && (d.Status == DeliveryFailed || d.Status == DeliveryCancelled) {
+			continue
+		}
+		effective = append(effective, d)
+	}
+	return effective
+}
+
+// Summarize 根据各投递状态汇总消息整体状态，免打扰丢弃和已取消的投递不计入
+func Summarize(deliveries []DeliveryStatus) string {
+	sent, failed, dropped := 0, 0, 0
+	for _, d := range Effective(deliveries) {
+		switch d.Status {
+		case DeliverySent:
+			sent++
+		case DeliveryFailed:
+			failed++
+		case DeliveryDropped, DeliveryCancelled:
+			dropped++
+		default:
+			return MessagePending
+		}
+	}
+	switch {
+	case dropped > 0 && sent == 0 && failed == 0:
+		return MessageDropped
+	case failed == 0 && sent > 0:
+		return MessageSent
+	case sent == 0:
+		return MessageFailed
+	default:
+		return MessagePartial
+	}
+}
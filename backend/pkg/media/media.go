@@ -0,0 +1,307 @@
+// Package media 图片缓存：保存服务端获取或上传的图片，并通过带签名、会过期的公网链接提供访问，
+// 用于只支持图片地址的渠道（企业微信图文消息、钉钉 feedCard 等）显示内网图片
+package media
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+)
+
+var (
+	// ErrNotFound 图片不存在或已被清理
+	ErrNotFound = errors.New("图片不存在")
+	// ErrInvalidSignature 签名错误
+	ErrInvalidSignature = errors.New("签名无效")
+	// ErrExpired 链接已过期
+	ErrExpired = errors.New("链接已过期")
+)
+
+// idPattern 图片ID为内容哈希，校验格式避免路径穿越
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Options 缓存选项
+type Options struct {
+	BaseURL      string        // 外部访问地址，例如 https://notify.example.com，为空时不改写图片地址
+	Secret       string        // 签名密钥，为空时自动生成并保存在缓存目录
+	TTL          time.Duration // 链接有效期，0 表示不过期
+	MaxSize      int64         // 缓存总大小（字节），超出时按最近访问时间淘汰，0 表示不限制
+	MaxImageSize int           // 单张图片最大字节数，超出时压缩，0 表示不限制
+	MaxDimension int           // 图片最长边的最大像素，超出时缩小，0 表示不缩放
+}
+
+type entry struct {
+	size     int64
+	accessed time.Time
+}
+
+// Cache 图片缓存
+type Cache struct {
+	dir    string
+	opts   Options
+	secret []byte
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	size    int64
+}
+
+// Open 打开缓存目录，加载已有的图片
+func Open(dir string, opts Options) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建图片缓存目录失败: %w", err)
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	c := &Cache{
+		dir:     dir,
+		opts:    opts,
+		entries: make(map[string]*entry),
+	}
+
+	secret, err := c.loadSecret()
+	if err != nil {
+		return nil, err
+	}
+	c.secret = secret
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取图片缓存目录失败: %w", err)
+	}
+	for _, file := range files {
+		if !idPattern.MatchString(file.Name()) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		// 文件修改时间即最近访问时间，访问时会更新
+		c.entries[file.Name()] = &entry{size: info.Size(), accessed: info.ModTime()}
+		c.size += info.Size()
+	}
+	c.mu.Lock()
+	c.evict("")
+	c.mu.Unlock()
+	return c, nil
+}
+
+// loadSecret 读取签名密钥，未配置时使用缓存目录中保存的随机密钥，保证重启后链接仍然有效
+func (c *Cache) loadSecret() ([]byte, error) {
+	if c.opts.Secret != "" {
+		return []byte(c.opts.Secret), nil
+	}
+	path := filepath.Join(c.dir, ".secret")
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		return data, nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+	secret = []byte(hex.EncodeToString(secret))
+	if err := os.WriteFile(path, secret, 0o600); err != nil {
+		return nil, fmt.Errorf("保存签名密钥失败: %w", err)
+	}
+	return secret, nil
+}
+
+// Enabled 是否配置了外部访问地址，未配置时无法生成公网链接
+func (c *Cache) Enabled() bool {
+	return c != nil && c.opts.BaseURL != ""
+}
+
+// Put 按渠道的图片限制缩放和压缩图片后保存，返回图片ID；缓存配置的限制同样生效，两者取较严格的一项。
+// 图片ID由原图内容和限制计算，相同内容和限制的图片只转换、保存一份
+func (c *Cache) Put(img *notifier.ImageData, limits notifier.ImageLimits) (string, error) {
+	limits.MaxBytes = minLimit(limits.MaxBytes, c.opts.MaxImageSize)
+	limits.MaxDimension = minLimit(limits.MaxDimension, c.opts.MaxDimension)
+	id := imageID(img.Data, limits)
+	if c.touch(id) {
+		return id, nil
+	}
+
+	img, err := img.Normalize(limits)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		e.accessed = time.Now()
+		return id, nil
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, id), img.Data, 0o644); err != nil {
+		return "", fmt.Errorf("保存图片失败: %w", err)
+	}
+	size := int64(len(img.Data))
+	c.entries[id] = &entry{size: size, accessed: time.Now()}
+	c.size += size
+	c.evict(id)
+	return id, nil
+}
+
+// imageID 计算原图内容和转换限制的哈希
+func imageID(data []byte, limits notifier.ImageLimits) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d;%d;%s;", limits.MaxBytes, limits.MaxDimension, strings.Join(limits.Formats, ","))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// minLimit 取两个限制中较严格的一个，0 表示不限制
+func minLimit(a, b int) int {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// touch 图片已缓存时更新最近访问时间
+func (c *Cache) touch(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if ok {
+		e.accessed = time.Now()
+	}
+	return ok
+}
+
+// evict 超出缓存大小时淘汰最久未访问的图片，keep 为刚写入、不能淘汰的图片，调用方需持有锁
+func (c *Cache) evict(keep string) {
+	if c.opts.MaxSize <= 0 || c.size <= c.opts.MaxSize {
+		return
+	}
+	ids := make([]string, 0, len(c.entries))
+	for id := range c.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return c.entries[ids[i]].accessed.Before(c.entries[ids[j]].accessed)
+	})
+	for _, id := range ids {
+		if c.size <= c.opts.MaxSize {
+			break
+		}
+		if id == keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, id)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("清理缓存图片失败", "id", id, "error", err)
+			continue
+		}
+		c.size -= c.entries[id].size
+		delete(c.entries, id)
+	}
+}
+
+// Get 读取图片内容和类型，并更新最近访问时间
+func (c *Cache) Get(id string) ([]byte, string, error) {
+	if !idPattern.MatchString(id) {
+		return nil, "", ErrNotFound
+	}
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	if ok {
+		e.accessed = time.Now()
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+
+	path := filepath.Join(c.dir, id)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("读取图片失败: %w", err)
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, http.DetectContentType(data), nil
+}
+
+// sign 计算图片ID和过期时间的签名
+func (c *Cache) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%s.%d", id, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// URL 生成带签名的访问链接
+func (c *Cache) URL(id string) string {
+	var expires int64
+	if c.opts.TTL > 0 {
+		expires = time.Now().Add(c.opts.TTL).Unix()
+	}
+	return fmt.Sprintf("%s/api/v1/media/%s?e=%d&s=%s", c.opts.BaseURL, id, expires, c.sign(id, expires))
+}
+
+// Verify 校验访问链接的签名和有效期，expires 为 0 表示不过期
+func (c *Cache) Verify(id, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(c.sign(id, exp))) {
+		return ErrInvalidSignature
+	}
+	if exp != 0 && time.Now().Unix() > exp {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Rewrite 将消息中渠道无法访问的图片（内网地址、data: URI）按渠道的图片限制转换后保存到缓存，
+// 返回图片替换为公网链接的消息副本，原消息不变；失败的图片保留原地址，由通知服务自行上传
+func (c *Cache) Rewrite(ctx context.Context, message *notifier.NotificationMessage, limits notifier.ImageLimits) *notifier.NotificationMessage {
+	// 预览模式不下载图片，也不写入缓存
+	if !c.Enabled() || notifier.IsDryRun(ctx) {
+		return message
+	}
+	rewrite := func(src string) string {
+		if src == "" || !notifier.NeedsUpload(ctx, src) {
+			return src
+		}
+		img, err := notifier.LoadImage(ctx, src)
+		if err == nil {
+			var id string
+			if id, err = c.Put(img, limits); err == nil {
+				return c.URL(id)
+			}
+		}
+		logger.Warn("缓存图片失败", "image", notifier.AbbreviateImage(src), "error", err)
+		return src
+	}
+
+	rewritten := *message
+	rewritten.Image = rewrite(message.Image)
+	if len(message.Images) > 0 {
+		rewritten.Images = make([]string, len(message.Images))
+		for i, image := range message.Images {
+			rewritten.Images[i] = rewrite(image)
+		}
+	}
+	return &rewritten
+}
@@ -0,0 +1,294 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Bark iOS 推送
+const Bark config.NotifiersType = "bark"
+
+// BarkConfig Bark 配置
+type BarkConfig struct {
+	Enabled     bool   `yaml:"enabled" json:"enabled"`
+	ServerURL   string `yaml:"server_url" json:"serverUrl"`     // 服务器地址，默认 https://api.day.app
+	DeviceKeys  string `yaml:"device_keys" json:"deviceKeys"`   // 默认设备 key，多个用逗号分隔，消息指定了 targets 时发送到 targets
+	Group       string `yaml:"group" json:"group"`              // 通知分组
+	Sound       string `yaml:"sound" json:"sound"`              // 通知铃声，例如 minuet
+	Icon        string `yaml:"icon" json:"icon"`                // 默认通知图标，消息带图片时使用消息的第一张图片
+	EncryptKey  string `yaml:"encrypt_key" json:"encryptKey"`   // 加密密钥，16、24 或 32 个字符，对应 AES-128/192/256，为空时不加密
+	EncryptIV   string `yaml:"encrypt_iv" json:"encryptIv"`     // 加密 IV，16 个字符，CBC 模式必填
+	EncryptMode string `yaml:"encrypt_mode" json:"encryptMode"` // 加密模式: cbc（默认）、ecb
+	Proxy       string `yaml:"proxy" json:"proxy"`              // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(Bark, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg BarkConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewBarkNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "Bark",
+		Description: "通过 Bark 推送到 iOS 设备，消息中的 targets 为设备 key",
+		Fields: []FieldSchema{
+			{Name: "server_url", Label: "服务器地址", Type: "string", Default: "https://api.day.app", Hint: "自建服务器填写自己的地址"},
+			{Name: "device_keys", Label: "设备 Key", Type: "password", Secret: true, Hint: "多个用逗号分隔，消息未指定 targets 时使用"},
+			{Name: "group", Label: "分组", Type: "string", Hint: "可选，通知中心按分组折叠"},
+			{Name: "sound", Label: "铃声", Type: "string", Placeholder: "minuet"},
+			{Name: "icon", Label: "图标地址", Type: "string", Hint: "可选，消息带图片时使用消息的图片"},
+			{Name: "encrypt_key", Label: "加密密钥", Type: "password", Secret: true, Hint: "可选，16、24 或 32 个字符，与 App 中的加密设置一致"},
+			{Name: "encrypt_iv", Label: "加密 IV", Type: "password", Secret: true, Hint: "16 个字符，CBC 模式必填"},
+			{Name: "encrypt_mode", Label: "加密模式", Type: "select", Options: []string{"cbc", "ecb"}, Default: "cbc"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+	})
+}
+
+// BarkNotifier Bark 通知服务
+type BarkNotifier struct {
+	config BarkConfig
+	client *resty.Client
+}
+
+// NewBarkNotifier 创建 Bark 通知服务实例
+func NewBarkNotifier(cfg BarkConfig) *BarkNotifier {
+	if cfg.ServerURL == "" {
+		cfg.ServerURL = "https://api.day.app"
+	}
+	cfg.ServerURL = strings.TrimRight(cfg.ServerURL, "/")
+	if cfg.EncryptMode == "" {
+		cfg.EncryptMode = "cbc"
+	}
+	client := resty.New()
+	client.SetTimeout(30 * time.Second)
+	if cfg.Proxy != "" {
+		client.SetProxy(cfg.Proxy)
+	}
+	return &BarkNotifier{config: cfg, client: supportDryRun(client)}
+}
+
+// Name 返回服务名称
+func (b *BarkNotifier) Name() string {
+	return string(Bark)
+}
+
+// IsEnabled 检查服务是否启用
+func (b *BarkNotifier) IsEnabled() bool {
+	return b.config.Enabled
+}
+
+// Validate 验证配置
+func (b *BarkNotifier) Validate() error {
+	if !b.config.Enabled {
+		return nil
+	}
+	if !strings.HasPrefix(b.config.ServerURL, "http://") && !strings.HasPrefix(b.config.ServerURL, "https://") {
+		return fmt.Errorf("bark 服务器地址必须以 http:// 或 https:// 开头")
+	}
+	if b.config.EncryptKey == "" {
+		return nil
+	}
+	switch len(b.config.EncryptKey) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("bark 加密密钥长度必须为 16、24 或 32 个字符")
+	}
+	switch b.config.EncryptMode {
+	case "cbc":
+		if len(b.config.EncryptIV) != aes.BlockSize {
+			return fmt.Errorf("bark CBC 模式的加密 IV 长度必须为 16 个字符")
+		}
+	case "ecb":
+	default:
+		return fmt.Errorf("bark 不支持的加密模式: %s", b.config.EncryptMode)
+	}
+	return nil
+}
+
+// Limits 消息长度限制：APNs 推送最大 4KB，加密后长度增加约三分之一
+func (b *BarkNotifier) Limits() Limits {
+	return Limits{Text: 2000, Bytes: true}
+}
+
+// Send 发送通知消息，targets 为设备 key，为空时发送到配置的设备
+func (b *BarkNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	if !b.config.Enabled {
+		return resultsFor(targets, time.Now(), "", permanentError("Bark通知服务未启用"))
+	}
+	keys := targets
+	if len(keys) == 0 {
+		keys = splitList(b.config.DeviceKeys)
+	}
+	if len(keys) == 0 {
+		return Results{NewResult("", time.Now(), "", permanentError("未指定设备 key"))}
+	}
+
+	// 图标由设备下载，内网图片和 data: URI 无法使用
+	if remote, uploads := SeparateUploadImages(ctx, message); len(uploads) > 0 {
+		logger.Warn("Bark 无法使用内网图片作为图标", "count", len(uploads))
+		message = remote
+	}
+
+	// 超长正文拆分为多条推送，链接只随最后一条发送
+	parts := SplitMessage(message, b.Limits(), b.buildBody)
+	results := make(Results, 0, len(keys))
+	for _, key := range keys {
+		start := time.Now()
+		_, sent, err := sendParts(ctx, []string{key}, len(parts), func(i int) (string, error) {
+			return "", b.push(ctx, key, b.buildPayload(parts[i]))
+		})
+		results = append(results, partResults([]string{key}, start, "", sent, len(parts), err)...)
+	}
+	return results
+}
+
+// CheckCredentials 检查服务器是否可用；设备 key 只能在推送时校验
+func (b *BarkNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	var result barkResponse
+	resp, err := b.client.R().SetContext(ctx).SetResult(&result).Get(b.config.ServerURL + "/ping")
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return "", barkError(resp)
+	}
+	return fmt.Sprintf("Bark 服务器 %s 可用", b.config.ServerURL), nil
+}
+
+// barkResponse 响应：{"code": 200, "message": "success"}
+type barkResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// barkLevel 根据消息级别返回通知的中断级别：严重时为重要警告（忽略静音和勿扰），错误和警告为时效性通知
+func barkLevel(level Level) string {
+	switch level {
+	case LevelCritical:
+		return "critical"
+	case LevelError, LevelWarning:
+		return "timeSensitive"
+	default:
+		return "active"
+	}
+}
+
+// buildBody 生成推送正文：纯文本内容和字段
+func (b *BarkNotifier) buildBody(message *NotificationMessage) string {
+	var parts []string
+	if content := message.PlainContent(); content != "" {
+		parts = append(parts, content)
+	}
+	if fields := message.FieldsText(""); fields != "" {
+		parts = append(parts, fields)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// buildPayload 生成推送参数，点击通知打开消息链接或第一个按钮的链接
+func (b *BarkNotifier) buildPayload(message *NotificationMessage) map[string]any {
+	payload := map[string]any{
+		"title": message.DisplayTitle(),
+		"body":  b.buildBody(message),
+		"level": barkLevel(message.Level),
+	}
+	if actions := message.AllActions(); len(actions) > 0 {
+		payload["url"] = actions[0].URL
+	}
+	icon := b.config.Icon
+	if images := message.AllImages(); len(images) > 0 {
+		icon = images[0]
+	}
+	if icon != "" {
+		payload["icon"] = icon
+	}
+	if b.config.Group != "" {
+		payload["group"] = b.config.Group
+	}
+	if b.config.Sound != "" {
+		payload["sound"] = b.config.Sound
+	}
+	return payload
+}
+
+// push 推送到一个设备，配置了加密密钥时整个推送参数加密后发送
+func (b *BarkNotifier) push(ctx context.Context, deviceKey string, payload map[string]any) error {
+	var result barkResponse
+	req := b.client.R().SetContext(ctx).SetResult(&result)
+	var resp *resty.Response
+	var err error
+	if b.config.EncryptKey != "" {
+		ciphertext, encErr := b.encrypt(payload)
+		if encErr != nil {
+			return permanentError("加密推送参数失败: %v", encErr)
+		}
+		form := map[string]string{"ciphertext": ciphertext}
+		if b.config.EncryptMode == "cbc" {
+			form["iv"] = b.config.EncryptIV
+		}
+		resp, err = req.SetFormData(form).Post(b.config.ServerURL + "/" + url.PathEscape(deviceKey))
+	} else {
+		payload["device_key"] = deviceKey
+		resp, err = req.SetHeader("Content-Type", "application/json").SetBody(payload).Post(b.config.ServerURL + "/push")
+	}
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return barkError(resp)
+	}
+	if result.Code != 0 && result.Code != 200 {
+		return providerError(result.Code, result.Message, false)
+	}
+	return nil
+}
+
+// encrypt 将推送参数序列化为 JSON 后使用 AES 加密，PKCS7 填充，返回 base64 密文
+func (b *BarkNotifier) encrypt(payload map[string]any) (string, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher([]byte(b.config.EncryptKey))
+	if err != nil {
+		return "", err
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	data := append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	out := make([]byte, len(data))
+	if b.config.EncryptMode == "ecb" {
+		for i := 0; i < len(data); i += aes.BlockSize {
+			block.Encrypt(out[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+		}
+	} else {
+		cipher.NewCBCEncrypter(block, []byte(b.config.EncryptIV)).CryptBlocks(out, data)
+	}
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// barkError 解析错误响应：{"code": 400, "message": "failed to get device token: ..."}
+func barkError(resp *resty.Response) error {
+	pe := httpStatusError(resp)
+	var body barkResponse
+	if json.Unmarshal(resp.Body(), &body) == nil && body.Message != "" {
+		pe.Message += "，" + body.Message
+	}
+	return pe
+}
@@ -0,0 +1,164 @@
+package notifier
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeConfig 将通知服务实例的原始配置解析到带 yaml 标签的配置结构体中
+//
+// 原始配置可能来自 yaml 文件（int、bool 等原生类型）或管理接口的 JSON（数字为 float64），
+// 这里按目标字段类型做宽松转换，例如 agent_id 写成数字也能解析为字符串。
+func DecodeConfig(raw map[string]interface{}, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("配置解析目标必须是结构体指针")
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		value, exists := raw[name]
+		if !exists || value == nil {
+			continue
+		}
+		if err := assignValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("配置项 %s 格式错误: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// assignValue 按目标类型转换并赋值
+func assignValue(dst reflect.Value, value interface{}) error {
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			dst.SetString(v)
+		case float64:
+			dst.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			dst.SetString(fmt.Sprint(v))
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			dst.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			dst.SetBool(b)
+		default:
+			return fmt.Errorf("无法将 %T 转换为布尔值", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := value.(type) {
+		case int:
+			dst.SetInt(int64(v))
+		case int64:
+			dst.SetInt(v)
+		case float64:
+			dst.SetInt(int64(v))
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return nil
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return err
+			}
+			dst.SetInt(n)
+		default:
+			return fmt.Errorf("无法将 %T 转换为整数", value)
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case int:
+			dst.SetFloat(float64(v))
+		case int64:
+			dst.SetFloat(float64(v))
+		case float64:
+			dst.SetFloat(v)
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return nil
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f)
+		default:
+			return fmt.Errorf("无法将 %T 转换为浮点数", value)
+		}
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的配置类型: %s", dst.Type())
+		}
+		var items []string
+		switch v := value.(type) {
+		case string:
+			// 兼容逗号分隔的写法
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		case []string:
+			items = v
+		case []interface{}:
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+		default:
+			return fmt.Errorf("无法将 %T 转换为列表", value)
+		}
+		dst.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		if dst.Type().Key().Kind() != reflect.String || dst.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的配置类型: %s", dst.Type())
+		}
+		result := map[string]string{}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				result[k] = fmt.Sprint(item)
+			}
+		case map[string]string:
+			for k, item := range v {
+				result[k] = item
+			}
+		case string:
+			// 管理界面的多行文本：每行一个 "键: 值" 或 "键=值"
+			for _, line := range strings.Split(v, "\n") {
+				if line = strings.TrimSpace(line); line == "" {
+					continue
+				}
+				i := strings.IndexAny(line, ":=")
+				if i <= 0 {
+					return fmt.Errorf("无法解析键值对 %q，格式应为 键: 值", line)
+				}
+				result[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		default:
+			return fmt.Errorf("无法将 %T 转换为键值对", value)
+		}
+		dst.Set(reflect.ValueOf(result))
+	default:
+		return fmt.Errorf("不支持的配置类型: %s", dst.Type())
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package notifier
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -11,14 +12,54 @@ import (
 	"time"
 
 	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
 	"github.com/jianxcao/notify/backend/pkg/utils"
 
 	"github.com/go-resty/resty/v2"
 )
 
+// DingTalkAppBot 钉钉群机器人
+const DingTalkAppBot config.NotifiersType = "dingTalkAppBot"
+
+// DingTalkConfig 钉钉配置
+type DingTalkConfig struct {
+	Enabled     bool   `yaml:"enabled" json:"enabled"`
+	AccessToken string `yaml:"access_token" json:"accessToken"`
+	Secret      string `yaml:"secret" json:"secret"`
+	Targets     string `yaml:"targets" json:"targets"`
+	AppKey      string `yaml:"app_key" json:"appKey"`       // 可选，用于上传内网图片和 base64 图片
+	AppSecret   string `yaml:"app_secret" json:"appSecret"` // 可选，与 AppKey 一起使用
+	Proxy       string `yaml:"proxy" json:"proxy"`          // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(DingTalkAppBot, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg DingTalkConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewDingTalkNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "钉钉",
+		Description: "在群设置的「智能群助手」中添加自定义机器人，安全设置选择加签，Access Token 为 Webhook 地址中 access_token 参数的值；消息中的 targets 为要 @ 的手机号或用户 ID",
+		Fields: []FieldSchema{
+			{Name: "access_token", Label: "Access Token", Type: "password", Required: true, Secret: true},
+			{Name: "secret", Label: "签名密钥", Type: "password", Secret: true},
+			{Name: "targets", Label: "目标", Type: "string", Hint: "用户手机号或用户id，多个用逗号分隔"},
+			{Name: "app_key", Label: "AppKey", Type: "string", Hint: "可选，配置后内网图片和 base64 图片会上传到钉钉"},
+			{Name: "app_secret", Label: "AppSecret", Type: "password", Secret: true, Hint: "与 AppKey 一起配置"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// 自定义机器人每分钟最多 20 条
+		RateLimit: &config.RateLimit{Rate: "20/m"},
+	})
+}
+
 // DingTalkNotifier 钉钉通知服务
 type DingTalkNotifier struct {
-	config config.DingTalkConfig
+	config DingTalkConfig
 	client *resty.Client
 }
 
@@ -29,7 +70,7 @@ type DingTalkResponse struct {
 }
 
 // NewDingTalkNotifier 创建钉钉通知服务实例
-func NewDingTalkNotifier(cfg config.DingTalkConfig) *DingTalkNotifier {
+func NewDingTalkNotifier(cfg DingTalkConfig) *DingTalkNotifier {
 	client := resty.New()
 	client.SetTimeout(30 * time.Second)
 	client.SetRetryCount(3)
@@ -42,13 +83,13 @@ func NewDingTalkNotifier(cfg config.DingTalkConfig) *DingTalkNotifier {
 
 	return &DingTalkNotifier{
 		config: cfg,
-		client: client,
+		client: supportDryRun(client),
 	}
 }
 
 // Name 返回服务名称
 func (d *DingTalkNotifier) Name() string {
-	return string(config.DingTalkAppBot)
+	return string(DingTalkAppBot)
 }
 
 // IsEnabled 检查服务是否启用
@@ -56,6 +97,11 @@ func (d *DingTalkNotifier) IsEnabled() bool {
 	return d.config.Enabled
 }
 
+// GroupTargets 钉钉的 targets 是同一条消息中 @ 的用户，不能拆分发送
+func (d *DingTalkNotifier) GroupTargets() bool {
+	return true
+}
+
 // Validate 验证配置
 func (d *DingTalkNotifier) Validate() error {
 	if !d.config.Enabled {
@@ -84,9 +130,10 @@ func (d *DingTalkNotifier) generateSign(timestamp int64) (string, error) {
 }
 
 // Send 发送通知消息
-func (d *DingTalkNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) error {
+func (d *DingTalkNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	start := time.Now()
 	if !d.config.Enabled {
-		return fmt.Errorf("钉钉通知服务未启用")
+		return resultsFor(targets, start, "", permanentError("钉钉通知服务未启用"))
 	}
 	if len(targets) == 0 && d.config.Targets != "" {
 		targets = strings.Split(d.config.Targets, ",")
@@ -102,36 +149,118 @@ func (d *DingTalkNotifier) Send(ctx context.Context, message *NotificationMessag
 		timestamp := time.Now().UnixNano() / 1e6
 		sign, err := d.generateSign(timestamp)
 		if err != nil {
-			return fmt.Errorf("生成签名失败: %w", err)
+			return resultsFor(targets, start, "", permanentError("生成签名失败: %v", err))
 		}
 		queryParams["timestamp"] = fmt.Sprintf("%d", timestamp)
 		queryParams["sign"] = sign
 	}
 
-	// 根据是否有图片选择不同的消息类型
-	var requestBody map[string]interface{}
-	// if message.Image != "" {
-	// 	requestBody = d.buildFeedCardMessage(message, targets)
-	// } else {
-	// 	requestBody = d.buildMarkdownMessage(message, targets)
-	// }
-	//全部用 markdown
-	requestBody = d.buildMarkdownMessage(message, targets)
+	// 内网图片和 data: URI 钉钉无法访问，配置了应用凭证时上传为媒体文件，否则去掉
+	if d.config.AppKey != "" && d.config.AppSecret != "" {
+		var accessToken string
+		message = ResolveImages(ctx, message, dingTalkImageLimits, func(ctx context.Context, image *ImageData) (string, error) {
+			if accessToken == "" {
+				token, err := d.getAccessToken(ctx)
+				if err != nil {
+					return "", err
+				}
+				accessToken = token
+			}
+			return d.uploadImage(ctx, accessToken, image)
+		})
+	} else if remote, uploads := SeparateUploadImages(ctx, message); len(uploads) > 0 {
+		logger.Warn("钉钉未配置 AppKey/AppSecret，无法发送内网图片", "count", len(uploads))
+		message = remote
+	}
+
+	// 超长消息拆分为多条，按钮只随最后一条发送
+	parts := SplitMessage(message, d.Limits(), d.buildMarkdownText)
+	_, sent, err := sendParts(ctx, targets, len(parts), func(i int) (string, error) {
+		// 有按钮且不需要 @ 用户时使用 actionCard（actionCard 不支持 @），其余全部用 markdown
+		var requestBody map[string]interface{}
+		if len(parts[i].AllActions()) > 0 && len(targets) == 0 {
+			requestBody = d.buildActionCardMessage(parts[i])
+		} else {
+			requestBody = d.buildMarkdownMessage(parts[i], targets)
+		}
+		return "", d.sendMessage(ctx, queryParams, requestBody)
+	})
+	// 钉钉一次请求发送到群，targets 只是 @ 的用户，所有目标结果相同
+	return partResults(targets, start, "", sent, len(parts), err)
+}
+
+// CheckCredentials 配置了 AppKey 时获取访问令牌检查应用凭证；机器人的 Access Token 只能通过发送消息验证
+func (d *DingTalkNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	if d.config.AppKey == "" || d.config.AppSecret == "" {
+		return "未配置 AppKey，机器人 Access Token 需要发送测试消息验证", nil
+	}
+	if _, err := d.getAccessToken(ctx); err != nil {
+		return "", err
+	}
+	return "获取访问令牌成功", nil
+}
+
+// Limits 消息长度限制：markdown 消息内容最长 20000 字节
+func (d *DingTalkNotifier) Limits() Limits {
+	return Limits{Text: 20000, Bytes: true}
+}
 
-	return d.sendMessage(ctx, queryParams, requestBody)
+// buildMarkdownText 构建 markdown 正文：图片、内容和键值字段
+func (d *DingTalkNotifier) buildMarkdownText(message *NotificationMessage) string {
+	var parts []string
+	for _, image := range message.AllImages() {
+		parts = append(parts, fmt.Sprintf("![](%s)", image))
+	}
+	if content := message.RenderContent(DialectDingTalk); content != "" {
+		parts = append(parts, content)
+	}
+	if len(message.Fields) > 0 {
+		// 钉钉 markdown 需要两个换行才能分行显示
+		parts = append(parts, strings.ReplaceAll(message.FieldsText("**"), "\n", "\n\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// buildActionCardMessage 构建ActionCard消息（支持按钮）
+func (d *DingTalkNotifier) buildActionCardMessage(message *NotificationMessage) map[string]interface{} {
+	actionCard := map[string]interface{}{
+		"title": message.DisplayTitle(),
+		"text":  d.buildMarkdownText(message),
+	}
+	actions := message.AllActions()
+	if len(actions) == 1 {
+		actionCard["singleTitle"] = actions[0].Label
+		actionCard["singleURL"] = actions[0].URL
+	} else {
+		btns := make([]map[string]interface{}, len(actions))
+		for i, action := range actions {
+			btns[i] = map[string]interface{}{
+				"title":     action.Label,
+				"actionURL": action.URL,
+			}
+		}
+		actionCard["btns"] = btns
+		actionCard["btnOrientation"] = "0"
+	}
+
+	return map[string]interface{}{
+		"msgtype":    "actionCard",
+		"actionCard": actionCard,
+	}
 }
 
 // buildMarkdownMessage 构建Markdown消息
 func (d *DingTalkNotifier) buildMarkdownMessage(message *NotificationMessage, targets []string) map[string]interface{} {
-	content := message.Content
-	if message.Image != "" {
-		content = fmt.Sprintf("![](%s)\n\n%s", message.Image, message.Content)
+	content := d.buildMarkdownText(message)
+	// 有 @ 用户时无法使用按钮，以链接形式附在末尾
+	for _, action := range message.AllActions() {
+		content += fmt.Sprintf("\n\n[%s](%s)", action.Label, action.URL)
 	}
 
 	requestBody := map[string]interface{}{
 		"msgtype": "markdown",
 		"markdown": map[string]interface{}{
-			"title": message.Title,
+			"title": message.DisplayTitle(),
 			"text":  content,
 		},
 	}
@@ -183,6 +312,73 @@ func (d *DingTalkNotifier) buildFeedCardMessage(message *NotificationMessage, ta
 	return requestBody
 }
 
+// dingTalkImageLimits 钉钉图片媒体文件限制：最大 20MB
+var dingTalkImageLimits = ImageLimits{MaxBytes: 20 << 20, Formats: []string{ImageJPEG, ImagePNG, ImageGIF}}
+
+// ImageLimits 消息中的图片链接与图片媒体文件的限制相同
+func (d *DingTalkNotifier) ImageLimits() ImageLimits {
+	return dingTalkImageLimits
+}
+
+// dingTalkTokenResponse 获取企业内部应用 access_token 的响应
+type dingTalkTokenResponse struct {
+	DingTalkResponse
+	AccessToken string `json:"access_token"`
+}
+
+// dingTalkMediaResponse 上传媒体文件的响应
+type dingTalkMediaResponse struct {
+	DingTalkResponse
+	MediaID string `json:"media_id"`
+}
+
+// getAccessToken 使用应用凭证获取 access_token，用于上传媒体文件
+func (d *DingTalkNotifier) getAccessToken(ctx context.Context) (string, error) {
+	var result dingTalkTokenResponse
+	resp, err := d.client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"appkey":    d.config.AppKey,
+			"appsecret": d.config.AppSecret,
+		}).
+		SetResult(&result).
+		Get("https://oapi.dingtalk.com/gettoken")
+	if err != nil {
+		return "", fmt.Errorf("获取访问令牌失败: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return "", httpStatusError(resp)
+	}
+	if result.ErrCode != 0 {
+		return "", providerError(result.ErrCode, "获取访问令牌失败: "+result.ErrMsg, result.ErrCode == -1)
+	}
+	return result.AccessToken, nil
+}
+
+// uploadImage 上传图片媒体文件，返回可以在 markdown 中引用的 media_id
+func (d *DingTalkNotifier) uploadImage(ctx context.Context, accessToken string, image *ImageData) (string, error) {
+	var result dingTalkMediaResponse
+	resp, err := d.client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"access_token": accessToken,
+			"type":         "image",
+		}).
+		SetMultipartField("media", image.Filename, image.ContentType, bytes.NewReader(image.Data)).
+		SetResult(&result).
+		Post("https://oapi.dingtalk.com/media/upload")
+	if err != nil {
+		return "", fmt.Errorf("上传请求失败: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return "", httpStatusError(resp)
+	}
+	if result.ErrCode != 0 {
+		return "", providerError(result.ErrCode, "上传媒体文件失败: "+result.ErrMsg, result.ErrCode == -1)
+	}
+	return result.MediaID, nil
+}
+
 // sendMessage 发送消息到钉钉
 func (d *DingTalkNotifier) sendMessage(ctx context.Context, queryParams map[string]string, requestBody map[string]interface{}) error {
 	var result DingTalkResponse
@@ -200,11 +396,18 @@ func (d *DingTalkNotifier) sendMessage(ctx context.Context, queryParams map[stri
 	}
 
 	if !resp.IsSuccess() {
-		return fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode())
+		return httpStatusError(resp)
 	}
 
 	if result.ErrCode != 0 {
-		return fmt.Errorf("发送消息失败: %s", result.ErrMsg)
+		// -1 系统繁忙，130101 发送太快
+		retryable := result.ErrCode == -1 || result.ErrCode == 130101
+		err := providerError(result.ErrCode, "发送消息失败: "+result.ErrMsg, retryable)
+		if result.ErrCode == 130101 {
+			// 每分钟超过 20 条后限流 10 分钟
+			err.RetryAfter = 10 * time.Minute
+		}
+		return err
 	}
 
 	return nil
@@ -0,0 +1,348 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DiscordWebhook Discord 频道 webhook
+const DiscordWebhook config.NotifiersType = "discordWebhook"
+
+// DiscordWebhookConfig Discord webhook 配置
+type DiscordWebhookConfig struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled"`
+	WebhookURL string `yaml:"webhook_url" json:"webhookUrl"` // webhook 地址，例如 https://discord.com/api/webhooks/<id>/<token>
+	ThreadID   string `yaml:"thread_id" json:"threadId"`     // 默认发送到的子区（论坛帖子），消息指定了 targets 时发送到 targets
+	Username   string `yaml:"username" json:"username"`      // 覆盖 webhook 的名称
+	AvatarURL  string `yaml:"avatar_url" json:"avatarUrl"`   // 覆盖 webhook 的头像
+	Proxy      string `yaml:"proxy" json:"proxy"`            // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(DiscordWebhook, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg DiscordWebhookConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewDiscordWebhookNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "Discord",
+		Description: "通过频道 webhook 发送 embed 消息，消息中的 targets 为子区 ID",
+		Fields: []FieldSchema{
+			{Name: "webhook_url", Label: "Webhook 地址", Type: "password", Required: true, Secret: true, Placeholder: "https://discord.com/api/webhooks/..."},
+			{Name: "thread_id", Label: "子区 ID", Type: "string", Hint: "可选，发送到频道中的子区或论坛帖子"},
+			{Name: "username", Label: "发送者名称", Type: "string", Hint: "可选，覆盖 webhook 的名称"},
+			{Name: "avatar_url", Label: "头像地址", Type: "string", Hint: "可选，覆盖 webhook 的头像"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// 每个 webhook 每分钟最多 30 条
+		RateLimit: &config.RateLimit{Rate: "30/m"},
+	})
+}
+
+// DiscordWebhookNotifier Discord webhook 通知服务
+type DiscordWebhookNotifier struct {
+	config DiscordWebhookConfig
+	client *resty.Client
+}
+
+// NewDiscordWebhookNotifier 创建 Discord webhook 通知服务实例
+func NewDiscordWebhookNotifier(cfg DiscordWebhookConfig) *DiscordWebhookNotifier {
+	client := resty.New()
+	client.SetTimeout(30 * time.Second)
+	if cfg.Proxy != "" {
+		client.SetProxy(cfg.Proxy)
+	}
+	return &DiscordWebhookNotifier{config: cfg, client: supportDryRun(client)}
+}
+
+// Name 返回服务名称
+func (d *DiscordWebhookNotifier) Name() string {
+	return string(DiscordWebhook)
+}
+
+// IsEnabled 检查服务是否启用
+func (d *DiscordWebhookNotifier) IsEnabled() bool {
+	return d.config.Enabled
+}
+
+// Validate 验证配置
+func (d *DiscordWebhookNotifier) Validate() error {
+	if !d.config.Enabled {
+		return nil
+	}
+	if d.config.WebhookURL == "" {
+		return fmt.Errorf("discord Webhook 地址不能为空")
+	}
+	if !strings.HasPrefix(d.config.WebhookURL, "http://") && !strings.HasPrefix(d.config.WebhookURL, "https://") {
+		return fmt.Errorf("discord Webhook 地址必须以 http:// 或 https:// 开头")
+	}
+	return nil
+}
+
+// Limits 消息长度限制：embed 描述最长 4096 字符
+func (d *DiscordWebhookNotifier) Limits() Limits {
+	return Limits{Text: 4096}
+}
+
+// discordEmbedTotal 一条消息中所有 embed 的标题、描述、字段和页脚合计最多 6000 字符
+const discordEmbedTotal = 6000
+
+// messageLimits 按消息计算描述的长度限制：从合计限制中扣除标题、字段、页脚和链接占用的长度，
+// 剩余长度过小时保留 1024 字符，由 fitEmbed 裁剪字段
+func (d *DiscordWebhookNotifier) messageLimits(message *NotificationMessage) Limits {
+	probe := *message
+	probe.Content = ""
+	probe.Title += partSuffixReserve
+	limits := d.Limits()
+	limits.Text = min(limits.Text, max(discordEmbedTotal-embedLength(d.buildEmbed(&probe)), 1024))
+	return limits
+}
+
+// Send 发送通知消息，targets 为子区 ID，为空时发送到默认子区或频道
+func (d *DiscordWebhookNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	if !d.config.Enabled {
+		return resultsFor(targets, time.Now(), "", permanentError("Discord通知服务未启用"))
+	}
+	threads := targets
+	if len(threads) == 0 {
+		threads = []string{d.config.ThreadID}
+	}
+
+	// Discord 无法访问内网图片和 data: URI
+	if remote, uploads := SeparateUploadImages(ctx, message); len(uploads) > 0 {
+		logger.Warn("Discord 无法发送内网图片", "count", len(uploads))
+		message = remote
+	}
+
+	// 超长描述拆分为多条，图片只随第一条发送，字段和链接只随最后一条发送
+	parts := SplitMessage(message, d.messageLimits(message), d.buildDescription)
+	results := make(Results, 0, len(threads))
+	for _, thread := range threads {
+		start := time.Now()
+		messageID, sent, err := sendParts(ctx, []string{thread}, len(parts), func(i int) (string, error) {
+			return d.execute(ctx, thread, d.buildPayload(parts[i]))
+		})
+		results = append(results, partResults([]string{thread}, start, messageID, sent, len(parts), err)...)
+	}
+	return results
+}
+
+// CheckCredentials 查询 webhook 信息检查地址和令牌，不发送消息
+func (d *DiscordWebhookNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	var webhook struct {
+		Name      string `json:"name"`
+		ChannelID string `json:"channel_id"`
+	}
+	resp, err := d.client.R().SetContext(ctx).SetResult(&webhook).Get(d.config.WebhookURL)
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return "", discordError(resp)
+	}
+	return fmt.Sprintf("Webhook %s，频道 %s", webhook.Name, webhook.ChannelID), nil
+}
+
+// discordEmbed 消息中的 embed
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Image       *discordEmbedImage  `json:"image,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Footer      *discordEmbedFooter `json:"footer,omitempty"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// buildDescription 生成 embed 描述：正文和按钮链接
+func (d *DiscordWebhookNotifier) buildDescription(message *NotificationMessage) string {
+	var parts []string
+	if content := message.RenderContent(DialectMarkdown); content != "" {
+		parts = append(parts, content)
+	}
+	// webhook 不能发送按钮，输出为链接
+	var links []string
+	for _, action := range message.AllActions() {
+		links = append(links, "["+action.Label+"]("+action.URL+")")
+	}
+	if len(links) > 0 {
+		parts = append(parts, strings.Join(links, " · "))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// buildEmbed 生成主 embed：标题、描述、颜色、时间和字段，各项按 Discord 的单项限制截断
+func (d *DiscordWebhookNotifier) buildEmbed(message *NotificationMessage) discordEmbed {
+	embed := discordEmbed{
+		Title:       truncateRunes(message.DisplayTitle(), 256),
+		Description: d.buildDescription(message),
+		URL:         message.URL,
+		Color:       discordColor(message.Level.Color()),
+	}
+	if ts, ok := discordTimestamp(message.Timestamp); ok {
+		embed.Timestamp = ts
+	} else if message.Timestamp != "" {
+		embed.Footer = &discordEmbedFooter{Text: truncateRunes(message.Timestamp, 2048)}
+	}
+	for _, field := range message.Fields[:min(len(message.Fields), 25)] {
+		embed.Fields = append(embed.Fields, discordEmbedField{
+			Name:   truncateRunes(field.Key, 256),
+			Value:  truncateRunes(field.Value, 1024),
+			Inline: utf8.RuneCountInString(field.Value) <= 40,
+		})
+	}
+	return embed
+}
+
+// embedLength 计算计入合计限制的长度
+func embedLength(e discordEmbed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+	if e.Footer != nil {
+		n += utf8.RuneCountInString(e.Footer.Text)
+	}
+	for _, field := range e.Fields {
+		n += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	return n
+}
+
+// fitEmbed 超出合计限制时从后往前去掉字段，仍然超出时截断描述
+func fitEmbed(e *discordEmbed) {
+	over := embedLength(*e) - discordEmbedTotal
+	for over > 0 && len(e.Fields) > 0 {
+		last := e.Fields[len(e.Fields)-1]
+		over -= utf8.RuneCountInString(last.Name) + utf8.RuneCountInString(last.Value)
+		e.Fields = e.Fields[:len(e.Fields)-1]
+	}
+	if over > 0 {
+		e.Description = truncateRunes(e.Description, max(utf8.RuneCountInString(e.Description)-over, 1))
+	}
+}
+
+// buildPayload 生成请求体：第一张图片放在主 embed 中，其余图片（最多 3 张）作为相同 URL 的 embed 组成图集；
+// 图集的 embed 只有图片，不占用合计长度
+func (d *DiscordWebhookNotifier) buildPayload(message *NotificationMessage) map[string]any {
+	embed := d.buildEmbed(message)
+	fitEmbed(&embed)
+
+	embeds := []discordEmbed{embed}
+	images := message.AllImages()
+	if len(images) > 0 {
+		embeds[0].Image = &discordEmbedImage{URL: images[0]}
+		for _, image := range images[1:min(len(images), 4)] {
+			// 多个 embed 的 URL 相同时 Discord 合并显示为图集；没有 URL 时各自显示
+			embeds = append(embeds, discordEmbed{URL: message.URL, Image: &discordEmbedImage{URL: image}})
+		}
+	}
+
+	payload := map[string]any{
+		"embeds": embeds,
+		// 不解析正文中的 @everyone、@here 和用户提及
+		"allowed_mentions": map[string]any{"parse": []string{}},
+	}
+	if d.config.Username != "" {
+		payload["username"] = d.config.Username
+	}
+	if d.config.AvatarURL != "" {
+		payload["avatar_url"] = d.config.AvatarURL
+	}
+	return payload
+}
+
+// execute 调用 webhook 发送消息，返回消息 ID
+func (d *DiscordWebhookNotifier) execute(ctx context.Context, threadID string, payload map[string]any) (string, error) {
+	var result struct {
+		ID string `json:"id"`
+	}
+	req := d.client.R().
+		SetContext(ctx).
+		SetQueryParam("wait", "true").
+		SetHeader("Content-Type", "application/json").
+		SetBody(payload).
+		SetResult(&result)
+	if threadID != "" {
+		req.SetQueryParam("thread_id", threadID)
+	}
+	resp, err := req.Post(d.config.WebhookURL)
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if !resp.IsSuccess() {
+		return "", discordError(resp)
+	}
+	return result.ID, nil
+}
+
+// discordError 解析错误响应：{"message": "...", "code": 10015}，限流时带有 retry_after（秒）
+func discordError(resp *resty.Response) error {
+	pe := httpStatusError(resp)
+	var body struct {
+		Message    string  `json:"message"`
+		Code       int     `json:"code"`
+		RetryAfter float64 `json:"retry_after"`
+		Global     bool    `json:"global"`
+	}
+	if json.Unmarshal(resp.Body(), &body) != nil {
+		return pe
+	}
+	if body.Message != "" {
+		pe.Message += "，" + body.Message
+	}
+	if body.Code != 0 {
+		pe.Code = strconv.Itoa(body.Code)
+	}
+	if body.RetryAfter > 0 {
+		pe.RetryAfter = time.Duration(body.RetryAfter * float64(time.Second))
+	}
+	return pe
+}
+
+// discordColor 将 #rrggbb 转换为 embed 使用的整数颜色
+func discordColor(hex string) int {
+	color, err := strconv.ParseInt(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(color)
+}
+
+// discordTimestamp 将消息时间转换为 ISO8601，无法解析时返回 false
+func discordTimestamp(timestamp string) (string, bool) {
+	if timestamp == "" {
+		return "", false
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, timestamp, time.Local); err == nil {
+			return t.Format(time.RFC3339), true
+		}
+	}
+	return "", false
+}
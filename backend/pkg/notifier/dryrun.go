@@ -0,0 +1,301 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jianxcao/notify/backend/pkg/config"
+)
+
+// 预览模式：通知服务照常格式化消息并构造请求，HTTP 传输层不发出请求，而是记录请求内容并返回模拟的成功响应。
+// 模拟响应包含各服务商成功响应的常用字段（错误码为 0、访问令牌、媒体 ID 等），令牌的有效期为 0，不会被缓存。
+
+// dryRunResponse 模拟的成功响应
+const dryRunResponse = `{"errcode":0,"errmsg":"ok","code":0,"msg":"ok","ok":true,` +
+	`"access_token":"dry-run","accessToken":"dry-run","tenant_access_token":"dry-run","expires_in":0,"expireIn":0,"expire":0,` +
+	`"media_id":"dry-run","msgid":"dry-run","result":{"message_id":0},"data":{"message_id":"dry-run","image_key":"dry-run"}}`
+
+// maxCapturedString 记录的请求体中超过该长度的字符串（例如 base64 图片）只保留开头
+const maxCapturedString = 4096
+
+// CapturedRequest 预览模式下记录的请求，密钥已替换为 ***
+type CapturedRequest struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Body        any               `json:"body,omitempty"` // JSON 解析为对象，multipart 解析为字段，其它内容为文本
+}
+
+// Recorder 记录预览模式下通知服务构造的请求
+type Recorder struct {
+	secrets []string
+
+	mu       sync.Mutex
+	requests []CapturedRequest
+}
+
+type recorderKey struct{}
+
+// WithDryRun 返回预览模式的 context：使用该 context 发送的请求不会发出，secrets 中的值在记录中替换为 ***
+func WithDryRun(ctx context.Context, secrets []string) (context.Context, *Recorder) {
+	rec := &Recorder{}
+	for _, secret := range secrets {
+		if secret != "" {
+			rec.secrets = append(rec.secrets, secret)
+			if escaped := url.QueryEscape(secret); escaped != secret {
+				rec.secrets = append(rec.secrets, escaped)
+			}
+		}
+	}
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+// Requests 返回已记录的请求
+func (r *Recorder) Requests() []CapturedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CapturedRequest(nil), r.requests...)
+}
+
+// SecretValues 返回通知服务实例配置中密钥字段的值
+func SecretValues(instance config.NotifierInstance) []string {
+	info, exists := Lookup(instance.Type)
+	if !exists {
+		return nil
+	}
+	var secrets []string
+	for _, field := range info.SecretFields {
+		if value, ok := instance.Config[field].(string); ok && value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// redact 替换文本中的密钥
+func (r *Recorder) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
+// redactValue 替换记录内容中各字符串的密钥
+func (r *Recorder) redactValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		return r.redact(val)
+	case []string:
+		for i, item := range val {
+			val[i] = r.redact(item)
+		}
+	case map[string]any:
+		for k, item := range val {
+			val[k] = r.redactValue(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = r.redactValue(item)
+		}
+	}
+	return v
+}
+
+// dryRunRecorder 返回 context 中的预览记录器，不是预览模式时返回 nil；
+// 不使用 HTTP 的通知服务（例如邮件）需要在发送前自行检查
+func dryRunRecorder(ctx context.Context) *Recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return rec
+}
+
+// IsDryRun 是否为预览模式，预览模式下不下载图片，也不写入图片缓存
+func IsDryRun(ctx context.Context) bool {
+	return dryRunRecorder(ctx) != nil
+}
+
+// dryRunImage 预览模式下代替下载内容的占位图片：1x1 的透明 PNG
+var dryRunImage = func() []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	return buf.Bytes()
+}()
+
+// add 记录不经过 HTTP 传输层的请求
+func (r *Recorder) add(captured CapturedRequest) {
+	captured.URL = r.redact(captured.URL)
+	captured.Body = r.redactValue(captured.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, captured)
+	r.mu.Unlock()
+}
+
+// record 读取并记录请求
+func (r *Recorder) record(req *http.Request) error {
+	captured := CapturedRequest{
+		Method:      req.Method,
+		URL:         r.redact(req.URL.String()),
+		ContentType: req.Header.Get("Content-Type"),
+	}
+	for key, values := range req.Header {
+		if key == "Content-Type" || key == "User-Agent" || key == "Content-Length" {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if key == "Authorization" {
+			value = "***"
+		}
+		if captured.Headers == nil {
+			captured.Headers = make(map[string]string)
+		}
+		captured.Headers[key] = r.redact(value)
+	}
+
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("读取请求体失败: %w", err)
+		}
+		captured.Body = r.captureBody(captured.ContentType, body)
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, captured)
+	r.mu.Unlock()
+	return nil
+}
+
+// captureBody 按内容类型解析请求体，解码后再替换密钥：JSON 转义或表单编码后的密钥在原始内容中无法匹配
+func (r *Recorder) captureBody(contentType string, body []byte) any {
+	if len(body) == 0 {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		fields := map[string]any{}
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			data, _ := io.ReadAll(part)
+			if part.FileName() != "" {
+				fields[part.FormName()] = fmt.Sprintf("[文件 %s，%d 字节]", part.FileName(), len(data))
+				continue
+			}
+			// 字段值为 JSON 时（例如 Telegram 的 reply_markup）解码后替换
+			text := string(data)
+			if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+				fields[part.FormName()] = r.decodeText(text)
+			} else {
+				fields[part.FormName()] = truncateString(r.redact(text))
+			}
+		}
+		return fields
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			break
+		}
+		fields := make(map[string]any, len(values))
+		for key, items := range values {
+			if len(items) == 1 {
+				fields[key] = truncateString(r.redact(items[0]))
+				continue
+			}
+			list := make([]any, len(items))
+			for i, item := range items {
+				list[i] = truncateString(r.redact(item))
+			}
+			fields[key] = list
+		}
+		return fields
+	}
+	return r.decodeText(string(body))
+}
+
+// decodeText 解析 JSON 后替换各字符串中的密钥并截断过长的字符串，不是 JSON 时按文本处理
+func (r *Recorder) decodeText(text string) any {
+	var decoded any
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if decoder.Decode(&decoded) == nil {
+		return truncateStrings(r.redactValue(decoded))
+	}
+	return truncateString(r.redact(text))
+}
+
+// truncateStrings 截断 JSON 中过长的字符串
+func truncateStrings(v any) any {
+	switch val := v.(type) {
+	case string:
+		return truncateString(val)
+	case map[string]any:
+		for k, item := range val {
+			val[k] = truncateStrings(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = truncateStrings(item)
+		}
+	}
+	return v
+}
+
+func truncateString(s string) string {
+	if len(s) <= maxCapturedString {
+		return s
+	}
+	return fmt.Sprintf("%s...（共 %d 字节）", s[:256], len(s))
+}
+
+// dryRunTransport 预览模式下记录请求并返回模拟响应，其它情况交给 base 发送
+type dryRunTransport struct {
+	base http.RoundTripper
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := dryRunRecorder(req.Context())
+	if rec == nil {
+		return t.base.RoundTrip(req)
+	}
+	if err := rec.record(req); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(dryRunResponse)),
+		ContentLength: int64(len(dryRunResponse)),
+		Request:       req,
+	}, nil
+}
+
+// supportDryRun 为 HTTP 客户端安装预览模式的传输层，需要在设置代理之后调用
+func supportDryRun(client *resty.Client) *resty.Client {
+	return client.SetTransport(&dryRunTransport{base: client.GetClient().Transport})
+}
+
+// dryRunHTTPClient 支持预览模式的标准 HTTP 客户端，用于服务商 SDK
+func dryRunHTTPClient() *http.Client {
+	return &http.Client{Transport: &dryRunTransport{base: http.DefaultTransport}}
+}
@@ -0,0 +1,356 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+)
+
+// EmailSMTP 邮件（SMTP）
+const EmailSMTP config.NotifiersType = "email"
+
+// 连接加密方式
+const (
+	EmailSecurityStartTLS = "starttls" // 明文连接后升级为 TLS，默认端口 587
+	EmailSecurityTLS      = "tls"      // 隐式 TLS（SMTPS），默认端口 465
+	EmailSecurityNone     = "none"     // 不加密，默认端口 25，只适合本机或内网中继
+)
+
+// emailTimeout 单次发送（连接、认证和传输）的超时时间
+const emailTimeout = 60 * time.Second
+
+// EmailConfig 邮件配置
+type EmailConfig struct {
+	Enabled            bool   `yaml:"enabled" json:"enabled"`
+	Host               string `yaml:"host" json:"host"`                               // SMTP 服务器地址
+	Port               int    `yaml:"port" json:"port"`                               // 端口，为空时按加密方式使用默认端口
+	Security           string `yaml:"security" json:"security"`                       // 加密方式：starttls（默认）、tls、none
+	Username           string `yaml:"username" json:"username"`                       // 认证用户名，为空时不认证
+	Password           string `yaml:"password" json:"password"`                       // 认证密码或授权码
+	From               string `yaml:"from" json:"from"`                               // 发件人，例如: Notify <notify@example.com>
+	To                 string `yaml:"to" json:"to"`                                   // 默认收件人，多个用逗号分隔
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecureSkipVerify"` // 不校验服务器证书（自签名证书）
+}
+
+func init() {
+	Register(EmailSMTP, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg EmailConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewEmailNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "邮件",
+		Description: "通过 SMTP 发送 HTML 邮件，图片作为内嵌附件，消息中的 targets 为收件人地址",
+		Fields: []FieldSchema{
+			{Name: "host", Label: "SMTP 服务器", Type: "string", Required: true, Placeholder: "smtp.example.com"},
+			{Name: "port", Label: "端口", Type: "number", Hint: "为空时使用默认端口：starttls 587，tls 465，none 25"},
+			{Name: "security", Label: "加密方式", Type: "select", Options: []string{EmailSecurityStartTLS, EmailSecurityTLS, EmailSecurityNone}, Default: EmailSecurityStartTLS},
+			{Name: "username", Label: "用户名", Type: "string", Hint: "为空时不认证"},
+			{Name: "password", Label: "密码", Type: "password", Secret: true, Hint: "密码或邮箱服务商的授权码"},
+			{Name: "from", Label: "发件人", Type: "string", Required: true, Placeholder: "Notify <notify@example.com>"},
+			{Name: "to", Label: "默认收件人", Type: "string", Hint: "多个用逗号分隔，消息指定了 targets 时发送给 targets"},
+			{Name: "insecure_skip_verify", Label: "跳过证书校验", Type: "bool", Hint: "服务器使用自签名证书时开启"},
+		},
+	})
+}
+
+// EmailNotifier 邮件通知服务
+type EmailNotifier struct {
+	config EmailConfig
+}
+
+// NewEmailNotifier 创建邮件通知服务实例
+func NewEmailNotifier(cfg EmailConfig) *EmailNotifier {
+	cfg.Security = strings.ToLower(strings.TrimSpace(cfg.Security))
+	if cfg.Security == "" {
+		cfg.Security = EmailSecurityStartTLS
+	}
+	if cfg.Port == 0 {
+		switch cfg.Security {
+		case EmailSecurityTLS:
+			cfg.Port = 465
+		case EmailSecurityNone:
+			cfg.Port = 25
+		default:
+			cfg.Port = 587
+		}
+	}
+	return &EmailNotifier{config: cfg}
+}
+
+// Name 返回服务名称
+func (e *EmailNotifier) Name() string {
+	return string(EmailSMTP)
+}
+
+// IsEnabled 检查服务是否启用
+func (e *EmailNotifier) IsEnabled() bool {
+	return e.config.Enabled
+}
+
+// GroupTargets 所有收件人在同一封邮件中，不按收件人拆分发送
+func (e *EmailNotifier) GroupTargets() bool {
+	return true
+}
+
+// Validate 验证配置
+func (e *EmailNotifier) Validate() error {
+	if !e.config.Enabled {
+		return nil
+	}
+	if e.config.Host == "" {
+		return fmt.Errorf("邮件 SMTP 服务器不能为空")
+	}
+	if e.config.Port <= 0 || e.config.Port > 65535 {
+		return fmt.Errorf("邮件 SMTP 端口 %d 无效", e.config.Port)
+	}
+	switch e.config.Security {
+	case EmailSecurityStartTLS, EmailSecurityTLS, EmailSecurityNone:
+	default:
+		return fmt.Errorf("邮件加密方式 %q 无效，可选 starttls、tls、none", e.config.Security)
+	}
+	if _, err := mail.ParseAddress(e.config.From); err != nil {
+		return fmt.Errorf("邮件发件人 %q 格式错误: %w", e.config.From, err)
+	}
+	for _, to := range splitList(e.config.To) {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("邮件默认收件人 %q 格式错误: %w", to, err)
+		}
+	}
+	return nil
+}
+
+// CheckCredentials 连接 SMTP 服务器并认证，不发送邮件
+func (e *EmailNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	client, err := e.dial(ctx)
+	if err != nil {
+		return "", smtpError(err)
+	}
+	defer client.Close()
+	_ = client.Quit()
+	if e.config.Username == "" {
+		return fmt.Sprintf("已连接 %s:%d（未配置认证）", e.config.Host, e.config.Port), nil
+	}
+	return fmt.Sprintf("已连接 %s:%d 并通过认证", e.config.Host, e.config.Port), nil
+}
+
+// Send 发送通知消息，targets 为收件人地址，为空时发送给默认收件人
+func (e *EmailNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	start := time.Now()
+	if !e.config.Enabled {
+		return resultsFor(targets, start, "", permanentError("邮件通知服务未启用"))
+	}
+	if len(targets) == 0 {
+		targets = splitList(e.config.To)
+	}
+	if len(targets) == 0 {
+		return resultsFor(nil, start, "", permanentError("未指定收件人，请配置默认收件人或在消息中指定 targets"))
+	}
+
+	// 地址格式错误的收件人单独失败，不影响其它收件人
+	errs := make(map[string]error, len(targets))
+	addresses := make(map[string]string, len(targets))
+	var recipients []*mail.Address
+	for _, target := range targets {
+		addr, err := mail.ParseAddress(target)
+		if err != nil {
+			errs[target] = permanentError("收件人地址格式错误: %v", err)
+			continue
+		}
+		addresses[target] = addr.Address
+		recipients = append(recipients, addr)
+	}
+
+	if len(recipients) > 0 {
+		from, _ := mail.ParseAddress(e.config.From)
+		msg, err := buildEmail(ctx, from, recipients, message)
+		if err != nil {
+			return resultsFor(targets, start, "", permanentError("生成邮件失败: %v", err))
+		}
+		if rec := dryRunRecorder(ctx); rec != nil {
+			rec.add(msg.captured(fmt.Sprintf("smtp://%s:%d", e.config.Host, e.config.Port)))
+			return resultsFor(targets, start, msg.id, nil)
+		}
+		failed := e.deliver(ctx, from, recipients, msg)
+		results := make(Results, 0, len(targets))
+		for _, target := range targets {
+			err := errs[target]
+			if err == nil {
+				err = failed[addresses[target]]
+			}
+			results = append(results, NewResult(target, start, msg.id, err))
+		}
+		return results
+	}
+
+	results := make(Results, 0, len(targets))
+	for _, target := range targets {
+		results = append(results, NewResult(target, start, "", errs[target]))
+	}
+	return results
+}
+
+// deliver 通过 SMTP 发送邮件，返回发送失败的收件人地址及原因；服务器拒绝的收件人单独失败
+func (e *EmailNotifier) deliver(ctx context.Context, from *mail.Address, recipients []*mail.Address, msg *email) map[string]error {
+	errs := make(map[string]error)
+	failAll := func(err error) map[string]error {
+		for _, addr := range recipients {
+			if errs[addr.Address] == nil {
+				errs[addr.Address] = err
+			}
+		}
+		return errs
+	}
+
+	client, err := e.dial(ctx)
+	if err != nil {
+		return failAll(smtpError(err))
+	}
+	defer client.Close()
+
+	if err := client.Mail(from.Address); err != nil {
+		return failAll(smtpError(fmt.Errorf("发件人被拒绝: %w", err)))
+	}
+	accepted := 0
+	for _, addr := range recipients {
+		if err := client.Rcpt(addr.Address); err != nil {
+			errs[addr.Address] = smtpError(fmt.Errorf("收件人被拒绝: %w", err))
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 {
+		return errs
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return failAll(smtpError(err))
+	}
+	if _, err := w.Write(msg.data); err != nil {
+		return failAll(smtpError(err))
+	}
+	if err := w.Close(); err != nil {
+		return failAll(smtpError(err))
+	}
+	_ = client.Quit()
+	return errs
+}
+
+// dial 连接 SMTP 服务器，按配置升级 TLS 并认证
+func (e *EmailNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	tlsConfig := &tls.Config{ServerName: e.config.Host, InsecureSkipVerify: e.config.InsecureSkipVerify}
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+	var err error
+	if e.config.Security == EmailSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SMTP 握手失败: %w", err)
+	}
+
+	if e.config.Security == EmailSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, permanentError("SMTP 服务器不支持 STARTTLS，请将加密方式设置为 tls 或 none")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+
+	if e.config.Username != "" {
+		if err := client.Auth(e.auth(client)); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// auth 按服务器支持的认证方式选择 PLAIN 或 LOGIN
+func (e *EmailNotifier) auth(client *smtp.Client) smtp.Auth {
+	if _, mechs := client.Extension("AUTH"); !strings.Contains(strings.ToUpper(mechs), "PLAIN") && strings.Contains(strings.ToUpper(mechs), "LOGIN") {
+		return &loginAuth{username: e.config.Username, password: e.config.Password}
+	}
+	return smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+}
+
+// loginAuth LOGIN 认证（部分邮箱服务只支持这种方式）
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("连接未加密，拒绝发送密码")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("未知的 LOGIN 认证提示: %s", fromServer)
+}
+
+// smtpError 转换 SMTP 错误：4xx 为临时错误可以重试，5xx 为永久错误
+func smtpError(err error) error {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return err
+	}
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		return providerError(fmt.Sprintf("SMTP_%d", te.Code), err.Error(), te.Code < 500)
+	}
+	return err
+}
+
+// splitList 拆分逗号或分号分隔的列表，去掉空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,302 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/utils"
+)
+
+// emailImageLimits 内嵌图片的限制，邮件客户端普遍支持 JPEG、PNG 和 GIF
+var emailImageLimits = ImageLimits{
+	MaxBytes: 5 << 20,
+	Formats:  []string{ImageJPEG, ImagePNG, ImageGIF},
+}
+
+// email 生成的邮件
+type email struct {
+	id          string // Message-ID，不含尖括号
+	from        string
+	to          []string
+	subject     string
+	text        string
+	html        string
+	attachments []emailAttachment
+	data        []byte // 完整的 MIME 邮件
+}
+
+// emailAttachment 内嵌图片
+type emailAttachment struct {
+	cid   string
+	image *ImageData
+}
+
+// emailView HTML 模板数据
+type emailView struct {
+	Color     string
+	Title     string
+	Content   template.HTML
+	Fields    []Field
+	Images    []template.URL
+	Actions   []Action
+	Timestamp string
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"></head>
+<body style="margin:0;padding:24px 12px;background:#f5f5f5;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI','PingFang SC','Microsoft YaHei',sans-serif;color:#262626;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:640px;margin:0 auto;background:#ffffff;border-radius:6px;border-top:4px solid {{.Color}};">
+<tr><td style="padding:20px 24px;">
+{{- if .Title}}
+<h2 style="margin:0 0 16px;font-size:18px;line-height:1.4;">{{.Title}}</h2>
+{{- end}}
+{{- if .Content}}
+<div style="font-size:14px;line-height:1.6;word-break:break-word;">{{.Content}}</div>
+{{- end}}
+{{- if .Fields}}
+<table role="presentation" cellpadding="0" cellspacing="0" style="margin-top:16px;border-collapse:collapse;font-size:14px;">
+{{- range .Fields}}
+<tr><td style="padding:4px 16px 4px 0;color:#8c8c8c;white-space:nowrap;vertical-align:top;">{{.Key}}</td><td style="padding:4px 0;">{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .Images}}
+<p style="margin:16px 0 0;"><img src="{{.}}" alt="" style="max-width:100%;height:auto;border-radius:4px;"></p>
+{{- end}}
+{{- if .Actions}}
+<p style="margin:20px 0 0;">
+{{- range .Actions}}
+<a href="{{.URL}}" target="_blank" style="display:inline-block;margin:0 8px 8px 0;padding:8px 16px;background:{{$.Color}};color:#ffffff;text-decoration:none;border-radius:4px;font-size:14px;">{{.Label}}</a>
+{{- end}}
+</p>
+{{- end}}
+{{- if .Timestamp}}
+<p style="margin:20px 0 0;font-size:12px;color:#8c8c8c;">{{.Timestamp}}</p>
+{{- end}}
+</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// buildEmail 将通知消息生成 multipart 邮件：纯文本和 HTML 两种正文，图片作为内嵌附件
+func buildEmail(ctx context.Context, from *mail.Address, to []*mail.Address, message *NotificationMessage) (*email, error) {
+	msg := &email{
+		id:      utils.NewID() + "@" + emailDomain(from.Address),
+		from:    from.String(),
+		subject: message.DisplayTitle(),
+	}
+	for _, addr := range to {
+		msg.to = append(msg.to, addr.String())
+	}
+	if msg.subject == "" {
+		msg.subject = "通知"
+	}
+
+	view := emailView{
+		Color:     message.Level.Color(),
+		Title:     message.DisplayTitle(),
+		Fields:    message.Fields,
+		Actions:   message.AllActions(),
+		Timestamp: message.Timestamp,
+	}
+	if message.Format == FormatHTML {
+		// 内容来自请求数据，过滤掉脚本、事件属性等后再嵌入邮件
+		view.Content = template.HTML(sanitizeHTML(message.Content, emailHTMLPolicy))
+	} else {
+		view.Content = template.HTML(message.RenderContent(DialectHTML))
+	}
+	for i, src := range message.AllImages() {
+		img, err := LoadImage(ctx, src)
+		if err == nil {
+			img, err = img.Normalize(emailImageLimits)
+		}
+		if err != nil {
+			// 无法内嵌时使用原地址，data URI 大多数邮件客户端不显示，直接丢弃
+			logger.Error("获取图片失败", "image", AbbreviateImage(src), "error", err)
+			if !IsDataURI(src) {
+				view.Images = append(view.Images, template.URL(src))
+			}
+			continue
+		}
+		cid := fmt.Sprintf("image%d.%s", i+1, msg.id)
+		msg.attachments = append(msg.attachments, emailAttachment{cid: cid, image: img})
+		view.Images = append(view.Images, template.URL("cid:"+cid))
+	}
+
+	var htmlBody bytes.Buffer
+	if err := emailTemplate.Execute(&htmlBody, view); err != nil {
+		return nil, fmt.Errorf("渲染 HTML 正文失败: %w", err)
+	}
+	msg.html = htmlBody.String()
+	msg.text = emailText(message)
+
+	data, err := msg.encode()
+	if err != nil {
+		return nil, err
+	}
+	msg.data = data
+	return msg, nil
+}
+
+// emailText 生成纯文本正文
+func emailText(message *NotificationMessage) string {
+	var parts []string
+	if title := message.DisplayTitle(); title != "" {
+		parts = append(parts, title)
+	}
+	if content := message.PlainContent(); content != "" {
+		parts = append(parts, content)
+	}
+	if fields := message.FieldsText(""); fields != "" {
+		parts = append(parts, fields)
+	}
+	var links []string
+	for _, action := range message.AllActions() {
+		links = append(links, action.Label+": "+action.URL)
+	}
+	if len(links) > 0 {
+		parts = append(parts, strings.Join(links, "\n"))
+	}
+	if message.Timestamp != "" {
+		parts = append(parts, message.Timestamp)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// encode 生成 MIME 邮件：
+// multipart/alternative 包含 text/plain 和 multipart/related（text/html 和内嵌图片）
+func (m *email) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	alternative := multipart.NewWriter(&buf)
+
+	header := []string{
+		"From: " + m.from,
+		"To: " + strings.Join(m.to, ", "),
+		"Subject: " + mime.BEncoding.Encode("utf-8", m.subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + m.id + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + alternative.Boundary(),
+	}
+	var out bytes.Buffer
+	out.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	textPart, err := alternative.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(textPart, m.text); err != nil {
+		return nil, err
+	}
+
+	var related bytes.Buffer
+	relatedWriter := multipart.NewWriter(&related)
+	relatedPart, err := alternative.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/related; boundary=" + relatedWriter.Boundary()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	htmlPart, err := relatedWriter.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(htmlPart, m.html); err != nil {
+		return nil, err
+	}
+	for _, attachment := range m.attachments {
+		part, err := relatedWriter.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(attachment.image.ContentType, map[string]string{"name": attachment.image.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("inline", map[string]string{"filename": attachment.image.Filename})},
+			"Content-ID":                {"<" + attachment.cid + ">"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(part, attachment.image.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := relatedWriter.Close(); err != nil {
+		return nil, err
+	}
+	if _, err := relatedPart.Write(related.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// captured 预览模式下记录的邮件内容
+func (m *email) captured(url string) CapturedRequest {
+	body := map[string]any{
+		"messageId": m.id,
+		"from":      m.from,
+		"to":        m.to,
+		"subject":   m.subject,
+		"text":      truncateString(m.text),
+		"html":      truncateString(m.html),
+	}
+	if len(m.attachments) > 0 {
+		var attachments []string
+		for _, attachment := range m.attachments {
+			attachments = append(attachments, fmt.Sprintf("[文件 %s，%d 字节]", attachment.image.Filename, len(attachment.image.Data)))
+		}
+		body["attachments"] = attachments
+	}
+	return CapturedRequest{Method: "SMTP", URL: url, ContentType: "multipart/alternative", Body: body}
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines 按每行 76 个字符写入 base64 内容
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// emailDomain 返回邮件地址的域名部分，用于生成 Message-ID 和 Content-ID
+func emailDomain(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 && i < len(address)-1 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,280 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer 测试用的 SMTP 服务器，只实现 EHLO、MAIL、RCPT、DATA 和 QUIT
+type smtpServer struct {
+	ln     net.Listener
+	reject map[string]string // 收件人地址 -> RCPT 的拒绝响应，例如 "550 no such user"
+
+	mu    sync.Mutex
+	rcpts []string
+	data  []string
+}
+
+func startSMTPServer(t *testing.T, reject map[string]string) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, reject: reject}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(line string) { _ = tp.PrintfLine("%s", line) }
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "HELO"), strings.HasPrefix(cmd, "MAIL FROM:"), strings.HasPrefix(cmd, "RSET"), strings.HasPrefix(cmd, "NOOP"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			addr := strings.Trim(strings.TrimSpace(line[len("RCPT TO:"):]), "<>")
+			if resp, ok := s.reject[addr]; ok {
+				reply(resp)
+				continue
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, addr)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = append(s.data, string(data))
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// received 返回服务器接受的收件人和收到的邮件
+func (s *smtpServer) received() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.rcpts...), append([]string(nil), s.data...)
+}
+
+func (s *smtpServer) notifier() *EmailNotifier {
+	return NewEmailNotifier(EmailConfig{
+		Enabled:  true,
+		Host:     "127.0.0.1",
+		Port:     s.ln.Addr().(*net.TCPAddr).Port,
+		Security: EmailSecurityNone,
+		From:     "Notify <notify@example.com>",
+	})
+}
+
+func pngDataURI(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// readPart 读取 MIME 部分的内容；quoted-printable 已由 multipart.Reader 解码，这里只需解码 base64
+func readPart(t *testing.T, part *multipart.Part) []byte {
+	t.Helper()
+	var r io.Reader = part
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		r = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEmailSendMultipartWithInlineImage(t *testing.T) {
+	server := startSMTPServer(t, nil)
+	message := &NotificationMessage{
+		Title:   "备份完成",
+		Content: `<p onclick="alert(1)">共 <b>3</b> 个文件</p><script>alert(1)</script><a href="javascript:alert(1)">x</a>`,
+		Format:  FormatHTML,
+		Image:   pngDataURI(t),
+		Fields:  []Field{{Key: "主机", Value: "nas"}},
+	}
+	results := server.notifier().Send(context.Background(), message, []string{"ops@example.com"})
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+	_, data := server.received()
+	if len(data) != 1 {
+		t.Fatalf("应发送 1 封邮件，实际 %d 封", len(data))
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(data[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "备份完成" {
+		t.Errorf("Subject = %q", subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("顶层应为 multipart/alternative，得到 %q (%v)", mediaType, err)
+	}
+
+	// multipart/alternative: text/plain 在前，multipart/related 在后
+	alternative := multipart.NewReader(msg.Body, params["boundary"])
+	textPart, err := alternative.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := textPart.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("第一部分应为 text/plain，得到 %q", ct)
+	}
+	if text := string(readPart(t, textPart)); !strings.Contains(text, "共 3 个文件") || !strings.Contains(text, "主机: nas") {
+		t.Errorf("纯文本正文缺少内容: %q", text)
+	}
+
+	relatedPart, err := alternative.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ = mime.ParseMediaType(relatedPart.Header.Get("Content-Type"))
+	if mediaType != "multipart/related" {
+		t.Fatalf("第二部分应为 multipart/related，得到 %q", mediaType)
+	}
+	related := multipart.NewReader(relatedPart, params["boundary"])
+	htmlPart, err := related.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := htmlPart.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("related 第一部分应为 text/html，得到 %q", ct)
+	}
+	html := string(readPart(t, htmlPart))
+	for _, unsafe := range []string{"<script", "onclick", "javascript:"} {
+		if strings.Contains(html, unsafe) {
+			t.Errorf("HTML 正文应过滤 %q: %s", unsafe, html)
+		}
+	}
+	if !strings.Contains(html, "<p>共 <b>3</b> 个文件</p>") {
+		t.Errorf("HTML 正文应保留排版标签: %s", html)
+	}
+
+	imagePart, err := related.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := imagePart.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/png") {
+		t.Errorf("内嵌图片类型应为 image/png，得到 %q", ct)
+	}
+	cid := strings.Trim(imagePart.Header.Get("Content-ID"), "<>")
+	if cid == "" || !strings.Contains(html, `src="cid:`+cid+`"`) {
+		t.Errorf("HTML 应通过 cid:%s 引用内嵌图片: %s", cid, html)
+	}
+	if _, err := png.Decode(bytes.NewReader(readPart(t, imagePart))); err != nil {
+		t.Errorf("内嵌图片内容无效: %v", err)
+	}
+	if _, err := related.NextPart(); err != io.EOF {
+		t.Errorf("related 中应只有 HTML 和一张图片")
+	}
+}
+
+func TestEmailRejectedRecipientFailsAlone(t *testing.T) {
+	server := startSMTPServer(t, map[string]string{
+		"missing@example.com": "550 5.1.1 no such user",
+		"full@example.com":    "452 4.2.2 mailbox full",
+	})
+	targets := []string{"a@example.com", "missing@example.com", "full@example.com", "Bob <b@example.com>", "not-an-address"}
+	results := server.notifier().Send(context.Background(), &NotificationMessage{Title: "t", Content: "c"}, targets)
+	if len(results) != len(targets) {
+		t.Fatalf("每个收件人应有一条结果，得到 %d 条", len(results))
+	}
+
+	byTarget := map[string]Result{}
+	for _, r := range results {
+		byTarget[r.Target] = r
+	}
+	for _, target := range []string{"a@example.com", "Bob <b@example.com>"} {
+		if r := byTarget[target]; !r.Success || r.ProviderMessageID == "" {
+			t.Errorf("%s 应发送成功: %+v", target, r)
+		}
+	}
+	tests := []struct {
+		target    string
+		code      string
+		retryable bool
+	}{
+		{"missing@example.com", "SMTP_550", false},
+		{"full@example.com", "SMTP_452", true},
+		{"not-an-address", "", false},
+	}
+	for _, tt := range tests {
+		r := byTarget[tt.target]
+		if r.Success || r.ErrorCode != tt.code || r.Retryable != tt.retryable {
+			t.Errorf("%s: 应单独失败 (code=%s retryable=%v)，得到 %+v", tt.target, tt.code, tt.retryable, r)
+		}
+	}
+
+	rcpts, data := server.received()
+	if got := fmt.Sprint(rcpts); got != "[a@example.com b@example.com]" {
+		t.Errorf("服务器接受的收件人 = %s", got)
+	}
+	if len(data) != 1 {
+		t.Errorf("接受的收件人应在同一封邮件中，实际发送 %d 封", len(data))
+	}
+}
+
+func TestEmailAllRecipientsRejected(t *testing.T) {
+	server := startSMTPServer(t, map[string]string{"x@example.com": "550 no such user"})
+	results := server.notifier().Send(context.Background(), &NotificationMessage{Content: "c"}, []string{"x@example.com"})
+	if results[0].Success || results[0].ErrorCode != "SMTP_550" {
+		t.Errorf("收件人被拒绝时应失败: %+v", results[0])
+	}
+	if _, data := server.received(); len(data) != 0 {
+		t.Errorf("没有收件人被接受时不应发送 DATA")
+	}
+}
@@ -1,36 +1,74 @@
 package notifier
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jianxcao/notify/backend/pkg/config"
 	"github.com/jianxcao/notify/backend/pkg/logger"
 
-	"github.com/go-resty/resty/v2"
 	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
 )
 
+// FeishuAppBot 飞书应用机器人
+const FeishuAppBot config.NotifiersType = "feishuAppBot"
+
+// FeishuConfig 飞书配置
+type FeishuConfig struct {
+	Enabled   bool   `yaml:"enabled" json:"enabled"`
+	AppID     string `yaml:"app_id" json:"appId"`         // 飞书应用ID
+	AppSecret string `yaml:"app_secret" json:"appSecret"` // 飞书应用密钥
+
+	Targets string `yaml:"targets" json:"targets"` // 默认发送目标(用户ID或群ID)
+	Proxy   string `yaml:"proxy" json:"proxy"`     // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(FeishuAppBot, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg FeishuConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewFeishuNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "飞书",
+		Description: "在飞书开放平台 https://open.feishu.cn 创建企业自建应用并开通 im:message 权限；消息中的 targets 为 open_id（ou_）、union_id（on_）、chat_id（oc_）、user_id 或邮箱，按格式自动识别",
+		Fields: []FieldSchema{
+			{Name: "app_id", Label: "应用 ID", Type: "string", Required: true, Hint: "飞书应用的 App ID"},
+			{Name: "app_secret", Label: "应用密钥", Type: "password", Required: true, Secret: true, Hint: "飞书应用的 App Secret"},
+			{Name: "targets", Label: "目标用户", Type: "string", Hint: "接收者ID，支持多种类型，多个用逗号分隔（可选）"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// 发送消息接口 50 次/秒，同一用户或群 5 次/秒
+		RateLimit: &config.RateLimit{Rate: "50/s", PerTarget: "5/s"},
+	})
+}
+
 // FeishuNotifier 飞书通知服务
 type FeishuNotifier struct {
-	config     config.FeishuConfig
+	config     FeishuConfig
 	larkClient *lark.Client // 飞书官方SDK客户端
 }
 
 // 不再需要额外的响应结构，直接使用官方SDK的响应
 
 // NewFeishuNotifier 创建飞书通知服务实例
-func NewFeishuNotifier(cfg config.FeishuConfig) *FeishuNotifier {
+func NewFeishuNotifier(cfg FeishuConfig) *FeishuNotifier {
 	notifier := &FeishuNotifier{
 		config: cfg,
 	}
 
 	// 初始化飞书官方SDK客户端
 	if cfg.AppID != "" && cfg.AppSecret != "" {
-		notifier.larkClient = lark.NewClient(cfg.AppID, cfg.AppSecret)
+		notifier.larkClient = lark.NewClient(cfg.AppID, cfg.AppSecret, lark.WithHttpClient(dryRunHTTPClient()))
 	}
 
 	return notifier
@@ -38,7 +76,7 @@ func NewFeishuNotifier(cfg config.FeishuConfig) *FeishuNotifier {
 
 // Name 返回服务名称
 func (f *FeishuNotifier) Name() string {
-	return string(config.FeishuAppBot)
+	return string(FeishuAppBot)
 }
 
 // IsEnabled 检查服务是否启用
@@ -60,10 +98,26 @@ func (f *FeishuNotifier) Validate() error {
 	return nil
 }
 
+// CheckCredentials 获取 tenant_access_token，检查应用 ID 和应用密钥
+func (f *FeishuNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	resp, err := f.larkClient.GetTenantAccessTokenBySelfBuiltApp(ctx, &larkcore.SelfBuiltTenantAccessTokenReq{
+		AppID:     f.config.AppID,
+		AppSecret: f.config.AppSecret,
+	})
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if !resp.Success() {
+		return "", providerError(resp.Code, "获取访问令牌失败: "+resp.Msg, false)
+	}
+	return "获取访问令牌成功", nil
+}
+
 // Send 发送通知消息
-func (f *FeishuNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) error {
+func (f *FeishuNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	start := time.Now()
 	if !f.config.Enabled {
-		return fmt.Errorf("飞书通知服务未启用")
+		return resultsFor(targets, start, "", permanentError("飞书通知服务未启用"))
 	}
 
 	if len(targets) == 0 && f.config.Targets != "" {
@@ -72,28 +126,39 @@ func (f *FeishuNotifier) Send(ctx context.Context, message *NotificationMessage,
 
 	// 使用飞书官方API发送消息
 	if f.larkClient == nil {
-		return fmt.Errorf("飞书客户端未初始化，请检查AppID和AppSecret配置")
+		return resultsFor(targets, start, "", permanentError("飞书客户端未初始化，请检查AppID和AppSecret配置"))
 	}
 
 	return f.sendAPIMessage(ctx, message, targets)
 }
 
+// feishuRetryable 飞书可以重试的错误码：内部错误、请求频率超限
+func feishuRetryable(code int) bool {
+	switch code {
+	case 1000004, 1000005, 99991400, 230020:
+		return true
+	}
+	return false
+}
+
 // sendAPIMessage 通过API发送消息（应用机器人）
-func (f *FeishuNotifier) sendAPIMessage(ctx context.Context, message *NotificationMessage, targets []string) error {
+func (f *FeishuNotifier) sendAPIMessage(ctx context.Context, message *NotificationMessage, targets []string) Results {
 	// 如果没有指定目标，尝试发送到配置的默认目标
 	if len(targets) == 0 {
-		return fmt.Errorf("未指定消息发送目标")
+		return Results{NewResult("", time.Now(), "", permanentError("未指定消息发送目标"))}
 	}
 
+	// 构建消息内容，图片只需上传一次
+	msgType, content := f.buildAPIMessageContent(ctx, message)
+
 	// 为每个目标发送消息
+	results := make(Results, 0, len(targets))
 	for _, target := range targets {
 		target = strings.TrimSpace(target)
 		if target == "" {
 			continue
 		}
-
-		// 构建消息内容
-		content := f.buildAPIMessageContent(message)
+		start := time.Now()
 
 		// 判断目标类型并设置接收者ID类型
 		receiveIdType := f.getReceiveIdType(target)
@@ -103,7 +168,7 @@ func (f *FeishuNotifier) sendAPIMessage(ctx context.Context, message *Notificati
 			ReceiveIdType(receiveIdType).
 			Body(larkim.NewCreateMessageReqBodyBuilder().
 				ReceiveId(target).
-				MsgType("post"). // 使用rich_text支持更丰富的格式
+				MsgType(msgType).
 				Content(content).
 				Build()).
 			Build()
@@ -111,49 +176,73 @@ func (f *FeishuNotifier) sendAPIMessage(ctx context.Context, message *Notificati
 		// 发起请求
 		resp, err := f.larkClient.Im.V1.Message.Create(ctx, req)
 		if err != nil {
-			return fmt.Errorf("发送消息到 %s 失败: %w", target, err)
+			results = append(results, NewResult(target, start, "", fmt.Errorf("发送消息失败: %w", err)))
+			continue
 		}
 
 		// 检查响应
 		if !resp.Success() {
-			logger.Error("resp", resp.Err)
-			logger.Error("err", resp.Code)
-			logger.Error("err", resp.Msg)
-			return fmt.Errorf("发送消息到 %s 失败: %s", target, resp.Msg)
+			logger.Error("发送飞书消息失败", "target", target, "code", resp.Code, "msg", resp.Msg)
+			results = append(results, NewResult(target, start, "", providerError(resp.Code, "发送消息失败: "+resp.Msg, feishuRetryable(resp.Code))))
+			continue
 		}
+
+		messageID := ""
+		if resp.Data != nil && resp.Data.MessageId != nil {
+			messageID = *resp.Data.MessageId
+		}
+		results = append(results, NewResult(target, start, messageID, nil))
 	}
 
-	return nil
+	return results
 }
 
-// buildAPIMessageContent 构建API消息内容（rich_text格式）
-func (f *FeishuNotifier) buildAPIMessageContent(message *NotificationMessage) string {
+// buildAPIMessageContent 构建API消息内容
+//
+// 普通消息使用富文本（post）；带级别、键值字段、多张图片或多个按钮时使用消息卡片（interactive）。
+func (f *FeishuNotifier) buildAPIMessageContent(ctx context.Context, message *NotificationMessage) (string, string) {
+	images := f.uploadImages(ctx, message.AllImages())
+	if message.Level != "" || len(message.Fields) > 0 || len(message.AllImages()) > 1 || len(message.AllActions()) > 1 {
+		contentBytes, _ := json.Marshal(f.buildCard(message, images))
+		return "interactive", string(contentBytes)
+	}
+
 	// 构建富文本内容
 	content := map[string]interface{}{
 		"zh_cn": map[string]interface{}{
 			"title":   message.Title,
-			"content": f.buildRichTextElements(message),
+			"content": f.buildRichTextElements(message, images),
 		},
 	}
 
 	// 转换为JSON字符串
 	contentBytes, _ := json.Marshal(content)
-	return string(contentBytes)
+	return "post", string(contentBytes)
+}
+
+// feishuImageLimits 飞书上传图片限制：最大 10MB
+var feishuImageLimits = ImageLimits{MaxBytes: 10 << 20, Formats: []string{ImageJPEG, ImagePNG, ImageGIF, ImageWebP}}
+
+// ImageLimits 图片链接由服务端下载后上传，限制与上传相同
+func (f *FeishuNotifier) ImageLimits() ImageLimits {
+	return feishuImageLimits
 }
 
-func (f *FeishuNotifier) buildImageElement(message *NotificationMessage) (string, error) {
-	image, err := resty.New().R().SetDoNotParseResponse(true).Get(message.Image)
+// uploadImage 下载图片并上传到飞书，返回 image_key
+func (f *FeishuNotifier) uploadImage(ctx context.Context, imageURL string) (string, error) {
+	// 支持 data: URI 和内网图片，由服务端获取后上传
+	image, err := FetchImage(ctx, imageURL, feishuImageLimits)
 	if err != nil {
 		return "", err
 	}
 	req := larkim.NewCreateImageReqBuilder().
 		Body(larkim.NewCreateImageReqBodyBuilder().
 			ImageType("message").
-			Image(image.RawBody()).
+			Image(bytes.NewReader(image.Data)).
 			Build()).
 		Build()
 
-	resp, err := f.larkClient.Im.Image.Create(context.Background(), req)
+	resp, err := f.larkClient.Im.Image.Create(ctx, req)
 	if err != nil {
 		return "", err
 	}
@@ -164,14 +253,24 @@ func (f *FeishuNotifier) buildImageElement(message *NotificationMessage) (string
 	return *resp.Data.ImageKey, nil
 }
 
-// buildRichTextElements 构建富文本元素
-func (f *FeishuNotifier) buildRichTextElements(message *NotificationMessage) [][]map[string]interface{} {
-	elements := [][]map[string]interface{}{}
-	if message.Image != "" {
-		imageKey, err := f.buildImageElement(message)
+// uploadImages 上传所有图片，上传失败的图片会被忽略
+func (f *FeishuNotifier) uploadImages(ctx context.Context, images []string) []string {
+	keys := make([]string, 0, len(images))
+	for _, image := range images {
+		key, err := f.uploadImage(ctx, image)
 		if err != nil {
-			logger.Error("buildImageElement", err)
+			logger.Error("上传飞书图片失败", "image", image, "error", err)
+			continue
 		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// buildRichTextElements 构建富文本元素
+func (f *FeishuNotifier) buildRichTextElements(message *NotificationMessage, images []string) [][]map[string]interface{} {
+	elements := [][]map[string]interface{}{}
+	for _, imageKey := range images {
 		elements = append(elements, []map[string]interface{}{
 			{
 				"tag":       "img",
@@ -179,16 +278,9 @@ func (f *FeishuNotifier) buildRichTextElements(message *NotificationMessage) [][
 			},
 		})
 	}
-	// 添加内容行
+	// 添加内容行，markdown 转换为富文本段落
 	if message.Content != "" {
-		// 按行分割内容
-		content := []map[string]interface{}{
-			{
-				"tag":  "md",
-				"text": message.Content,
-			},
-		}
-		elements = append(elements, content)
+		elements = append(elements, message.FeishuPostContent()...)
 	}
 
 	// 添加时间戳
@@ -203,12 +295,12 @@ func (f *FeishuNotifier) buildRichTextElements(message *NotificationMessage) [][
 	}
 
 	// 添加链接
-	if message.URL != "" {
+	for _, action := range message.AllActions() {
 		linkElement := []map[string]interface{}{
 			{
 				"tag":  "a",
-				"text": "查看详情",
-				"href": message.URL,
+				"text": action.Label,
+				"href": action.URL,
 			},
 		}
 		elements = append(elements, linkElement)
@@ -217,6 +309,90 @@ func (f *FeishuNotifier) buildRichTextElements(message *NotificationMessage) [][
 	return elements
 }
 
+// feishuCardColors 消息级别对应的卡片标题颜色
+var feishuCardColors = map[Level]string{
+	LevelInfo:     "blue",
+	LevelSuccess:  "green",
+	LevelWarning:  "orange",
+	LevelError:    "red",
+	LevelCritical: "carmine",
+}
+
+// buildCard 构建消息卡片：标题栏颜色表示级别，字段并排显示，按钮放在底部
+func (f *FeishuNotifier) buildCard(message *NotificationMessage, images []string) map[string]interface{} {
+	elements := []map[string]interface{}{}
+
+	if message.Content != "" {
+		if message.Format == FormatPlain || message.Format == FormatHTML {
+			elements = append(elements, map[string]interface{}{
+				"tag":  "div",
+				"text": map[string]interface{}{"tag": "plain_text", "content": message.PlainContent()},
+			})
+		} else {
+			elements = append(elements, map[string]interface{}{
+				"tag":     "markdown",
+				"content": message.RenderContent(DialectLarkMd),
+			})
+		}
+	}
+
+	if len(message.Fields) > 0 {
+		fields := make([]map[string]interface{}, len(message.Fields))
+		for i, field := range message.Fields {
+			fields[i] = map[string]interface{}{
+				"is_short": true,
+				"text":     map[string]interface{}{"tag": "lark_md", "content": "**" + field.Key + "**\n" + field.Value},
+			}
+		}
+		elements = append(elements, map[string]interface{}{"tag": "div", "fields": fields})
+	}
+
+	for _, imageKey := range images {
+		elements = append(elements, map[string]interface{}{
+			"tag":     "img",
+			"img_key": imageKey,
+			"alt":     map[string]interface{}{"tag": "plain_text", "content": ""},
+		})
+	}
+
+	if actions := message.AllActions(); len(actions) > 0 {
+		buttons := make([]map[string]interface{}, len(actions))
+		for i, action := range actions {
+			buttonType := "default"
+			if i == 0 {
+				buttonType = "primary"
+			}
+			buttons[i] = map[string]interface{}{
+				"tag":  "button",
+				"text": map[string]interface{}{"tag": "plain_text", "content": action.Label},
+				"url":  action.URL,
+				"type": buttonType,
+			}
+		}
+		elements = append(elements, map[string]interface{}{"tag": "action", "actions": buttons})
+	}
+
+	if message.Timestamp != "" {
+		elements = append(elements, map[string]interface{}{
+			"tag":      "note",
+			"elements": []map[string]interface{}{{"tag": "plain_text", "content": "时间: " + message.Timestamp}},
+		})
+	}
+
+	color := feishuCardColors[message.Level]
+	if color == "" {
+		color = "blue"
+	}
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true},
+		"header": map[string]interface{}{
+			"title":    map[string]interface{}{"tag": "plain_text", "content": message.DisplayTitle()},
+			"template": color,
+		},
+		"elements": elements,
+	}
+}
+
 // getReceiveIdType 根据目标格式判断接收者ID类型
 // 根据飞书官方文档：https://open.feishu.cn/document/server-docs/im-v1/message/create
 func (f *FeishuNotifier) getReceiveIdType(target string) string {
@@ -0,0 +1,391 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册 gif 解码
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// MaxImageSize 下载或接收的图片最大字节数
+const MaxImageSize = 20 << 20
+
+// 常见图片 MIME 类型
+const (
+	ImageJPEG = "image/jpeg"
+	ImagePNG  = "image/png"
+	ImageGIF  = "image/gif"
+	ImageWebP = "image/webp"
+)
+
+// ImageData 图片内容
+type ImageData struct {
+	Data        []byte
+	ContentType string
+	Filename    string
+}
+
+// ImageLimits 通知渠道上传图片的限制
+type ImageLimits struct {
+	MaxBytes     int      // 最大字节数，0 表示不限制
+	Formats      []string // 支持的 MIME 类型，为空表示不限制
+	MaxDimension int      // 最长边的最大像素，0 表示不限制
+}
+
+// ImageLimiter 可选接口：声明渠道获取图片的限制，图片缓存按该限制转换图片后再生成公网链接
+type ImageLimiter interface {
+	ImageLimits() ImageLimits
+}
+
+// ImageLimitsOf 返回通知服务声明的图片限制，未实现 ImageLimiter 时不限制
+func ImageLimitsOf(n Notifier) ImageLimits {
+	if l, ok := n.(ImageLimiter); ok {
+		return l.ImageLimits()
+	}
+	return ImageLimits{}
+}
+
+var imageClient = resty.New().SetTimeout(30 * time.Second)
+
+// IsDataURI 判断是否为 data: URI
+func IsDataURI(src string) bool {
+	return strings.HasPrefix(strings.ToLower(src), "data:")
+}
+
+// EncodeDataURI 将图片编码为 base64 的 data: URI
+func EncodeDataURI(data []byte, contentType string) string {
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// ParseDataURI 解析 base64 编码的 data: URI
+func ParseDataURI(src string) (*ImageData, error) {
+	header, payload, ok := strings.Cut(src, ",")
+	if !ok || !IsDataURI(header) {
+		return nil, fmt.Errorf("无效的 data URI")
+	}
+	if !strings.HasSuffix(strings.ToLower(header), ";base64") {
+		return nil, fmt.Errorf("data URI 必须使用 base64 编码")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(payload))
+	if err != nil {
+		return nil, fmt.Errorf("解码 data URI 失败: %w", err)
+	}
+	return newImageData(data, "")
+}
+
+// newImageData 校验图片大小并根据内容识别图片类型
+func newImageData(data []byte, filename string) (*ImageData, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("图片内容为空")
+	}
+	if len(data) > MaxImageSize {
+		return nil, fmt.Errorf("图片大小 %d 字节超过限制 %d 字节", len(data), MaxImageSize)
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("不是图片: %s", contentType)
+	}
+	img := &ImageData{Data: data, ContentType: contentType, Filename: filename}
+	img.fixFilename()
+	return img, nil
+}
+
+// fixFilename 保证文件名的扩展名与图片类型一致，部分渠道按扩展名识别图片格式
+func (img *ImageData) fixFilename() {
+	ext := map[string]string{ImageJPEG: ".jpg", ImagePNG: ".png", ImageGIF: ".gif", ImageWebP: ".webp"}[img.ContentType]
+	name := strings.TrimSuffix(img.Filename, path.Ext(img.Filename))
+	if name == "" || name == "." || name == "/" {
+		name = "image"
+	}
+	img.Filename = name + ext
+}
+
+// LoadImage 获取图片内容，支持 data: URI 和 http(s) 地址（由服务端下载，预览模式下不下载）
+func LoadImage(ctx context.Context, src string) (*ImageData, error) {
+	if IsDataURI(src) {
+		return ParseDataURI(src)
+	}
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("不支持的图片地址: %s", src)
+	}
+	if IsDryRun(ctx) {
+		// 预览模式不下载图片，使用占位图片构造请求
+		return newImageData(dryRunImage, path.Base(u.Path))
+	}
+
+	resp, err := imageClient.R().SetContext(ctx).SetDoNotParseResponse(true).Get(src)
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %w", err)
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("下载图片失败，状态码: %d", resp.StatusCode())
+	}
+	// 多读一个字节用于判断是否超过大小限制
+	data, err := io.ReadAll(io.LimitReader(body, MaxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %w", err)
+	}
+	return newImageData(data, path.Base(u.Path))
+}
+
+// NeedsUpload 判断图片是否需要由服务端获取后上传到渠道：
+// data: URI，以及指向内网、本机或无法解析的地址（渠道服务器无法访问）
+func NeedsUpload(ctx context.Context, src string) bool {
+	if IsDataURI(src) {
+		return true
+	}
+	u, err := url.Parse(src)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	return isPrivateHost(ctx, u.Hostname())
+}
+
+// isPrivateHost 判断主机是否为内网地址
+func isPrivateHost(ctx context.Context, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return isPrivateIP(ip)
+	}
+	// 没有域名后缀的主机名（例如 nas）和常见的局域网后缀
+	if !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range []string{".local", ".lan", ".home", ".internal", ".localdomain", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		// 本机无法解析时按公网地址处理，交给渠道自行下载
+		return false
+	}
+	for _, addr := range addrs {
+		if !isPrivateIP(addr.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		// 100.64.0.0/10 运营商级 NAT（Tailscale 等组网常用）
+		(ip.To4() != nil && ip.To4()[0] == 100 && ip.To4()[1]&0xc0 == 64)
+}
+
+// maxDecodePixels 允许解码转换的最大像素数（4000 万像素，解码后约 160MB）
+const maxDecodePixels = 40_000_000
+
+// Normalize 按渠道限制转换图片：不支持的格式转换为 JPEG，超出尺寸时等比缩小，超出大小时逐步压缩
+func (img *ImageData) Normalize(limits ImageLimits) (*ImageData, error) {
+	supported := len(limits.Formats) == 0
+	for _, format := range limits.Formats {
+		if format == img.ContentType {
+			supported = true
+			break
+		}
+	}
+	// 只解析图片头获取尺寸，无法解析的格式（例如 webp）不缩放
+	cfg, _, cfgErr := image.DecodeConfig(bytes.NewReader(img.Data))
+	oversized := cfgErr == nil && limits.MaxDimension > 0 && max(cfg.Width, cfg.Height) > limits.MaxDimension
+	if supported && !oversized && (limits.MaxBytes <= 0 || len(img.Data) <= limits.MaxBytes) {
+		return img, nil
+	}
+
+	// 解码后每个像素占用 4 字节以上，文件很小的图片也可能声明极大的尺寸，解码前拒绝
+	if cfgErr == nil && int64(cfg.Width)*int64(cfg.Height) > maxDecodePixels {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 过大，超过 %d 万像素", cfg.Width, cfg.Height, maxDecodePixels/10000)
+	}
+	src, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("图片格式 %s 不受支持且无法转换: %w", img.ContentType, err)
+	}
+	if oversized {
+		bounds := src.Bounds()
+		scale := float64(limits.MaxDimension) / float64(max(bounds.Dx(), bounds.Dy()))
+		src = ResizeImage(src, int(float64(bounds.Dx())*scale), int(float64(bounds.Dy())*scale))
+	}
+
+	// 保留透明通道时使用 PNG，否则统一转为 JPEG（体积更小，所有渠道都支持）
+	usePNG := img.ContentType == ImagePNG && supported
+	quality := 90
+	for i := 0; i < 8; i++ {
+		var buf bytes.Buffer
+		if usePNG {
+			err = png.Encode(&buf, src)
+		} else {
+			err = jpeg.Encode(&buf, flattenImage(src), &jpeg.Options{Quality: quality})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("转换图片失败: %w", err)
+		}
+		if limits.MaxBytes <= 0 || buf.Len() <= limits.MaxBytes {
+			out := &ImageData{Data: buf.Bytes(), ContentType: ImageJPEG, Filename: img.Filename}
+			if usePNG {
+				out.ContentType = ImagePNG
+			}
+			out.fixFilename()
+			return out, nil
+		}
+		// 先降低 JPEG 质量，仍然过大时缩小尺寸
+		if !usePNG && quality > 70 {
+			quality -= 10
+			continue
+		}
+		bounds := src.Bounds()
+		src = ResizeImage(src, bounds.Dx()*3/4, bounds.Dy()*3/4)
+	}
+	return nil, fmt.Errorf("图片压缩后仍超过 %d 字节", limits.MaxBytes)
+}
+
+// flattenImage 将带透明通道的图片合成到白色背景上，JPEG 不支持透明
+func flattenImage(src image.Image) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Over)
+	return dst
+}
+
+// ResizeImage 按区域平均缩小图片到指定尺寸，尺寸不小于 1 像素
+func ResizeImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	width, height = max(width, 1), max(height, 1)
+	if width >= bounds.Dx() && height >= bounds.Dy() {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// FetchImage 获取图片并按渠道限制转换
+func FetchImage(ctx context.Context, src string, limits ImageLimits) (*ImageData, error) {
+	img, err := LoadImage(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return img.Normalize(limits)
+}
+
+// SplitImageList 拆分以逗号或换行分隔的图片列表，data: URI 中 base64 前的逗号不作为分隔符
+func SplitImageList(s string) []string {
+	var images []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if n := len(images); n > 0 && IsDataURI(images[n-1]) && !strings.Contains(images[n-1], ",") {
+			images[n-1] += "," + item
+			continue
+		}
+		if item != "" {
+			images = append(images, item)
+		}
+	}
+	return images
+}
+
+// ResolveImages 将需要上传的图片获取并上传到渠道，返回替换为上传结果（media_id 等）的消息副本；
+// 上传失败的图片会被去掉并记录日志，不影响消息正文的发送
+func ResolveImages(ctx context.Context, message *NotificationMessage, limits ImageLimits, upload func(context.Context, *ImageData) (string, error)) *NotificationMessage {
+	uploaded := map[string]string{} // 同一张图片只上传一次
+	resolve := func(src string) string {
+		if src == "" || !NeedsUpload(ctx, src) {
+			return src
+		}
+		if ref, ok := uploaded[src]; ok {
+			return ref
+		}
+		img, err := FetchImage(ctx, src, limits)
+		if err == nil {
+			var ref string
+			if ref, err = upload(ctx, img); err == nil {
+				uploaded[src] = ref
+				return ref
+			}
+		}
+		logger.Error("上传图片失败", "image", AbbreviateImage(src), "error", err)
+		uploaded[src] = ""
+		return ""
+	}
+
+	resolved := *message
+	resolved.Image = resolve(message.Image)
+	resolved.Images = nil
+	for _, image := range message.Images {
+		if image = resolve(image); image != "" {
+			resolved.Images = append(resolved.Images, image)
+		}
+	}
+	return &resolved
+}
+
+// AbbreviateImage 缩短日志中的 data: URI
+func AbbreviateImage(src string) string {
+	if IsDataURI(src) && len(src) > 64 {
+		return src[:64] + "..."
+	}
+	return src
+}
+
+// SeparateUploadImages 拆出需要上传的图片，返回只保留公网图片的消息副本和需要上传的图片列表，
+// 用于图文消息只支持图片地址、需要把上传的图片作为单独消息发送的渠道
+func SeparateUploadImages(ctx context.Context, message *NotificationMessage) (*NotificationMessage, []string) {
+	var uploads []string
+	remote := *message
+	remote.Image = ""
+	remote.Images = nil
+	for _, image := range message.AllImages() {
+		if NeedsUpload(ctx, image) {
+			uploads = append(uploads, image)
+		} else {
+			remote.Images = append(remote.Images, image)
+		}
+	}
+	if len(uploads) == 0 {
+		return message, nil
+	}
+	return &remote, uploads
+}
@@ -9,6 +9,12 @@ type NotificationMessage struct {
 	Timestamp string `json:"timestamp"`
 	Image     string `json:"image"` // 图片URL或路径
 	URL       string `json:"url"`   // 点击跳转的URL
+
+	Format  ContentFormat `json:"format,omitempty"`  // 内容格式，为空时由通知服务使用默认格式
+	Level   Level         `json:"level,omitempty"`   // 消息级别
+	Images  []string      `json:"images,omitempty"`  // Image 之外的更多图片
+	Actions []Action      `json:"actions,omitempty"` // 按钮
+	Fields  []Field       `json:"fields,omitempty"`  // 键值字段
 }
 
 // Notifier 通知服务接口
@@ -16,8 +22,8 @@ type Notifier interface {
 	// Name 返回通知服务的名称
 	Name() string
 
-	// Send 发送通知消息
-	Send(ctx context.Context, message *NotificationMessage, targets []string) error
+	// Send 发送通知消息，返回每个目标的发送结果；targets 为空时发送到通知服务配置的默认目标
+	Send(ctx context.Context, message *NotificationMessage, targets []string) Results
 
 	// IsEnabled 检查服务是否启用
 	IsEnabled() bool
@@ -26,6 +32,18 @@ type Notifier interface {
 	Validate() error
 }
 
+// TargetGrouper 可选接口：实现该接口且返回 true 的通知服务把 targets 作为一个整体随同一条消息发送
+// （例如钉钉的 @ 列表），投递时不能按目标拆分
+type TargetGrouper interface {
+	GroupTargets() bool
+}
+
+// CredentialChecker 可选接口：不发送消息，调用服务商接口（例如获取访问令牌）检查凭证是否有效，
+// 成功时返回凭证对应的账号等说明
+type CredentialChecker interface {
+	CheckCredentials(ctx context.Context) (string, error)
+}
+
 // NotificationTarget 通知目标
 type NotificationTarget struct {
 	Type string `json:"type"` // user, group, channel等
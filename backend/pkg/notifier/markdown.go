@@ -0,0 +1,552 @@
+package notifier
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Dialect 各通知渠道支持的 markdown 方言
+//
+// 模板只需按常用 markdown 语法编写一次，发送时解析为统一的结构后再按渠道输出：
+// 标题、粗体、斜体、删除线、行内代码、代码块、链接、列表、引用和分隔线。
+type Dialect int
+
+const (
+	DialectPlain        Dialect = iota // 纯文本，去掉所有标记
+	DialectMarkdown                    // 标准 markdown（企业微信群机器人 markdown_v2 等）
+	DialectTelegramV2                  // Telegram MarkdownV2，所有特殊字符都需要转义
+	DialectTelegramHTML                // Telegram HTML，只支持部分标签
+	DialectHTML                        // 通用 HTML（邮件等）
+	DialectLarkMd                      // 飞书卡片 lark_md，不支持标题
+	DialectWechatWork                  // 企业微信应用 markdown，不支持斜体、删除线和代码块
+	DialectDingTalk                    // 钉钉 markdown，需要空行才能换行
+	DialectSlack                       // Slack mrkdwn，单个 * 为粗体，链接为 <url|文本>，需要转义 & < >
+)
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockBlank
+	blockHeading
+	blockListItem
+	blockQuote
+	blockCode
+	blockRule
+)
+
+// mdBlock 一行（代码块为多行）内容
+type mdBlock struct {
+	kind    blockKind
+	level   int    // 标题级别或列表缩进层级
+	marker  string // 有序列表的序号，如 "1."；无序列表为空
+	lang    string // 代码块语言
+	code    string // 代码块内容
+	inlines []mdNode
+}
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeBold
+	nodeItalic
+	nodeStrike
+	nodeCode
+	nodeLink
+)
+
+// mdNode 行内元素
+type mdNode struct {
+	kind     nodeKind
+	text     string // 文本或代码内容
+	url      string // 链接地址
+	children []mdNode
+}
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	unorderedPattern = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	orderedPattern   = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	quotePattern     = regexp.MustCompile(`^>\s?(.*)$`)
+	rulePattern      = regexp.MustCompile(`^\s*(-{3,}|\*{3,}|_{3,})\s*$`)
+	codeFencePattern = regexp.MustCompile("^\\s*```\\s*([\\w+-]*)\\s*$")
+	slackReplacer    = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`)
+	markdownReplacer = strings.NewReplacer(
+		`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `[`, `\[`, `]`, `\]`, `<`, `\<`,
+	)
+	// 钉钉和企业微信应用只支持部分样式，只转义会被识别的标记
+	dingTalkReplacer   = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `[`, `\[`, `]`, `\]`)
+	wechatWorkReplacer = strings.NewReplacer(`\`, `\\`, `*`, `\*`, "`", "\\`", `[`, `\[`, `]`, `\]`)
+	// 飞书 lark_md 不支持反斜杠转义，使用 HTML 实体
+	larkMdReplacer = strings.NewReplacer(
+		`*`, `&#42;`, `_`, `&#95;`, `~`, `&#126;`, "`", `&#96;`, `[`, `&#91;`, `]`, `&#93;`, `<`, `&lt;`, `>`, `&gt;`,
+	)
+	telegramV2Replacer = strings.NewReplacer(
+		`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `~`, `\~`, "`", "\\`",
+		`>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+	)
+)
+
+// parseMarkdown 将 markdown 文本按行解析为块
+func parseMarkdown(src string) []mdBlock {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	blocks := make([]mdBlock, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := codeFencePattern.FindStringSubmatch(line); m != nil {
+			// 代码块一直到结束标记，没有结束标记时到文本末尾
+			var code []string
+			for i++; i < len(lines) && !codeFencePattern.MatchString(lines[i]); i++ {
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, mdBlock{kind: blockCode, lang: m[1], code: strings.Join(code, "\n")})
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			blocks = append(blocks, mdBlock{kind: blockBlank})
+		case rulePattern.MatchString(line):
+			blocks = append(blocks, mdBlock{kind: blockRule})
+		default:
+			if m := headingPattern.FindStringSubmatch(line); m != nil {
+				blocks = append(blocks, mdBlock{kind: blockHeading, level: len(m[1]), inlines: parseInline(m[2])})
+			} else if m := unorderedPattern.FindStringSubmatch(line); m != nil {
+				blocks = append(blocks, mdBlock{kind: blockListItem, level: len(m[1]) / 2, inlines: parseInline(m[2])})
+			} else if m := orderedPattern.FindStringSubmatch(line); m != nil {
+				blocks = append(blocks, mdBlock{kind: blockListItem, level: len(m[1]) / 2, marker: m[2] + ".", inlines: parseInline(m[3])})
+			} else if m := quotePattern.FindStringSubmatch(line); m != nil {
+				blocks = append(blocks, mdBlock{kind: blockQuote, inlines: parseInline(m[1])})
+			} else {
+				blocks = append(blocks, mdBlock{kind: blockParagraph, inlines: parseInline(line)})
+			}
+		}
+	}
+	return blocks
+}
+
+// literalBlocks 将纯文本按行转换为块，不解析任何标记
+func literalBlocks(src string) []mdBlock {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	blocks := make([]mdBlock, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			blocks[i] = mdBlock{kind: blockBlank}
+		} else {
+			blocks[i] = mdBlock{kind: blockParagraph, inlines: []mdNode{{kind: nodeText, text: line}}}
+		}
+	}
+	return blocks
+}
+
+// parseInline 解析行内元素；找不到配对结束标记的符号按普通文本处理
+func parseInline(s string) []mdNode {
+	var nodes []mdNode
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() > 0 {
+			nodes = append(nodes, mdNode{kind: nodeText, text: buf.String()})
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!~>|", s[i+1]) >= 0:
+			buf.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				flush()
+				nodes = append(nodes, mdNode{kind: nodeCode, text: s[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], "**") || strings.HasPrefix(s[i:], "__"):
+			if end := findClosing(s, i+2, s[i:i+2]); end > 0 {
+				flush()
+				nodes = append(nodes, mdNode{kind: nodeBold, children: parseInline(s[i+2 : end])})
+				i = end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], "~~"):
+			if end := findClosing(s, i+2, "~~"); end > 0 {
+				flush()
+				nodes = append(nodes, mdNode{kind: nodeStrike, children: parseInline(s[i+2 : end])})
+				i = end + 2
+				continue
+			}
+		case c == '*' || (c == '_' && (i == 0 || !isWordByte(s[i-1]))):
+			// 下划线只在单词边界生效，避免 snake_case 之类的文本被当作斜体
+			if end := findClosing(s, i+1, string(c)); end > 0 && (c == '*' || end+1 >= len(s) || !isWordByte(s[end+1])) {
+				flush()
+				nodes = append(nodes, mdNode{kind: nodeItalic, children: parseInline(s[i+1 : end])})
+				i = end + 1
+				continue
+			}
+		case c == '[':
+			if mid := strings.Index(s[i:], "]("); mid > 1 {
+				if end := linkEnd(s[i+mid+2:]); end > 0 {
+					flush()
+					text := s[i+1 : i+mid]
+					url := s[i+mid+2 : i+mid+2+end]
+					nodes = append(nodes, mdNode{kind: nodeLink, url: url, children: parseInline(text)})
+					i += mid + 2 + end + 1
+					continue
+				}
+			}
+		}
+		buf.WriteByte(c)
+		i++
+	}
+	flush()
+	return nodes
+}
+
+// linkEnd 查找链接地址的结束括号，地址中可以包含成对的括号，例如 https://en.wikipedia.org/wiki/Foo_(bar)
+func linkEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// findClosing 查找结束标记的位置，标记内的内容不能为空且不能以空格开头或结尾
+func findClosing(s string, start int, marker string) int {
+	if start >= len(s) || s[start] == ' ' {
+		return -1
+	}
+	for i := start + 1; i+len(marker) <= len(s); i++ {
+		if s[i:i+len(marker)] != marker || s[i-1] == ' ' {
+			continue
+		}
+		// 单个 * 不能匹配 ** 的一部分
+		if len(marker) == 1 && i+1 < len(s) && s[i+1] == marker[0] {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// RenderMarkdown 将常用 markdown 转换为指定渠道的方言
+func RenderMarkdown(src string, d Dialect) string {
+	return renderBlocks(parseMarkdown(src), d)
+}
+
+// EscapeText 将纯文本转义为指定方言中的字面文本
+func EscapeText(s string, d Dialect) string {
+	switch d {
+	case DialectTelegramV2:
+		return telegramV2Replacer.Replace(s)
+	case DialectTelegramHTML, DialectHTML:
+		return html.EscapeString(s)
+	case DialectSlack:
+		return slackReplacer.Replace(s)
+	case DialectMarkdown:
+		return markdownReplacer.Replace(s)
+	case DialectLarkMd:
+		return larkMdReplacer.Replace(s)
+	case DialectDingTalk:
+		return dingTalkReplacer.Replace(s)
+	case DialectWechatWork:
+		return wechatWorkReplacer.Replace(s)
+	}
+	return s
+}
+
+// escapeBlockStart 转义段落开头会被识别为标题、列表、引用或分隔线的字符，
+// 例如纯文本内容中的 "# 1" 或源文本中转义过的 "\- 项"
+func escapeBlockStart(line string, d Dialect) string {
+	escape := func(c string) string { return `\` + c }
+	switch d {
+	case DialectMarkdown, DialectDingTalk, DialectWechatWork:
+	case DialectLarkMd:
+		escape = func(c string) string { return fmt.Sprintf("&#%d;", c[0]) }
+	default:
+		return line
+	}
+
+	trimmed := strings.TrimLeft(line, " ")
+	indent := line[:len(line)-len(trimmed)]
+	switch {
+	case headingPattern.MatchString(trimmed), quotePattern.MatchString(trimmed),
+		unorderedPattern.MatchString(trimmed), rulePattern.MatchString(trimmed):
+		return indent + escape(trimmed[:1]) + trimmed[1:]
+	case orderedPattern.MatchString(trimmed):
+		// 序号后的 . 或 ) 转义后不再是有序列表
+		i := strings.IndexAny(trimmed, ".)")
+		return indent + trimmed[:i] + escape(trimmed[i:i+1]) + trimmed[i+1:]
+	}
+	return line
+}
+
+// renderBlocks 按方言输出所有块
+func renderBlocks(blocks []mdBlock, d Dialect) string {
+	lines := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		lines = append(lines, renderBlock(b, d))
+	}
+
+	switch d {
+	case DialectHTML:
+		return strings.Join(lines, "<br>\n")
+	case DialectDingTalk:
+		// 钉钉 markdown 单个换行不生效，非空行之间用空行分隔
+		var out []string
+		for _, line := range lines {
+			if line != "" {
+				out = append(out, line)
+			}
+		}
+		return strings.Join(out, "\n\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderBlock 输出单个块
+func renderBlock(b mdBlock, d Dialect) string {
+	text := renderInlines(b.inlines, d)
+	switch b.kind {
+	case blockBlank:
+		return ""
+	case blockParagraph:
+		return escapeBlockStart(text, d)
+	case blockRule:
+		switch d {
+		case DialectHTML:
+			return "<hr>"
+		case DialectMarkdown, DialectLarkMd, DialectDingTalk:
+			return "---"
+		}
+		return "──────────"
+	case blockHeading:
+		switch d {
+		case DialectMarkdown, DialectWechatWork, DialectDingTalk:
+			return strings.Repeat("#", b.level) + " " + text
+		case DialectPlain:
+			return text
+		}
+		// 不支持标题的渠道使用粗体
+		return wrapStyle(nodeBold, text, d)
+	case blockListItem:
+		indent := strings.Repeat("  ", b.level)
+		marker := b.marker
+		switch {
+		case marker != "" && d == DialectTelegramV2:
+			marker = EscapeText(marker, d)
+		case marker == "" && (d == DialectMarkdown || d == DialectLarkMd || d == DialectDingTalk):
+			marker = "-"
+		case marker == "":
+			marker = "•"
+		}
+		if d == DialectHTML {
+			indent = strings.Repeat("&nbsp;&nbsp;", b.level)
+		}
+		return indent + marker + " " + text
+	case blockQuote:
+		switch d {
+		case DialectTelegramHTML, DialectHTML:
+			return "<blockquote>" + text + "</blockquote>"
+		case DialectPlain:
+			return "│ " + text
+		case DialectTelegramV2:
+			return ">" + text
+		}
+		return "> " + text
+	case blockCode:
+		switch d {
+		case DialectTelegramV2:
+			code := strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(b.code)
+			return "```" + b.lang + "\n" + code + "\n```"
+		case DialectTelegramHTML, DialectHTML:
+			code := html.EscapeString(b.code)
+			if b.lang != "" {
+				return `<pre><code class="language-` + b.lang + `">` + code + "</code></pre>"
+			}
+			return "<pre>" + code + "</pre>"
+		case DialectMarkdown, DialectLarkMd, DialectDingTalk:
+			return "```" + b.lang + "\n" + b.code + "\n```"
+		case DialectSlack:
+			// Slack 代码块不支持语言标记
+			return "```\n" + slackReplacer.Replace(b.code) + "\n```"
+		}
+		return b.code
+	}
+	return text
+}
+
+// renderInlines 输出行内元素
+func renderInlines(nodes []mdNode, d Dialect) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText:
+			sb.WriteString(EscapeText(n.text, d))
+		case nodeCode:
+			switch d {
+			case DialectTelegramV2:
+				sb.WriteString("`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(n.text) + "`")
+			case DialectTelegramHTML, DialectHTML:
+				sb.WriteString("<code>" + html.EscapeString(n.text) + "</code>")
+			case DialectPlain, DialectDingTalk:
+				sb.WriteString(n.text)
+			case DialectSlack:
+				sb.WriteString("`" + slackReplacer.Replace(n.text) + "`")
+			default:
+				sb.WriteString("`" + n.text + "`")
+			}
+		case nodeLink:
+			text := renderInlines(n.children, d)
+			switch d {
+			case DialectTelegramV2:
+				url := strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(n.url)
+				sb.WriteString("[" + text + "](" + url + ")")
+			case DialectTelegramHTML, DialectHTML:
+				sb.WriteString(`<a href="` + html.EscapeString(n.url) + `">` + text + "</a>")
+			case DialectSlack:
+				sb.WriteString("<" + slackReplacer.Replace(n.url) + "|" + text + ">")
+			case DialectPlain:
+				if text == n.url {
+					sb.WriteString(text)
+				} else {
+					sb.WriteString(text + " (" + n.url + ")")
+				}
+			default:
+				sb.WriteString("[" + text + "](" + n.url + ")")
+			}
+		default:
+			sb.WriteString(wrapStyle(n.kind, renderInlines(n.children, d), d))
+		}
+	}
+	return sb.String()
+}
+
+// styleMarkers 各方言中粗体、斜体、删除线的标记
+var styleMarkers = map[Dialect]map[nodeKind]string{
+	DialectTelegramV2: {nodeBold: "*", nodeItalic: "_", nodeStrike: "~"},
+	DialectMarkdown:   {nodeBold: "**", nodeItalic: "*", nodeStrike: "~~"},
+	DialectLarkMd:     {nodeBold: "**", nodeItalic: "*", nodeStrike: "~~"},
+	DialectWechatWork: {nodeBold: "**"},
+	DialectDingTalk:   {nodeBold: "**", nodeItalic: "*"},
+	DialectSlack:      {nodeBold: "*", nodeItalic: "_", nodeStrike: "~"},
+}
+
+// htmlStyleTags 粗体、斜体、删除线对应的 HTML 标签
+var htmlStyleTags = map[nodeKind]string{nodeBold: "b", nodeItalic: "i", nodeStrike: "s"}
+
+// wrapStyle 为已输出的文本加上粗体、斜体或删除线标记，不支持的样式按普通文本输出
+func wrapStyle(kind nodeKind, text string, d Dialect) string {
+	if d == DialectTelegramHTML || d == DialectHTML {
+		tag := htmlStyleTags[kind]
+		return "<" + tag + ">" + text + "</" + tag + ">"
+	}
+	marker := styleMarkers[d][kind]
+	return marker + text + marker
+}
+
+// RenderFeishuPost 将 markdown 转换为飞书富文本（post）的段落元素
+func RenderFeishuPost(src string) [][]map[string]interface{} {
+	return feishuPostRows(parseMarkdown(src))
+}
+
+// feishuPostRows 每个块输出为富文本的一个段落
+func feishuPostRows(blocks []mdBlock) [][]map[string]interface{} {
+	rows := [][]map[string]interface{}{}
+	for _, b := range blocks {
+		var row []map[string]interface{}
+		switch b.kind {
+		case blockBlank:
+			continue
+		case blockRule:
+			row = []map[string]interface{}{{"tag": "hr"}}
+		case blockCode:
+			row = []map[string]interface{}{{"tag": "code_block", "language": strings.ToUpper(b.lang), "text": b.code}}
+		default:
+			var prefix string
+			var styles []string
+			switch b.kind {
+			case blockHeading:
+				styles = []string{"bold"}
+			case blockListItem:
+				prefix = strings.Repeat("  ", b.level) + "• "
+				if b.marker != "" {
+					prefix = strings.Repeat("  ", b.level) + b.marker + " "
+				}
+			case blockQuote:
+				prefix = "┃ "
+			}
+			if prefix != "" {
+				row = append(row, map[string]interface{}{"tag": "text", "text": prefix})
+			}
+			row = append(row, feishuPostElements(b.inlines, styles)...)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// feishuPostElements 行内元素转换为富文本元素，嵌套样式合并到 style 中
+func feishuPostElements(nodes []mdNode, styles []string) []map[string]interface{} {
+	var elements []map[string]interface{}
+	withStyle := func(el map[string]interface{}) map[string]interface{} {
+		if len(styles) > 0 {
+			el["style"] = append([]string(nil), styles...)
+		}
+		return el
+	}
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText, nodeCode:
+			elements = append(elements, withStyle(map[string]interface{}{"tag": "text", "text": n.text}))
+		case nodeLink:
+			elements = append(elements, withStyle(map[string]interface{}{"tag": "a", "text": renderInlines(n.children, DialectPlain), "href": n.url}))
+		default:
+			style := map[nodeKind]string{nodeBold: "bold", nodeItalic: "italic", nodeStrike: "lineThrough"}[n.kind]
+			elements = append(elements, feishuPostElements(n.children, append(append([]string(nil), styles...), style))...)
+		}
+	}
+	return elements
+}
+
+// RenderContent 按消息格式将正文输出为指定方言：
+// markdown（或未指定格式）按 markdown 解析，纯文本和 HTML（去除标签后）按字面文本转义
+func (m *NotificationMessage) RenderContent(d Dialect) string {
+	switch m.Format {
+	case FormatPlain:
+		return renderBlocks(literalBlocks(m.Content), d)
+	case FormatHTML:
+		return renderBlocks(literalBlocks(stripHTML(m.Content)), d)
+	}
+	return RenderMarkdown(m.Content, d)
+}
+
+// FeishuPostContent 按消息格式将正文转换为飞书富文本段落
+func (m *NotificationMessage) FeishuPostContent() [][]map[string]interface{} {
+	switch m.Format {
+	case FormatPlain:
+		return feishuPostRows(literalBlocks(m.Content))
+	case FormatHTML:
+		return feishuPostRows(literalBlocks(stripHTML(m.Content)))
+	}
+	return RenderFeishuPost(m.Content)
+}
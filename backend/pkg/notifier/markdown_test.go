@@ -0,0 +1,141 @@
+package notifier
+
+import "testing"
+
+var dialectNames = map[Dialect]string{
+	DialectPlain:        "plain",
+	DialectMarkdown:     "markdown",
+	DialectTelegramV2:   "telegramV2",
+	DialectTelegramHTML: "telegramHTML",
+	DialectHTML:         "html",
+	DialectLarkMd:       "larkMd",
+	DialectWechatWork:   "wechatWork",
+	DialectDingTalk:     "dingTalk",
+	DialectSlack:        "slack",
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want map[Dialect]string
+	}{
+		{
+			name: "转义特殊字符",
+			src:  "a_b*c [x] 1.5 <tag> & #",
+			want: map[Dialect]string{
+				DialectPlain:        "a_b*c [x] 1.5 <tag> & #",
+				DialectMarkdown:     `a\_b\*c \[x\] 1.5 \<tag> & #`,
+				DialectTelegramV2:   `a\_b\*c \[x\] 1\.5 <tag\> & \#`,
+				DialectTelegramHTML: "a_b*c [x] 1.5 &lt;tag&gt; &amp; #",
+				DialectHTML:         "a_b*c [x] 1.5 &lt;tag&gt; &amp; #",
+				DialectLarkMd:       "a&#95;b&#42;c &#91;x&#93; 1.5 &lt;tag&gt; & #",
+				DialectWechatWork:   `a_b\*c \[x\] 1.5 <tag> & #`,
+				DialectDingTalk:     `a\_b\*c \[x\] 1.5 <tag> & #`,
+				DialectSlack:        "a_b*c [x] 1.5 &lt;tag&gt; &amp; #",
+			},
+		},
+		{
+			name: "段落开头的转义标记",
+			src:  `\- 不是列表`,
+			want: map[Dialect]string{
+				DialectPlain:        "- 不是列表",
+				DialectMarkdown:     `\- 不是列表`,
+				DialectTelegramV2:   `\- 不是列表`,
+				DialectTelegramHTML: "- 不是列表",
+				DialectHTML:         "- 不是列表",
+				DialectLarkMd:       "&#45; 不是列表",
+				DialectWechatWork:   `\- 不是列表`,
+				DialectDingTalk:     `\- 不是列表`,
+				DialectSlack:        "- 不是列表",
+			},
+		},
+		{
+			name: "地址中包含括号的链接",
+			src:  "[维基](https://en.wikipedia.org/wiki/Foo_(bar)) 后缀",
+			want: map[Dialect]string{
+				DialectPlain:        "维基 (https://en.wikipedia.org/wiki/Foo_(bar)) 后缀",
+				DialectMarkdown:     "[维基](https://en.wikipedia.org/wiki/Foo_(bar)) 后缀",
+				DialectTelegramV2:   `[维基](https://en.wikipedia.org/wiki/Foo_(bar\)) 后缀`,
+				DialectTelegramHTML: `<a href="https://en.wikipedia.org/wiki/Foo_(bar)">维基</a> 后缀`,
+				DialectHTML:         `<a href="https://en.wikipedia.org/wiki/Foo_(bar)">维基</a> 后缀`,
+				DialectLarkMd:       "[维基](https://en.wikipedia.org/wiki/Foo_(bar)) 后缀",
+				DialectWechatWork:   "[维基](https://en.wikipedia.org/wiki/Foo_(bar)) 后缀",
+				DialectDingTalk:     "[维基](https://en.wikipedia.org/wiki/Foo_(bar)) 后缀",
+				DialectSlack:        "<https://en.wikipedia.org/wiki/Foo_(bar)|维基> 后缀",
+			},
+		},
+		{
+			name: "链接后的括号文本",
+			src:  "[a](http://x/y) (注)",
+			want: map[Dialect]string{
+				DialectPlain:        "a (http://x/y) (注)",
+				DialectMarkdown:     "[a](http://x/y) (注)",
+				DialectTelegramV2:   `[a](http://x/y) \(注\)`,
+				DialectTelegramHTML: `<a href="http://x/y">a</a> (注)`,
+				DialectHTML:         `<a href="http://x/y">a</a> (注)`,
+				DialectLarkMd:       "[a](http://x/y) (注)",
+				DialectWechatWork:   "[a](http://x/y) (注)",
+				DialectDingTalk:     "[a](http://x/y) (注)",
+				DialectSlack:        "<http://x/y|a> (注)",
+			},
+		},
+		{
+			name: "行内代码",
+			src:  "`a*b_c <x>`",
+			want: map[Dialect]string{
+				DialectPlain:        "a*b_c <x>",
+				DialectMarkdown:     "`a*b_c <x>`",
+				DialectTelegramV2:   "`a*b_c <x>`",
+				DialectTelegramHTML: "<code>a*b_c &lt;x&gt;</code>",
+				DialectHTML:         "<code>a*b_c &lt;x&gt;</code>",
+				DialectLarkMd:       "`a*b_c <x>`",
+				DialectWechatWork:   "`a*b_c <x>`",
+				DialectDingTalk:     "a*b_c <x>",
+				DialectSlack:        "`a*b_c &lt;x&gt;`",
+			},
+		},
+		{
+			name: "列表",
+			src:  "- 一\n  - 二\n1. 三",
+			want: map[Dialect]string{
+				DialectPlain:        "• 一\n  • 二\n1. 三",
+				DialectMarkdown:     "- 一\n  - 二\n1. 三",
+				DialectTelegramV2:   "• 一\n  • 二\n1\\. 三",
+				DialectTelegramHTML: "• 一\n  • 二\n1. 三",
+				DialectHTML:         "• 一<br>\n&nbsp;&nbsp;• 二<br>\n1. 三",
+				DialectLarkMd:       "- 一\n  - 二\n1. 三",
+				DialectWechatWork:   "• 一\n  • 二\n1. 三",
+				DialectDingTalk:     "- 一\n\n  - 二\n\n1. 三",
+				DialectSlack:        "• 一\n  • 二\n1. 三",
+			},
+		},
+		{
+			name: "粗体、斜体和删除线",
+			src:  "**粗** *斜* ~~删~~",
+			want: map[Dialect]string{
+				DialectPlain:        "粗 斜 删",
+				DialectMarkdown:     "**粗** *斜* ~~删~~",
+				DialectTelegramV2:   "*粗* _斜_ ~删~",
+				DialectTelegramHTML: "<b>粗</b> <i>斜</i> <s>删</s>",
+				DialectHTML:         "<b>粗</b> <i>斜</i> <s>删</s>",
+				DialectLarkMd:       "**粗** *斜* ~~删~~",
+				DialectWechatWork:   "**粗** 斜 删",
+				DialectDingTalk:     "**粗** *斜* 删",
+				DialectSlack:        "*粗* _斜_ ~删~",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.want) != len(dialectNames) {
+				t.Fatalf("应覆盖全部 %d 种方言", len(dialectNames))
+			}
+			for d, want := range tt.want {
+				if got := RenderMarkdown(tt.src, d); got != want {
+					t.Errorf("%s:\n got %q\nwant %q", dialectNames[d], got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package notifier
+
+import (
+	"html"
+	"strings"
+)
+
+// ContentFormat 消息内容格式
+type ContentFormat string
+
+const (
+	FormatPlain    ContentFormat = "plain"    // 纯文本
+	FormatMarkdown ContentFormat = "markdown" // Markdown
+	FormatHTML     ContentFormat = "html"     // HTML
+)
+
+// ParseFormat 解析内容格式，无法识别时返回空（由各通知服务使用各自的默认格式）
+func ParseFormat(s string) ContentFormat {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "plain", "text", "txt":
+		return FormatPlain
+	case "markdown", "md":
+		return FormatMarkdown
+	case "html":
+		return FormatHTML
+	}
+	return ""
+}
+
+// Level 消息级别
+type Level string
+
+const (
+	LevelInfo     Level = "info"
+	LevelSuccess  Level = "success"
+	LevelWarning  Level = "warning"
+	LevelError    Level = "error"
+	LevelCritical Level = "critical"
+)
+
+// ParseLevel 解析消息级别，兼容常见的别名，无法识别时返回空
+func ParseLevel(s string) Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "info", "information", "notice", "debug":
+		return LevelInfo
+	case "success", "ok", "resolved":
+		return LevelSuccess
+	case "warning", "warn":
+		return LevelWarning
+	case "error", "err", "fail", "failed", "failure":
+		return LevelError
+	case "critical", "crit", "fatal", "emergency", "alert":
+		return LevelCritical
+	}
+	return ""
+}
+
+// Rank 返回级别的严重程度，数值越大越严重，未设置级别视为 info
+func (l Level) Rank() int {
+	switch l {
+	case LevelWarning:
+		return 2
+	case LevelError:
+		return 3
+	case LevelCritical:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// Icon 返回级别对应的标题前缀图标，info 和未设置时为空
+func (l Level) Icon() string {
+	switch l {
+	case LevelSuccess:
+		return "✅"
+	case LevelWarning:
+		return "⚠️"
+	case LevelError:
+		return "❌"
+	case LevelCritical:
+		return "🚨"
+	}
+	return ""
+}
+
+// Color 返回级别对应的颜色（#rrggbb），未设置级别视为 info
+func (l Level) Color() string {
+	switch l {
+	case LevelSuccess:
+		return "#52c41a"
+	case LevelWarning:
+		return "#faad14"
+	case LevelError:
+		return "#f5222d"
+	case LevelCritical:
+		return "#a8071a"
+	}
+	return "#1677ff"
+}
+
+// Action 消息中的按钮
+type Action struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// Field 消息中的键值字段
+type Field struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// DisplayTitle 返回带级别图标的标题
+func (m *NotificationMessage) DisplayTitle() string {
+	if icon := m.Level.Icon(); icon != "" && m.Title != "" {
+		return icon + " " + m.Title
+	}
+	return m.Title
+}
+
+// AllImages 返回全部图片，Image 排在最前面
+func (m *NotificationMessage) AllImages() []string {
+	var images []string
+	seen := map[string]bool{}
+	for _, image := range append([]string{m.Image}, m.Images...) {
+		if image = strings.TrimSpace(image); image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// AllActions 返回全部按钮，设置了 URL 且不在按钮中时补充一个“查看详情”按钮放在最前面
+func (m *NotificationMessage) AllActions() []Action {
+	var actions []Action
+	hasURL := false
+	for _, action := range m.Actions {
+		if action.URL == "" {
+			continue
+		}
+		if action.Label == "" {
+			action.Label = action.URL
+		}
+		if action.URL == m.URL {
+			hasURL = true
+		}
+		actions = append(actions, action)
+	}
+	if m.URL != "" && !hasURL {
+		actions = append([]Action{{Label: "查看详情", URL: m.URL}}, actions...)
+	}
+	return actions
+}
+
+// stripHTML 去除 HTML 标签，块级标签的结束和 <br> 转换为换行，脚本和样式的内容不输出
+func stripHTML(s string) string {
+	return html.UnescapeString(sanitizeHTML(s, plainTextPolicy))
+}
+
+// PlainContent 返回纯文本形式的内容：去除 markdown 标记或 HTML 标签
+func (m *NotificationMessage) PlainContent() string {
+	return m.RenderContent(DialectPlain)
+}
+
+// FieldsText 将键值字段按行拼接，bold 为加粗键名的包裹符（例如 markdown 的 "**"），为空时不加粗
+func (m *NotificationMessage) FieldsText(bold string) string {
+	var sb strings.Builder
+	for i, field := range m.Fields {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(bold + field.Key + bold + ": " + field.Value)
+	}
+	return sb.String()
+}
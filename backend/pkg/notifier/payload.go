@@ -0,0 +1,23 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+)
+
+type payloadKey struct{}
+
+// WithPayload 返回携带原始请求数据的 context，供需要原始数据的通知服务（例如 webhook 的模板）使用；
+// load 在第一次读取时才调用，结果会被缓存
+func WithPayload(ctx context.Context, load func() map[string]any) context.Context {
+	return context.WithValue(ctx, payloadKey{}, sync.OnceValue(load))
+}
+
+// Payload 返回 context 中的原始请求数据，没有时返回 nil
+func Payload(ctx context.Context) map[string]any {
+	load, ok := ctx.Value(payloadKey{}).(func() map[string]any)
+	if !ok {
+		return nil
+	}
+	return load()
+}
@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/ratelimit"
+)
+
+// Factory 根据通知服务实例配置创建通知服务，负责解析并校验自身的配置
+type Factory func(instance config.NotifierInstance) (Notifier, error)
+
+// FieldSchema 配置字段描述，供前端渲染配置表单
+type FieldSchema struct {
+	Name        string   `json:"name"`                  // 配置键名，与配置文件中的键一致
+	Label       string   `json:"label"`                 // 显示名称
+	Type        string   `json:"type"`                  // 字段类型: string, password, number, bool, select, textarea
+	Required    bool     `json:"required"`              // 是否必填
+	Secret      bool     `json:"secret"`                // 是否为敏感信息，读取时需要隐藏
+	Hint        string   `json:"hint,omitempty"`        // 提示信息
+	Placeholder string   `json:"placeholder,omitempty"` // 占位文本
+	Options     []string `json:"options,omitempty"`     // select 类型的可选值
+	Default     any      `json:"default,omitempty"`     // 默认值
+}
+
+// Schema 通知服务类型的元数据
+type Schema struct {
+	DisplayName string            `json:"displayName"`
+	Description string            `json:"description,omitempty"`
+	Fields      []FieldSchema     `json:"fields"`
+	RateLimit   *config.RateLimit `json:"rateLimit,omitempty"` // 默认发送限速，按服务商的频率限制设置
+}
+
+// TypeInfo 已注册的通知服务类型信息
+type TypeInfo struct {
+	Type config.NotifiersType `json:"type"`
+	Schema
+	RequiredFields []string `json:"requiredFields"`
+	SecretFields   []string `json:"secretFields"`
+}
+
+type registration struct {
+	info    TypeInfo
+	factory Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[config.NotifiersType]*registration)
+	// 按注册顺序保存类型，保证列表输出稳定
+	registryOrder []config.NotifiersType
+)
+
+// Register 注册通知服务类型，通常在各通知服务文件的 init 中调用
+func Register(typ config.NotifiersType, factory Factory, schema Schema) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("notifier: 类型 %s 的 factory 不能为空", typ))
+	}
+	if _, exists := registry[typ]; exists {
+		panic(fmt.Sprintf("notifier: 类型 %s 重复注册", typ))
+	}
+
+	info := TypeInfo{
+		Type:           typ,
+		Schema:         schema,
+		RequiredFields: []string{},
+		SecretFields:   []string{},
+	}
+	for _, field := range schema.Fields {
+		if field.Required {
+			info.RequiredFields = append(info.RequiredFields, field.Name)
+		}
+		if field.Secret {
+			info.SecretFields = append(info.SecretFields, field.Name)
+		}
+	}
+
+	registry[typ] = &registration{info: info, factory: factory}
+	registryOrder = append(registryOrder, typ)
+}
+
+// Lookup 获取指定类型的注册信息
+func Lookup(typ config.NotifiersType) (TypeInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, exists := registry[typ]
+	if !exists {
+		return TypeInfo{}, false
+	}
+	return reg.info, true
+}
+
+// Types 按注册顺序返回所有已注册的通知服务类型
+func Types() []TypeInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]TypeInfo, 0, len(registryOrder))
+	for _, typ := range registryOrder {
+		types = append(types, registry[typ].info)
+	}
+	return types
+}
+
+// IsSecretField 判断指定类型的配置键是否为敏感字段
+func IsSecretField(typ config.NotifiersType, name string) bool {
+	info, exists := Lookup(typ)
+	if !exists {
+		return false
+	}
+	for _, field := range info.SecretFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// New 根据通知服务实例配置创建通知服务
+func New(instance config.NotifierInstance) (Notifier, error) {
+	registryMu.RLock()
+	reg, exists := registry[instance.Type]
+	registryMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("未知的通知服务类型: %s", instance.Type)
+	}
+	return reg.factory(instance)
+}
+
+// RateLimitFor 返回通知服务实例生效的限速配置：实例配置覆盖类型的默认值
+func RateLimitFor(instance config.NotifierInstance) config.RateLimit {
+	var defaults *config.RateLimit
+	if info, exists := Lookup(instance.Type); exists {
+		defaults = info.RateLimit
+	}
+	return ratelimit.Merge(defaults, instance.RateLimit)
+}
+
+// Validate 校验通知服务实例配置
+func Validate(instance config.NotifierInstance) error {
+	_, err := New(instance)
+	return err
+}
@@ -0,0 +1,171 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Result 单个目标的发送结果
+type Result struct {
+	Target            string `json:"target"`                      // 发送目标，使用通知服务默认目标时可能为空
+	Success           bool   `json:"success"`                     // 是否发送成功
+	ProviderMessageID string `json:"providerMessageId,omitempty"` // 服务商返回的消息ID
+	ErrorCode         string `json:"errorCode,omitempty"`         // 服务商返回的错误码
+	Error             string `json:"error,omitempty"`             // 错误信息
+	Retryable         bool   `json:"retryable"`                   // 失败后重试是否可能成功
+	LatencyMs         int64  `json:"latencyMs"`                   // 请求耗时（毫秒）
+	RetryAfterMs      int64  `json:"retryAfterMs,omitempty"`      // 服务商要求的重试等待时间（毫秒）
+	SentParts         int    `json:"sentParts,omitempty"`         // 拆分发送失败时已成功发送的条数，重试时从下一条继续
+	Parts             int    `json:"parts,omitempty"`             // 拆分发送失败时消息的总条数
+}
+
+// Results 一次发送的全部结果
+type Results []Result
+
+// Err 合并所有失败结果为一个错误，全部成功时返回 nil
+func (rs Results) Err() error {
+	var msgs []string
+	for _, r := range rs {
+		if r.Success {
+			continue
+		}
+		if r.Target != "" {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", r.Target, r.Error))
+		} else {
+			msgs = append(msgs, r.Error)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Retryable 是否存在可以重试的失败结果
+func (rs Results) Retryable() bool {
+	for _, r := range rs {
+		if !r.Success && r.Retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryAfter 返回失败结果中服务商要求的最长重试等待时间，没有时返回 0
+func (rs Results) RetryAfter() time.Duration {
+	var after int64
+	for _, r := range rs {
+		if !r.Success && r.RetryAfterMs > after {
+			after = r.RetryAfterMs
+		}
+	}
+	return time.Duration(after) * time.Millisecond
+}
+
+// FailedTargets 返回发送失败的目标
+func (rs Results) FailedTargets() []string {
+	var targets []string
+	for _, r := range rs {
+		if !r.Success && r.Target != "" {
+			targets = append(targets, r.Target)
+		}
+	}
+	return targets
+}
+
+// ProviderError 服务商返回的错误
+type ProviderError struct {
+	Code       string // 服务商错误码
+	Message    string
+	Retryable  bool
+	RetryAfter time.Duration // 服务商要求的重试等待时间（限流时返回）
+}
+
+func (e *ProviderError) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (错误代码: %s)", e.Message, e.Code)
+}
+
+// providerError 创建服务商错误
+func providerError(code interface{}, message string, retryable bool) *ProviderError {
+	return &ProviderError{Code: fmt.Sprint(code), Message: message, Retryable: retryable}
+}
+
+// permanentError 配置错误等重试无法恢复的错误
+func permanentError(format string, args ...interface{}) *ProviderError {
+	return &ProviderError{Message: fmt.Sprintf(format, args...)}
+}
+
+// httpStatusError 根据 HTTP 状态码创建错误，限流和服务端错误可以重试，并读取 Retry-After 响应头
+func httpStatusError(resp *resty.Response) *ProviderError {
+	status := resp.StatusCode()
+	return &ProviderError{
+		Code:       fmt.Sprintf("HTTP_%d", status),
+		Message:    fmt.Sprintf("HTTP请求失败，状态码: %d", status),
+		Retryable:  status == 429 || status >= 500,
+		RetryAfter: parseRetryAfter(resp.Header().Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter 解析 Retry-After 响应头：秒数或 HTTP 日期
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// NewResult 根据发送返回的错误生成结果
+//
+// ProviderError 按其自身的 Retryable 判断；网络错误、超时等其它错误默认可以重试。
+func NewResult(target string, start time.Time, providerMessageID string, err error) Result {
+	r := Result{
+		Target:    target,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err == nil {
+		r.Success = true
+		r.ProviderMessageID = providerMessageID
+		return r
+	}
+
+	r.Error = err.Error()
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		r.ErrorCode = pe.Code
+		r.Retryable = pe.Retryable
+		r.RetryAfterMs = pe.RetryAfter.Milliseconds()
+	} else {
+		r.Retryable = true
+	}
+	return r
+}
+
+// resultsFor 为同一次请求涉及的所有目标生成相同的结果，targets 为空时生成一条目标为空的结果
+func resultsFor(targets []string, start time.Time, providerMessageID string, err error) Results {
+	if len(targets) == 0 {
+		return Results{NewResult("", start, providerMessageID, err)}
+	}
+	results := make(Results, len(targets))
+	for i, target := range targets {
+		results[i] = NewResult(target, start, providerMessageID, err)
+	}
+	return results
+}
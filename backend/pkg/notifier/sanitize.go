@@ -0,0 +1,209 @@
+package notifier
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// htmlPolicy HTML 内容的白名单规则
+type htmlPolicy struct {
+	// tags 允许的标签及其允许的属性
+	tags map[string][]string
+	// rename 输出时替换的标签名，例如将标题替换为粗体
+	rename map[string]string
+	// startText 和 endText 为不允许的标签在开始和结束位置输出的文本，例如段落结束时换行
+	startText map[string]string
+	endText   map[string]string
+	// schemes 链接和图片地址允许的协议
+	schemes []string
+	// requireAttr 没有任何允许的属性时整个去掉的标签，例如没有地址的链接
+	requireAttr map[string]bool
+	// attrValue 可选，进一步校验属性值，返回 false 时丢弃该属性
+	attrValue func(tag, attr, value string) bool
+}
+
+// skippedTags 内容不输出的标签
+var skippedTags = map[string]bool{"script": true, "style": true, "head": true, "title": true, "iframe": true, "object": true}
+
+// voidTags 没有结束标签的元素
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// telegramHTMLPolicy Telegram parse_mode=HTML 支持的标签，其余标签会导致 400，转换为文本换行或去掉
+var telegramHTMLPolicy = func() htmlPolicy {
+	p := htmlPolicy{
+		tags: map[string][]string{
+			"b": nil, "strong": nil, "i": nil, "em": nil, "u": nil, "ins": nil, "s": nil, "strike": nil, "del": nil,
+			"a": {"href"}, "code": {"class"}, "pre": nil, "blockquote": nil, "tg-spoiler": nil, "span": {"class"},
+		},
+		rename:      map[string]string{},
+		requireAttr: map[string]bool{"a": true, "span": true},
+		startText:   map[string]string{"br": "\n", "hr": "\n", "li": "• "},
+		endText:     map[string]string{},
+		schemes:     []string{"http", "https", "tg", "mailto"},
+		attrValue: func(tag, attr, value string) bool {
+			switch tag {
+			case "code":
+				return strings.HasPrefix(value, "language-")
+			case "span":
+				return value == "tg-spoiler"
+			}
+			return true
+		},
+	}
+	for _, tag := range []string{"h1", "h2", "h3", "h4", "h5", "h6"} {
+		p.rename[tag] = "b"
+		p.tags[tag] = nil
+		p.endText[tag] = "\n"
+	}
+	for _, tag := range []string{"p", "div", "li", "tr", "ul", "ol", "table", "section", "article"} {
+		p.endText[tag] = "\n"
+	}
+	return p
+}()
+
+// emailHTMLPolicy 邮件正文允许的排版标签，去掉脚本、表单、事件属性和样式
+var emailHTMLPolicy = func() htmlPolicy {
+	p := htmlPolicy{
+		tags: map[string][]string{
+			"a": {"href", "title"}, "img": {"src", "alt", "width", "height"},
+			"table": {"border", "cellpadding", "cellspacing", "width"}, "td": {"colspan", "rowspan", "align"}, "th": {"colspan", "rowspan", "align"},
+			"font": {"color"},
+		},
+		schemes: []string{"http", "https", "mailto", "cid"},
+	}
+	for _, tag := range []string{
+		"p", "div", "span", "br", "hr", "b", "strong", "i", "em", "u", "s", "strike", "del", "ins", "small", "sub", "sup", "mark",
+		"h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "li", "dl", "dt", "dd", "blockquote", "pre", "code",
+		"thead", "tbody", "tfoot", "tr", "caption",
+	} {
+		p.tags[tag] = nil
+	}
+	return p
+}()
+
+// plainTextPolicy 不保留任何标签，只把块级元素转换为换行，用于将 HTML 转换为纯文本
+var plainTextPolicy = func() htmlPolicy {
+	p := htmlPolicy{
+		startText: map[string]string{"br": "\n", "hr": "\n", "li": "• "},
+		endText:   map[string]string{"td": " ", "th": " "},
+	}
+	for _, tag := range []string{"p", "div", "li", "tr", "ul", "ol", "table", "section", "article", "blockquote", "pre", "h1", "h2", "h3", "h4", "h5", "h6"} {
+		p.endText[tag] = "\n"
+	}
+	return p
+}()
+
+// sanitizeHTML 按白名单过滤 HTML：去掉不允许的标签和属性，补全未闭合的标签，文本重新转义
+func sanitizeHTML(src string, p htmlPolicy) string {
+	var sb strings.Builder
+	var open []string
+	skip := 0
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		token := z.Token()
+		name := token.Data
+		switch tt {
+		case nethtml.TextToken:
+			if skip == 0 {
+				sb.WriteString(html.EscapeString(token.Data))
+			}
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if skippedTags[name] {
+				if tt == nethtml.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			attrs, ok := p.tags[name]
+			if !ok {
+				sb.WriteString(p.startText[name])
+				continue
+			}
+			var attrText strings.Builder
+			for _, attr := range token.Attr {
+				if p.allowAttr(name, attr, attrs) {
+					attrText.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+				}
+			}
+			if attrText.Len() == 0 && p.requireAttr[name] {
+				continue
+			}
+			out := name
+			if r, ok := p.rename[name]; ok {
+				out = r
+			}
+			sb.WriteString("<" + out + attrText.String() + ">")
+			if tt == nethtml.StartTagToken && !voidTags[name] {
+				open = append(open, name)
+			}
+		case nethtml.EndTagToken:
+			if skippedTags[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if _, ok := p.tags[name]; !ok {
+				sb.WriteString(p.endText[name])
+				continue
+			}
+			// 只关闭已打开的标签，中间未闭合的标签一并关闭
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != name {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					sb.WriteString(p.closeTag(open[j]))
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString(p.closeTag(open[i]))
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(sb.String(), "\n\n"))
+}
+
+// closeTag 输出结束标签，并补上白名单外标签结束时的文本
+func (p htmlPolicy) closeTag(name string) string {
+	out := name
+	if r, ok := p.rename[name]; ok {
+		out = r
+	}
+	return "</" + out + ">" + p.endText[name]
+}
+
+// allowAttr 检查属性是否在白名单中，链接和图片地址只允许指定的协议
+func (p htmlPolicy) allowAttr(tag string, attr nethtml.Attribute, allowed []string) bool {
+	if !slices.Contains(allowed, attr.Key) || attr.Namespace != "" {
+		return false
+	}
+	if attr.Key == "href" || attr.Key == "src" {
+		scheme, _, ok := strings.Cut(strings.TrimSpace(attr.Val), ":")
+		if !ok {
+			return false
+		}
+		if !slices.Contains(p.schemes, strings.ToLower(scheme)) {
+			return false
+		}
+	}
+	return p.attrValue == nil || p.attrValue(tag, attr.Key, attr.Val)
+}
@@ -0,0 +1,477 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Slack 和 Mattermost 的 incoming webhook 请求格式相同（channel、username、icon、text、attachments），
+// 由同一个实现按方言输出：Slack 使用 Block Kit 和 mrkdwn，Mattermost 使用带颜色的 attachment 和标准 markdown。
+const (
+	Slack      config.NotifiersType = "slack"
+	Mattermost config.NotifiersType = "mattermost"
+)
+
+const slackAPIURL = "https://slack.com/api"
+
+// SlackConfig Slack / Mattermost 配置
+type SlackConfig struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled"`
+	WebhookURL string `yaml:"webhook_url" json:"webhookUrl"` // incoming webhook 地址
+	BotToken   string `yaml:"bot_token" json:"botToken"`     // Slack Bot Token（xoxb-），设置后通过 chat.postMessage 发送
+	Channel    string `yaml:"channel" json:"channel"`        // 默认频道，消息指定了 targets 时发送到 targets
+	Username   string `yaml:"username" json:"username"`      // 显示的发送者名称
+	IconURL    string `yaml:"icon_url" json:"iconUrl"`       // 发送者头像地址
+	IconEmoji  string `yaml:"icon_emoji" json:"iconEmoji"`   // 发送者头像 emoji，例如 :bell:
+	Proxy      string `yaml:"proxy" json:"proxy"`            // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	factory := func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg SlackConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewSlackNotifier(instance.Type, cfg)
+		return n, n.Validate()
+	}
+
+	Register(Slack, factory, Schema{
+		DisplayName: "Slack",
+		Description: "通过 incoming webhook 或 Bot Token（chat.postMessage）发送 Block Kit 消息，消息中的 targets 为频道",
+		Fields: []FieldSchema{
+			{Name: "webhook_url", Label: "Webhook 地址", Type: "password", Secret: true, Placeholder: "https://hooks.slack.com/services/...", Hint: "与 Bot Token 二选一"},
+			{Name: "bot_token", Label: "Bot Token", Type: "password", Secret: true, Placeholder: "xoxb-...", Hint: "需要 chat:write 权限，设置后优先使用"},
+			{Name: "channel", Label: "默认频道", Type: "string", Placeholder: "#alerts", Hint: "频道名或 ID，使用 Bot Token 时必填（消息指定了 targets 时除外）"},
+			{Name: "username", Label: "发送者名称", Type: "string"},
+			{Name: "icon_emoji", Label: "头像 emoji", Type: "string", Placeholder: ":bell:"},
+			{Name: "icon_url", Label: "头像地址", Type: "string"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// Slack 每个频道每秒约 1 条消息
+		RateLimit: &config.RateLimit{PerTarget: "1/s"},
+	})
+
+	Register(Mattermost, factory, Schema{
+		DisplayName: "Mattermost",
+		Description: "通过 incoming webhook 发送带颜色的消息，消息中的 targets 为频道",
+		Fields: []FieldSchema{
+			{Name: "webhook_url", Label: "Webhook 地址", Type: "password", Required: true, Secret: true, Placeholder: "https://mattermost.example.com/hooks/..."},
+			{Name: "channel", Label: "默认频道", Type: "string", Placeholder: "town-square", Hint: "频道名（URL 中的名称），为空时使用 webhook 的默认频道"},
+			{Name: "username", Label: "发送者名称", Type: "string", Hint: "需要在系统设置中允许 webhook 覆盖用户名"},
+			{Name: "icon_emoji", Label: "头像 emoji", Type: "string", Placeholder: "bell"},
+			{Name: "icon_url", Label: "头像地址", Type: "string", Hint: "需要在系统设置中允许 webhook 覆盖头像"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+	})
+}
+
+// SlackNotifier Slack / Mattermost 通知服务
+type SlackNotifier struct {
+	kind   config.NotifiersType // Slack 或 Mattermost
+	config SlackConfig
+	client *resty.Client
+}
+
+// NewSlackNotifier 创建 Slack 或 Mattermost 通知服务实例
+func NewSlackNotifier(kind config.NotifiersType, cfg SlackConfig) *SlackNotifier {
+	client := resty.New()
+	client.SetTimeout(30 * time.Second)
+	if cfg.Proxy != "" {
+		client.SetProxy(cfg.Proxy)
+	}
+	if kind == Mattermost {
+		// Mattermost 不支持 Bot Token 方式
+		cfg.BotToken = ""
+	}
+	return &SlackNotifier{kind: kind, config: cfg, client: supportDryRun(client)}
+}
+
+// Name 返回服务名称
+func (s *SlackNotifier) Name() string {
+	return string(s.kind)
+}
+
+// IsEnabled 检查服务是否启用
+func (s *SlackNotifier) IsEnabled() bool {
+	return s.config.Enabled
+}
+
+// displayName 错误信息中的服务名称
+func (s *SlackNotifier) displayName() string {
+	if s.kind == Mattermost {
+		return "Mattermost"
+	}
+	return "Slack"
+}
+
+// Validate 验证配置
+func (s *SlackNotifier) Validate() error {
+	if !s.config.Enabled {
+		return nil
+	}
+	if s.config.WebhookURL == "" && s.config.BotToken == "" {
+		if s.kind == Mattermost {
+			return fmt.Errorf("Mattermost Webhook 地址不能为空")
+		}
+		return fmt.Errorf("Slack Webhook 地址和 Bot Token 不能都为空")
+	}
+	if s.config.BotToken == "" && !strings.HasPrefix(s.config.WebhookURL, "http://") && !strings.HasPrefix(s.config.WebhookURL, "https://") {
+		return fmt.Errorf("%s Webhook 地址必须以 http:// 或 https:// 开头", s.displayName())
+	}
+	return nil
+}
+
+// Limits 消息长度限制：Slack section 文本最长 3000 字符，Mattermost 消息最长 16383 字符
+func (s *SlackNotifier) Limits() Limits {
+	if s.kind == Mattermost {
+		return Limits{Text: 16000}
+	}
+	return Limits{Text: 3000}
+}
+
+// Send 发送通知消息，targets 为频道，为空时发送到默认频道
+func (s *SlackNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	if !s.config.Enabled {
+		return resultsFor(targets, time.Now(), "", permanentError("%s 通知服务未启用", s.displayName()))
+	}
+	channels := targets
+	if len(channels) == 0 {
+		channels = []string{s.config.Channel}
+	}
+
+	// 渠道服务器无法访问内网图片和 data: URI，incoming webhook 也不能上传文件
+	if remote, uploads := SeparateUploadImages(ctx, message); len(uploads) > 0 {
+		logger.Warn(s.displayName()+" 无法发送内网图片", "count", len(uploads))
+		message = remote
+	}
+
+	// 超长消息拆分为多条，图片只随第一条发送，按钮只随最后一条发送
+	parts := SplitMessage(message, s.Limits(), s.renderContent)
+	results := make(Results, 0, len(channels))
+	for _, channel := range channels {
+		start := time.Now()
+		if channel == "" && s.config.BotToken != "" {
+			results = append(results, NewResult(channel, start, "", permanentError("未指定频道，请配置默认频道或在消息中指定 targets")))
+			continue
+		}
+		messageID, sent, err := sendParts(ctx, []string{channel}, len(parts), func(i int) (string, error) {
+			return s.post(ctx, s.buildPayload(parts[i], channel))
+		})
+		results = append(results, partResults([]string{channel}, start, messageID, sent, len(parts), err)...)
+	}
+	return results
+}
+
+// CheckCredentials 使用 Bot Token 时调用 auth.test 检查；incoming webhook 只能通过发送消息验证
+func (s *SlackNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	if s.config.BotToken == "" {
+		return "Webhook 地址需要发送测试消息验证", nil
+	}
+	var result slackAPIResponse
+	resp, err := s.client.R().
+		SetContext(ctx).
+		SetAuthToken(s.config.BotToken).
+		SetResult(&result).
+		Post(slackAPIURL + "/auth.test")
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if err := result.err(resp); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("工作区 %s，机器人 %s", result.Team, result.User), nil
+}
+
+// renderContent 按方言输出正文，用于拆分时计算长度
+func (s *SlackNotifier) renderContent(message *NotificationMessage) string {
+	if s.kind == Mattermost {
+		return message.RenderContent(DialectMarkdown)
+	}
+	return message.RenderContent(DialectSlack)
+}
+
+// slackPayload incoming webhook 和 chat.postMessage 共用的请求体
+type slackPayload struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Text        string            `json:"text,omitempty"`
+	Blocks      []map[string]any  `json:"blocks,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+// slackAttachment 消息附件（Mattermost 使用）
+type slackAttachment struct {
+	Fallback  string       `json:"fallback"`
+	Color     string       `json:"color,omitempty"`
+	Title     string       `json:"title,omitempty"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text,omitempty"`
+	Fields    []slackField `json:"fields,omitempty"`
+	ImageURL  string       `json:"image_url,omitempty"`
+	Footer    string       `json:"footer,omitempty"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// buildPayload 按方言生成请求体
+func (s *SlackNotifier) buildPayload(message *NotificationMessage, channel string) slackPayload {
+	payload := slackPayload{
+		Channel:   channel,
+		Username:  s.config.Username,
+		IconURL:   s.config.IconURL,
+		IconEmoji: s.config.IconEmoji,
+	}
+	if s.kind == Mattermost {
+		payload.Attachments = []slackAttachment{s.buildAttachment(message)}
+		return payload
+	}
+
+	// text 用于通知和不支持 Block Kit 的客户端
+	payload.Text = message.DisplayTitle()
+	if payload.Text == "" {
+		payload.Text = truncateRunes(message.PlainContent(), 200)
+	}
+	payload.Blocks = s.buildBlocks(message)
+	return payload
+}
+
+const (
+	// slackMaxBlocks 一条消息最多 50 个 block，超出时 Slack 返回 invalid_blocks
+	slackMaxBlocks = 50
+	// slackMaxImages 最多显示的图片数
+	slackMaxImages = 10
+)
+
+// buildBlocks 生成 Block Kit：标题、正文、字段、图片、按钮和时间
+//
+// 标题、正文、按钮和时间各占一个 block，其余的 block 依次分配给字段和图片，超出的字段和图片不再显示。
+func (s *SlackNotifier) buildBlocks(message *NotificationMessage) []map[string]any {
+	var blocks []map[string]any
+	title := message.DisplayTitle()
+	content := message.RenderContent(DialectSlack)
+	actions := message.AllActions()
+
+	budget := slackMaxBlocks
+	for _, present := range []bool{title != "", content != "", len(actions) > 0, message.Timestamp != ""} {
+		if present {
+			budget--
+		}
+	}
+
+	if title != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "header",
+			"text": map[string]any{"type": "plain_text", "text": truncateRunes(title, 150), "emoji": true},
+		})
+	}
+	if content != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]any{"type": "mrkdwn", "text": content},
+		})
+	}
+	// section 最多 10 个字段
+	for i := 0; i < len(message.Fields) && budget > 0; i += 10 {
+		var fields []map[string]any
+		for _, field := range message.Fields[i:min(i+10, len(message.Fields))] {
+			text := "*" + EscapeText(field.Key, DialectSlack) + "*\n" + EscapeText(field.Value, DialectSlack)
+			fields = append(fields, map[string]any{"type": "mrkdwn", "text": truncateRunes(text, 2000)})
+		}
+		blocks = append(blocks, map[string]any{"type": "section", "fields": fields})
+		budget--
+	}
+	images := message.AllImages()
+	for _, image := range images[:min(len(images), slackMaxImages, budget)] {
+		alt := message.Title
+		if alt == "" {
+			alt = "image"
+		}
+		blocks = append(blocks, map[string]any{"type": "image", "image_url": image, "alt_text": truncateRunes(alt, 2000)})
+	}
+	if len(actions) > 0 {
+		var buttons []map[string]any
+		for i, action := range actions[:min(len(actions), 25)] {
+			button := map[string]any{
+				"type": "button",
+				"text": map[string]any{"type": "plain_text", "text": truncateRunes(action.Label, 75), "emoji": true},
+				"url":  action.URL,
+			}
+			if i == 0 {
+				button["style"] = "primary"
+			}
+			buttons = append(buttons, button)
+		}
+		blocks = append(blocks, map[string]any{"type": "actions", "elements": buttons})
+	}
+	if message.Timestamp != "" {
+		blocks = append(blocks, map[string]any{
+			"type":     "context",
+			"elements": []map[string]any{{"type": "mrkdwn", "text": EscapeText(message.Timestamp, DialectSlack)}},
+		})
+	}
+	return blocks
+}
+
+// buildAttachment 生成 Mattermost 附件：级别颜色、标题链接、markdown 正文和按钮链接
+func (s *SlackNotifier) buildAttachment(message *NotificationMessage) slackAttachment {
+	attachment := slackAttachment{
+		Fallback:  message.DisplayTitle(),
+		Color:     message.Level.Color(),
+		Title:     message.DisplayTitle(),
+		TitleLink: message.URL,
+		Footer:    message.Timestamp,
+	}
+	if attachment.Fallback == "" {
+		attachment.Fallback = truncateRunes(message.PlainContent(), 200)
+	}
+
+	var lines []string
+	if content := message.RenderContent(DialectMarkdown); content != "" {
+		lines = append(lines, content)
+	}
+	images := message.AllImages()
+	if len(images) > 0 {
+		attachment.ImageURL = images[0]
+		// 附件只能显示一张图片，其余图片内嵌在正文中
+		for _, image := range images[1:] {
+			lines = append(lines, "![]("+image+")")
+		}
+	}
+	// incoming webhook 的附件按钮需要交互集成，这里输出为链接
+	var links []string
+	for _, action := range message.AllActions() {
+		links = append(links, "["+action.Label+"]("+action.URL+")")
+	}
+	if len(links) > 0 {
+		lines = append(lines, strings.Join(links, " · "))
+	}
+	attachment.Text = strings.Join(lines, "\n\n")
+
+	for _, field := range message.Fields {
+		attachment.Fields = append(attachment.Fields, slackField{
+			Title: field.Key,
+			Value: field.Value,
+			Short: utf8.RuneCountInString(field.Value) <= 40,
+		})
+	}
+	return attachment
+}
+
+// slackAPIResponse Slack Web API 响应
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	TS      string `json:"ts"`
+	Channel string `json:"channel"`
+	Team    string `json:"team"`
+	User    string `json:"user"`
+}
+
+// slackRetryableErrors 可以重试的 Web API 错误
+var slackRetryableErrors = map[string]bool{
+	"ratelimited":         true,
+	"service_unavailable": true,
+	"internal_error":      true,
+	"fatal_error":         true,
+	"request_timeout":     true,
+}
+
+// err 将 Web API 响应转换为错误
+func (r *slackAPIResponse) err(resp *resty.Response) error {
+	if resp.StatusCode() == 429 || resp.StatusCode() >= 500 {
+		return httpStatusError(resp)
+	}
+	if r.OK {
+		return nil
+	}
+	if r.Error == "" {
+		return httpStatusError(resp)
+	}
+	pe := providerError(r.Error, "Slack 接口返回错误: "+r.Error, slackRetryableErrors[r.Error])
+	pe.RetryAfter = parseRetryAfter(resp.Header().Get("Retry-After"))
+	return pe
+}
+
+// post 发送请求体，返回消息 ID（只有 chat.postMessage 返回）
+func (s *SlackNotifier) post(ctx context.Context, payload slackPayload) (string, error) {
+	if s.config.BotToken != "" {
+		var result slackAPIResponse
+		resp, err := s.client.R().
+			SetContext(ctx).
+			SetAuthToken(s.config.BotToken).
+			SetHeader("Content-Type", "application/json; charset=utf-8").
+			SetBody(payload).
+			SetResult(&result).
+			Post(slackAPIURL + "/chat.postMessage")
+		if err != nil {
+			return "", fmt.Errorf("请求失败: %w", err)
+		}
+		if err := result.err(resp); err != nil {
+			return "", err
+		}
+		return result.TS, nil
+	}
+
+	resp, err := s.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(payload).
+		Post(s.config.WebhookURL)
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if resp.IsSuccess() {
+		return "", nil
+	}
+	return "", s.webhookError(resp)
+}
+
+// webhookError 解析 webhook 的错误响应：Slack 为错误码文本（例如 channel_not_found），
+// Mattermost 为 JSON（message 字段）
+func (s *SlackNotifier) webhookError(resp *resty.Response) error {
+	pe := httpStatusError(resp)
+	body := strings.TrimSpace(resp.String())
+	var mm struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	}
+	switch {
+	case json.Unmarshal(resp.Body(), &mm) == nil && mm.Message != "":
+		pe.Message += "，" + mm.Message
+		if mm.ID != "" {
+			pe.Code = mm.ID
+		}
+	case body != "" && !strings.ContainsAny(body, " <\n") && len(body) <= 64:
+		pe.Code = body
+		pe.Message += "，" + body
+	case body != "":
+		pe.Message += "，响应: " + truncateString(body)
+	}
+	return pe
+}
+
+// truncateRunes 按字符截断文本，超长时以 … 结尾
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
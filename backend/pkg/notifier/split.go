@@ -0,0 +1,298 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits 通知渠道的消息长度限制
+type Limits struct {
+	Text    int  // 单条消息最大长度
+	Caption int  // 图片说明最大长度（例如 Telegram sendPhoto），0 表示与 Text 相同
+	Bytes   bool // 按 UTF-8 字节计数（企业微信、钉钉），否则按字符计数
+}
+
+// Limiter 可选接口：声明通知服务的消息长度限制，超长的消息会被拆分为多条发送
+type Limiter interface {
+	Limits() Limits
+}
+
+// count 按限制的计数方式计算长度
+func (l Limits) count(s string) int {
+	if l.Bytes {
+		return len(s)
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// partSuffixReserve 计算长度时为标题的分段序号预留的位置，拆分超过 99 段时按实际位数重新拆分
+const partSuffixReserve = " (99/99)"
+
+// SplitMessage 按长度限制将消息拆分为多条
+//
+// render 返回某一段消息最终发送的文本（包含标题、字段等），用于计算长度。
+// 内容优先在段落（空行）处拆分，其次在行尾拆分，单行超长时才按字符截断；
+// 代码块跨段时在前一段末尾补上结束标记并在后一段开头重新打开。
+// 图片只保留在第一段，按钮、链接和键值字段只保留在最后一段，拆分后的标题带上 (序号/总数)。
+func SplitMessage(message *NotificationMessage, limits Limits, render func(*NotificationMessage) string) []*NotificationMessage {
+	if limits.Text <= 0 {
+		return []*NotificationMessage{message}
+	}
+	firstLimit := limits.Text
+	if limits.Caption > 0 && len(message.AllImages()) > 0 {
+		firstLimit = limits.Caption
+	}
+	if limits.count(render(message)) <= firstLimit {
+		return []*NotificationMessage{message}
+	}
+
+	reserve := partSuffixReserve
+	for {
+		s := &splitter{message: message, limits: limits, render: render, firstLimit: firstLimit, reserve: reserve}
+		parts := s.split()
+		n := len(strconv.Itoa(len(parts)))
+		if n <= 2 || len(reserve) >= 4+2*n {
+			return parts
+		}
+		digits := strings.Repeat("9", n)
+		reserve = " (" + digits + "/" + digits + ")"
+	}
+}
+
+type splitter struct {
+	message    *NotificationMessage
+	limits     Limits
+	render     func(*NotificationMessage) string
+	firstLimit int
+	reserve    string // 标题序号预留的位置
+
+	chunks []string // 已完成的各段内容
+	lines  []string // 当前段的行
+	fence  string   // 当前段末尾所在代码块的开始标记，不在代码块中时为空
+}
+
+// part 生成第 index 段消息，last 表示是否为最后一段
+func (s *splitter) part(content string, index int, last bool) *NotificationMessage {
+	part := *s.message
+	part.Content = content
+	if index > 0 {
+		part.Image = ""
+		part.Images = nil
+	}
+	if !last {
+		part.URL = ""
+		part.Actions = nil
+		part.Fields = nil
+	}
+	return &part
+}
+
+// text 输出段内容，仍在代码块中时补上结束标记
+func (s *splitter) text(lines []string, fence string) string {
+	text := strings.Join(lines, "\n")
+	if fence != "" {
+		text += "\n```"
+	}
+	return text
+}
+
+// fits 判断当前段加上 line 后是否超出限制；无法确定是否为最后一段时按更长的情况计算
+func (s *splitter) fits(line string, fence string) bool {
+	limit := s.limits.Text
+	if len(s.chunks) == 0 {
+		limit = s.firstLimit
+	}
+	lines := append(append([]string(nil), s.lines...), line)
+	part := s.part(s.text(lines, fence), len(s.chunks), true)
+	part.Title += s.reserve
+	return s.limits.count(s.render(part)) <= limit
+}
+
+// empty 当前段是否还没有内容（只有重新打开的代码块标记也算空）
+func (s *splitter) empty() bool {
+	return len(s.lines) == 0 || (len(s.lines) == 1 && s.fence != "" && s.lines[0] == s.fence)
+}
+
+// flush 结束当前段，代码块跨段时在下一段开头重新打开
+func (s *splitter) flush() {
+	s.chunks = append(s.chunks, s.text(s.lines, s.fence))
+	s.lines = nil
+	if s.fence != "" {
+		s.lines = []string{s.fence}
+	}
+}
+
+func (s *splitter) split() []*NotificationMessage {
+	for _, line := range strings.Split(strings.ReplaceAll(s.message.Content, "\r\n", "\n"), "\n") {
+		nextFence := s.fence
+		if codeFencePattern.MatchString(line) {
+			if s.fence == "" {
+				nextFence = strings.TrimSpace(line)
+			} else {
+				nextFence = ""
+			}
+		}
+
+		if s.fits(line, nextFence) {
+			s.lines, s.fence = append(s.lines, line), nextFence
+			continue
+		}
+
+		if !s.empty() {
+			// 优先在段落边界拆分，空行之后的行移到下一段
+			if cut := lastBlankLine(s.lines); s.fence == "" && cut > len(s.lines)/2 {
+				rest := append([]string(nil), s.lines[cut+1:]...)
+				s.lines = s.lines[:cut]
+				s.flush()
+				s.lines = rest
+				if s.fits(line, nextFence) {
+					s.lines, s.fence = append(s.lines, line), nextFence
+					continue
+				}
+			}
+			if !s.empty() {
+				s.flush()
+			}
+			if s.fits(line, nextFence) {
+				s.lines, s.fence = append(s.lines, line), nextFence
+				continue
+			}
+		}
+
+		// 单行超长，按字符截断
+		runes := []rune(line)
+		for len(runes) > 0 {
+			n := s.maxRunes(runes, nextFence)
+			if n == len(runes) {
+				s.lines = append(s.lines, string(runes))
+				break
+			}
+			s.lines = append(s.lines, string(runes[:n]))
+			s.flush()
+			runes = runes[n:]
+		}
+		s.fence = nextFence
+	}
+	if !s.empty() {
+		s.chunks = append(s.chunks, s.text(s.lines, s.fence))
+	}
+
+	// 去掉空段并生成带序号的消息
+	var chunks []string
+	for _, chunk := range s.chunks {
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, strings.Trim(chunk, "\n"))
+		}
+	}
+	if len(chunks) == 0 {
+		return []*NotificationMessage{s.message}
+	}
+	parts := make([]*NotificationMessage, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = s.part(chunk, i, i == len(chunks)-1)
+		if len(chunks) > 1 {
+			parts[i].Title = fmt.Sprintf("%s (%d/%d)", s.message.Title, i+1, len(chunks))
+		}
+	}
+	return parts
+}
+
+// maxRunes 二分查找当前段还能容纳的最大字符数，至少为 1 以保证能继续拆分
+func (s *splitter) maxRunes(runes []rune, fence string) int {
+	lo, hi := 1, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if s.fits(string(runes[:mid]), fence) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// lastBlankLine 返回最后一个空行的位置，没有空行时返回 -1
+func lastBlankLine(lines []string) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			return i
+		}
+	}
+	return -1
+}
+
+type previousResultsKey struct{}
+
+// WithPreviousResults 返回携带上次投递结果的 context，拆分发送的消息重试时跳过已成功发送的条目
+func WithPreviousResults(ctx context.Context, results Results) context.Context {
+	return context.WithValue(ctx, previousResultsKey{}, results)
+}
+
+// resumePart 返回 targets 上次已成功发送的条数和第一条的消息 ID，取所有目标中最少的条数；
+// 总条数与本次不同时（例如修改了配置）从头发送
+func resumePart(ctx context.Context, targets []string, total int) (int, string) {
+	previous, _ := ctx.Value(previousResultsKey{}).(Results)
+	if len(previous) == 0 || total <= 1 {
+		return 0, ""
+	}
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+	sent, messageID := total, ""
+	for _, target := range targets {
+		found := false
+		for _, r := range previous {
+			if r.Target != target || r.Success {
+				continue
+			}
+			if r.Parts != total {
+				return 0, ""
+			}
+			found = true
+			if r.SentParts < sent {
+				sent, messageID = r.SentParts, r.ProviderMessageID
+			}
+		}
+		if !found {
+			return 0, ""
+		}
+	}
+	return sent, messageID
+}
+
+// sendParts 依次发送 total 条消息，任意一条失败即停止，重试时从上次失败的条目继续；
+// 返回第一条的消息 ID 和已成功发送的条数
+func sendParts(ctx context.Context, targets []string, total int, send func(i int) (string, error)) (string, int, error) {
+	sent, messageID := resumePart(ctx, targets, total)
+	for ; sent < total; sent++ {
+		id, err := send(sent)
+		if err != nil {
+			if total > 1 {
+				err = fmt.Errorf("第 %d/%d 条: %w", sent+1, total, err)
+			}
+			return messageID, sent, err
+		}
+		if sent == 0 {
+			messageID = id
+		}
+	}
+	return messageID, sent, nil
+}
+
+// partResults 生成拆分发送的结果，部分条目已发送时记录进度和第一条的消息 ID
+func partResults(targets []string, start time.Time, messageID string, sent, total int, err error) Results {
+	results := resultsFor(targets, start, messageID, err)
+	if err == nil || sent == 0 {
+		return results
+	}
+	for i := range results {
+		results[i].ProviderMessageID = messageID
+		results[i].SentParts = sent
+		results[i].Parts = total
+	}
+	return results
+}
@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func renderTitleAndContent(m *NotificationMessage) string {
+	return m.Title + "\n" + m.Content
+}
+
+func TestSplitMessageFitsWithinLimit(t *testing.T) {
+	message := &NotificationMessage{Title: "标题", Content: "短消息"}
+	parts := SplitMessage(message, Limits{Text: 100}, renderTitleAndContent)
+	if len(parts) != 1 || parts[0] != message {
+		t.Fatalf("未超长的消息不应拆分，得到 %d 段", len(parts))
+	}
+}
+
+func TestSplitMessageLimitMath(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		line   string
+	}{
+		{name: "按字符计数", limits: Limits{Text: 60}, line: "中文内容一二三四五六七八九十"},
+		{name: "按字节计数", limits: Limits{Text: 80, Bytes: true}, line: "中文内容一二三四五六七八九十"},
+		{name: "单行超长按字符截断", limits: Limits{Text: 40}, line: strings.Repeat("长", 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([]string, 20)
+			for i := range lines {
+				lines[i] = tt.line
+			}
+			message := &NotificationMessage{Title: "告警", Content: strings.Join(lines, "\n")}
+			parts := SplitMessage(message, tt.limits, renderTitleAndContent)
+			if len(parts) < 2 {
+				t.Fatalf("超长消息应拆分为多段，得到 %d 段", len(parts))
+			}
+			var content []string
+			for i, part := range parts {
+				if n := tt.limits.count(renderTitleAndContent(part)); n > tt.limits.Text {
+					t.Errorf("第 %d 段长度 %d 超出限制 %d", i+1, n, tt.limits.Text)
+				}
+				if tt.limits.Bytes && !utf8.ValidString(part.Content) {
+					t.Errorf("第 %d 段在多字节字符中间截断", i+1)
+				}
+				content = append(content, part.Content)
+			}
+			joined := strings.ReplaceAll(strings.Join(content, ""), "\n", "")
+			if want := strings.ReplaceAll(message.Content, "\n", ""); joined != want {
+				t.Errorf("拆分后内容丢失或重复")
+			}
+		})
+	}
+}
+
+func TestSplitMessageCaptionLimit(t *testing.T) {
+	message := &NotificationMessage{
+		Title:   "图片",
+		Image:   "https://example.com/a.png",
+		Content: strings.Repeat("第一行内容\n", 30),
+	}
+	parts := SplitMessage(message, Limits{Text: 200, Caption: 50}, renderTitleAndContent)
+	if n := utf8.RuneCountInString(renderTitleAndContent(parts[0])); n > 50 {
+		t.Errorf("第一段带图片，长度 %d 应不超过图片说明限制 50", n)
+	}
+	for i, part := range parts[1:] {
+		if len(part.AllImages()) > 0 {
+			t.Errorf("图片只应随第一段发送，第 %d 段仍有图片", i+2)
+		}
+	}
+}
+
+func TestSplitMessageTitleAndTrailer(t *testing.T) {
+	message := &NotificationMessage{
+		Title:   "标题",
+		Content: strings.Repeat("内容\n\n", 40),
+		URL:     "https://example.com",
+		Fields:  []Field{{Key: "k", Value: "v"}},
+	}
+	parts := SplitMessage(message, Limits{Text: 60}, renderTitleAndContent)
+	for i, part := range parts {
+		if !strings.HasPrefix(part.Title, "标题 (") || !strings.Contains(part.Title, "/") {
+			t.Errorf("第 %d 段标题应带序号，得到 %q", i+1, part.Title)
+		}
+		last := i == len(parts)-1
+		if (part.URL != "") != last || (len(part.Fields) > 0) != last {
+			t.Errorf("链接和字段只应随最后一段发送，第 %d 段: url=%q fields=%d", i+1, part.URL, len(part.Fields))
+		}
+	}
+}
+
+func TestSplitMessageRebalancesCodeFence(t *testing.T) {
+	code := make([]string, 30)
+	for i := range code {
+		code[i] = "echo line"
+	}
+	message := &NotificationMessage{
+		Title:   "脚本",
+		Content: "开头\n```bash\n" + strings.Join(code, "\n") + "\n```\n结尾",
+	}
+	parts := SplitMessage(message, Limits{Text: 80}, renderTitleAndContent)
+	if len(parts) < 3 {
+		t.Fatalf("代码块应跨多段，得到 %d 段", len(parts))
+	}
+	for i, part := range parts {
+		if n := strings.Count(part.Content, "```"); n%2 != 0 {
+			t.Errorf("第 %d 段代码块标记不成对: %q", i+1, part.Content)
+		}
+		if i > 0 && i < len(parts)-1 && !strings.HasPrefix(part.Content, "```bash") {
+			t.Errorf("第 %d 段应重新打开代码块并保留语言: %q", i+1, part.Content)
+		}
+	}
+}
+
+func TestSendPartsResumesFromFailedPart(t *testing.T) {
+	ctx := context.Background()
+	var sent []int
+	fail := 2
+	send := func(i int) (string, error) {
+		if i == fail {
+			return "", errors.New("网络错误")
+		}
+		sent = append(sent, i)
+		return "id" + string(rune('0'+i)), nil
+	}
+
+	messageID, n, err := sendParts(ctx, []string{"user"}, 4, send)
+	if err == nil || n != 2 || messageID != "id0" {
+		t.Fatalf("第 3 条失败时应返回已发送 2 条: id=%q n=%d err=%v", messageID, n, err)
+	}
+	results := partResults([]string{"user"}, time.Now(), messageID, n, 4, err)
+	if results[0].SentParts != 2 || results[0].Parts != 4 || results[0].ProviderMessageID != "id0" {
+		t.Fatalf("失败结果应记录进度: %+v", results[0])
+	}
+
+	// 重试时从第 3 条继续，并保留第一条的消息 ID
+	sent, fail = nil, -1
+	messageID, n, err = sendParts(WithPreviousResults(ctx, results), []string{"user"}, 4, send)
+	if err != nil || n != 4 || messageID != "id0" {
+		t.Fatalf("重试应成功: id=%q n=%d err=%v", messageID, n, err)
+	}
+	if len(sent) != 2 || sent[0] != 2 || sent[1] != 3 {
+		t.Errorf("重试应只发送第 3、4 条，实际发送 %v", sent)
+	}
+
+	// 总条数变化时从头发送
+	sent = nil
+	if _, _, err := sendParts(WithPreviousResults(ctx, results), []string{"user"}, 5, send); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 5 {
+		t.Errorf("总条数变化时应全部重新发送，实际发送 %v", sent)
+	}
+}
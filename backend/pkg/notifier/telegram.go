@@ -1,8 +1,12 @@
 package notifier
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jianxcao/notify/backend/pkg/config"
@@ -11,21 +15,67 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
+// TelegramAppBot Telegram 机器人
+const TelegramAppBot config.NotifiersType = "telegramAppBot"
+
+// TelegramConfig Telegram配置
+type TelegramConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	BotToken string `yaml:"bot_token" json:"botToken"`
+	ChatID   string `yaml:"chat_id" json:"chatId"` // 默认发送的聊天ID
+	Proxy    string `yaml:"proxy" json:"proxy"`    // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(TelegramAppBot, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg TelegramConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewTelegramNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "Telegram",
+		Description: "通过 @BotFather 的 /newbot 创建机器人并添加到群组或频道，访问 https://api.telegram.org/bot<TOKEN>/getUpdates 查看 chat.id；消息中的 targets 为 Chat ID",
+		Fields: []FieldSchema{
+			{Name: "bot_token", Label: "Bot Token", Type: "password", Required: true, Secret: true, Hint: "从 @BotFather 获取"},
+			{Name: "chat_id", Label: "Chat ID", Type: "string", Hint: "群组或频道ID，可以是负数, 多个用逗号分隔"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// 机器人每秒最多 30 条，同一群组每分钟最多 20 条
+		RateLimit: &config.RateLimit{Rate: "30/s", PerTarget: "20/m"},
+	})
+}
+
 // TelegramNotifier Telegram通知服务
 type TelegramNotifier struct {
-	config config.TelegramConfig
+	config TelegramConfig
 	client *resty.Client
 }
 
 // TelegramResponse Telegram API响应结构
 type TelegramResponse struct {
-	OK          bool   `json:"ok"`
-	Description string `json:"description,omitempty"`
-	ErrorCode   int    `json:"error_code,omitempty"`
+	OK          bool                        `json:"ok"`
+	Description string                      `json:"description,omitempty"`
+	ErrorCode   int                         `json:"error_code,omitempty"`
+	Result      json.RawMessage             `json:"result,omitempty"` // 单条消息或消息数组（相册）
+	Parameters  *TelegramResponseParameters `json:"parameters,omitempty"`
+}
+
+// TelegramResponseParameters 错误响应的附加信息
+type TelegramResponseParameters struct {
+	RetryAfter      int   `json:"retry_after,omitempty"`        // 限流时需要等待的秒数
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"` // 群组已升级为超级群组时的新ID
+}
+
+// telegramMessage Telegram 消息对象中需要的字段
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
 }
 
 // NewTelegramNotifier 创建Telegram通知服务实例
-func NewTelegramNotifier(cfg config.TelegramConfig) *TelegramNotifier {
+func NewTelegramNotifier(cfg TelegramConfig) *TelegramNotifier {
 	client := resty.New()
 	client.SetTimeout(100 * time.Second)
 	client.SetRetryCount(3)
@@ -38,13 +88,13 @@ func NewTelegramNotifier(cfg config.TelegramConfig) *TelegramNotifier {
 
 	return &TelegramNotifier{
 		config: cfg,
-		client: client,
+		client: supportDryRun(client),
 	}
 }
 
 // Name 返回服务名称
 func (t *TelegramNotifier) Name() string {
-	return "telegram"
+	return string(TelegramAppBot)
 }
 
 // IsEnabled 检查服务是否启用
@@ -61,100 +111,284 @@ func (t *TelegramNotifier) Validate() error {
 	if t.config.BotToken == "" {
 		return fmt.Errorf("telegram Bot Token 不能为空")
 	}
-	if t.config.ChatID == "" {
-		return fmt.Errorf("telegram Chat ID 不能为空")
-	}
 
 	return nil
 }
 
 // Send 发送通知消息
-func (t *TelegramNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) error {
+func (t *TelegramNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
 	if !t.config.Enabled {
-		return fmt.Errorf("Telegram通知服务未启用")
+		return resultsFor(targets, time.Now(), "", permanentError("Telegram通知服务未启用"))
+	}
+	users := targets
+	if len(users) == 0 {
+		users = splitList(t.config.ChatID)
 	}
-	users := []string{t.config.ChatID}
-	if len(targets) > 0 {
-		users = targets
+	if len(users) == 0 {
+		return Results{NewResult("", time.Now(), "", permanentError("未指定 Chat ID"))}
 	}
+	// 超长消息拆分为多条，图片只随第一条发送
+	steps := t.buildSteps(SplitMessage(message, t.Limits(), t.buildText))
+	results := make(Results, 0, len(users))
 	for _, user := range users {
-		// 使用message.Targets作为@提及的用户名列表
-		if message.Image != "" {
-			err := t.sendPhotoMessage(ctx, user, message)
-			if err != nil {
-				return fmt.Errorf("发送图片消息失败: %w", err)
-			}
-		} else {
-			err := t.sendTextMessage(ctx, user, message)
-			if err != nil {
-				return fmt.Errorf("发送文本消息失败: %w", err)
-			}
+		start := time.Now()
+		messageID, sent, err := sendParts(ctx, []string{user}, len(steps), func(i int) (string, error) {
+			return steps[i](ctx, user)
+		})
+		results = append(results, partResults([]string{user}, start, messageID, sent, len(steps), err)...)
+	}
+
+	return results
+}
+
+// telegramStep 发送一条消息，返回消息 ID
+type telegramStep func(ctx context.Context, chatID string) (string, error)
+
+// buildSteps 将拆分后的消息转换为依次发送的请求：相册不支持按钮，按钮作为单独的一条发送，
+// 重试时不会重复发送已成功的相册
+func (t *TelegramNotifier) buildSteps(parts []*NotificationMessage) []telegramStep {
+	steps := make([]telegramStep, 0, len(parts))
+	for _, part := range parts {
+		steps = append(steps, func(ctx context.Context, chatID string) (string, error) {
+			return t.sendPart(ctx, chatID, part)
+		})
+		if len(part.AllImages()) > 1 && t.buildKeyboard(part) != nil {
+			steps = append(steps, func(ctx context.Context, chatID string) (string, error) {
+				id, err := t.sendKeyboardMessage(ctx, chatID, part)
+				if err != nil {
+					return "", fmt.Errorf("发送按钮消息失败: %w", err)
+				}
+				return id, nil
+			})
 		}
 	}
+	return steps
+}
 
-	return nil
+// CheckCredentials 调用 getMe 检查 Bot Token，返回机器人的用户名
+func (t *TelegramNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	var result TelegramResponse
+	resp, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetError(&result).
+		Get(fmt.Sprintf("https://api.telegram.org/bot%s/getMe", t.config.BotToken))
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	if !result.OK {
+		if result.ErrorCode != 0 {
+			return "", providerError(result.ErrorCode, "检查 Bot Token 失败: "+result.Description, false)
+		}
+		return "", httpStatusError(resp)
+	}
+
+	var bot struct {
+		Username string `json:"username"`
+	}
+	_ = json.Unmarshal(result.Result, &bot)
+	return fmt.Sprintf("机器人 @%s", bot.Username), nil
 }
 
-// sendTextMessage 发送文本消息
-func (t *TelegramNotifier) sendTextMessage(ctx context.Context, chatID string, message *NotificationMessage) error {
-	content := fmt.Sprintf("*%s*\n\n%s", message.Title, message.Content)
+// Limits 消息长度限制：文本消息 4096 字符，图片说明 1024 字符
+func (t *TelegramNotifier) Limits() Limits {
+	return Limits{Text: 4096, Caption: 1024}
+}
 
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.config.BotToken)
-	// TODO: 消息超过4096字符的处理
-	requestBody := map[string]interface{}{
-		"chat_id":    chatID,
-		"text":       content,
-		"parse_mode": "Markdown",
-	}
-
-	// 如果有URL，添加inline keyboard按钮
-	if message.URL != "" {
-		requestBody["reply_markup"] = map[string]interface{}{
-			"inline_keyboard": [][]map[string]interface{}{
-				{
-					{
-						"text": "🔗 查看详情",
-						"url":  message.URL,
-					},
-				},
-			},
+// sendPart 按图片数量选择发送方式发送一条消息
+func (t *TelegramNotifier) sendPart(ctx context.Context, chatID string, message *NotificationMessage) (string, error) {
+	switch images := message.AllImages(); {
+	case len(images) > 1:
+		messageID, err := t.sendMediaGroupMessage(ctx, chatID, message, images)
+		if err != nil {
+			return "", fmt.Errorf("发送相册消息失败: %w", err)
+		}
+		return messageID, nil
+	case len(images) == 1:
+		messageID, err := t.sendPhotoMessage(ctx, chatID, message, images[0])
+		if err != nil {
+			return "", fmt.Errorf("发送图片消息失败: %w", err)
 		}
+		return messageID, nil
+	default:
+		messageID, err := t.sendTextMessage(ctx, chatID, message)
+		if err != nil {
+			return "", fmt.Errorf("发送文本消息失败: %w", err)
+		}
+		return messageID, nil
+	}
+}
+
+// parseMode 根据消息格式选择 Telegram 的 parse_mode：HTML 内容过滤为支持的标签后发送，其余转换为 MarkdownV2
+func (t *TelegramNotifier) parseMode(message *NotificationMessage) string {
+	if message.Format == FormatHTML {
+		return "HTML"
+	}
+	return "MarkdownV2"
+}
+
+// buildText 构建消息正文：标题、正文和键值字段
+func (t *TelegramNotifier) buildText(message *NotificationMessage) string {
+	dialect := DialectTelegramV2
+	content := message.RenderContent(dialect)
+	if t.parseMode(message) == "HTML" {
+		// Telegram 只支持少量 HTML 标签，段落等其他标签会导致发送失败
+		dialect = DialectTelegramHTML
+		content = sanitizeHTML(message.Content, telegramHTMLPolicy)
+	}
+
+	var parts []string
+	if title := message.DisplayTitle(); title != "" {
+		parts = append(parts, wrapStyle(nodeBold, EscapeText(title, dialect), dialect))
+	}
+	parts = append(parts, content)
+	if len(message.Fields) > 0 {
+		fields := make([]string, len(message.Fields))
+		for i, field := range message.Fields {
+			fields[i] = wrapStyle(nodeBold, EscapeText(field.Key, dialect), dialect) + ": " + EscapeText(field.Value, dialect)
+		}
+		parts = append(parts, strings.Join(fields, "\n"))
+	}
+	return joinNonEmpty(parts, "\n\n")
+}
+
+// buildKeyboard 将按钮转换为 inline keyboard，每行一个按钮
+func (t *TelegramNotifier) buildKeyboard(message *NotificationMessage) map[string]interface{} {
+	actions := message.AllActions()
+	if len(actions) == 0 {
+		return nil
+	}
+	rows := make([][]map[string]interface{}, 0, len(actions))
+	for _, action := range actions {
+		label := action.Label
+		if action.URL == message.URL && label == "查看详情" {
+			label = "🔗 " + label
+		}
+		rows = append(rows, []map[string]interface{}{{"text": label, "url": action.URL}})
+	}
+	return map[string]interface{}{"inline_keyboard": rows}
+}
+
+// newRequestBody 构建请求体，并按消息格式设置 parse_mode
+func (t *TelegramNotifier) newRequestBody(chatID string, message *NotificationMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"chat_id":    chatID,
+		"parse_mode": t.parseMode(message),
+	}
+}
+
+// sendTextMessage 发送文本消息
+func (t *TelegramNotifier) sendTextMessage(ctx context.Context, chatID string, message *NotificationMessage) (string, error) {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.config.BotToken)
+	requestBody := t.newRequestBody(chatID, message)
+	requestBody["text"] = t.buildText(message)
+
+	// 如果有按钮，添加inline keyboard
+	if keyboard := t.buildKeyboard(message); keyboard != nil {
+		requestBody["reply_markup"] = keyboard
 	}
 
 	return t.sendRequest(ctx, apiURL, requestBody)
 }
 
 // sendPhotoMessage 发送图片消息
-func (t *TelegramNotifier) sendPhotoMessage(ctx context.Context, chatID string, message *NotificationMessage) error {
-	caption := fmt.Sprintf("*%s*\n\n%s", message.Title, message.Content)
+func (t *TelegramNotifier) sendPhotoMessage(ctx context.Context, chatID string, message *NotificationMessage, photo string) (string, error) {
 	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendPhoto", t.config.BotToken)
 
-	requestBody := map[string]interface{}{
-		"chat_id":    chatID,
-		"photo":      message.Image,
-		"caption":    caption,
-		"parse_mode": "Markdown",
-	}
-
-	// 如果有URL，添加inline keyboard按钮
-	if message.URL != "" {
-		requestBody["reply_markup"] = map[string]interface{}{
-			"inline_keyboard": [][]map[string]interface{}{
-				{
-					{
-						"text": "🔗 查看详情",
-						"url":  message.URL,
-					},
-				},
-			},
+	requestBody := t.newRequestBody(chatID, message)
+	requestBody["photo"] = photo
+	requestBody["caption"] = t.buildText(message)
+
+	// 如果有按钮，添加inline keyboard
+	if keyboard := t.buildKeyboard(message); keyboard != nil {
+		requestBody["reply_markup"] = keyboard
+	}
+
+	// 内网图片和 data: URI 由服务端获取后以文件形式上传
+	if NeedsUpload(ctx, photo) {
+		image, err := FetchImage(ctx, photo, telegramImageLimits)
+		if err != nil {
+			return "", permanentError("获取图片失败: %v", err)
 		}
+		delete(requestBody, "photo")
+		return t.sendMultipart(ctx, apiURL, requestBody, map[string]*ImageData{"photo": image})
 	}
 
 	return t.sendRequest(ctx, apiURL, requestBody)
 }
 
-// sendRequest 发送HTTP请求
-func (t *TelegramNotifier) sendRequest(ctx context.Context, apiURL string, requestBody map[string]interface{}) error {
+// sendMediaGroupMessage 发送多张图片，正文作为第一张图片的说明；按钮由 sendKeyboardMessage 另外发送
+func (t *TelegramNotifier) sendMediaGroupMessage(ctx context.Context, chatID string, message *NotificationMessage, images []string) (string, error) {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMediaGroup", t.config.BotToken)
+
+	// 一个相册最多 10 张图片
+	if len(images) > 10 {
+		images = images[:10]
+	}
+	media := make([]map[string]interface{}, 0, len(images))
+	files := map[string]*ImageData{}
+	for _, image := range images {
+		if NeedsUpload(ctx, image) {
+			data, err := FetchImage(ctx, image, telegramImageLimits)
+			if err != nil {
+				logger.Error("获取图片失败", "image", AbbreviateImage(image), "error", err)
+				continue
+			}
+			name := fmt.Sprintf("photo%d", len(files))
+			files[name] = data
+			image = "attach://" + name
+		}
+		media = append(media, map[string]interface{}{"type": "photo", "media": image})
+	}
+	if len(media) == 0 {
+		return "", permanentError("没有可以发送的图片")
+	}
+	media[0]["caption"] = t.buildText(message)
+	media[0]["parse_mode"] = t.parseMode(message)
+
+	requestBody := map[string]interface{}{
+		"chat_id": chatID,
+		"media":   media,
+	}
+	var messageID string
+	var err error
+	if len(files) > 0 {
+		messageID, err = t.sendMultipart(ctx, apiURL, requestBody, files)
+	} else {
+		messageID, err = t.sendRequest(ctx, apiURL, requestBody)
+	}
+	if err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// sendKeyboardMessage 相册不支持按钮，发送一条带标题的消息放置按钮
+func (t *TelegramNotifier) sendKeyboardMessage(ctx context.Context, chatID string, message *NotificationMessage) (string, error) {
+	title := message.DisplayTitle()
+	if title == "" {
+		title = "🔗"
+	}
+	requestBody := map[string]interface{}{
+		"chat_id":      chatID,
+		"text":         title,
+		"reply_markup": t.buildKeyboard(message),
+	}
+	return t.sendRequest(ctx, fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.config.BotToken), requestBody)
+}
+
+// telegramImageLimits sendPhoto 上传图片限制：最大 10MB
+var telegramImageLimits = ImageLimits{MaxBytes: 10 << 20, Formats: []string{ImageJPEG, ImagePNG, ImageWebP}}
+
+// ImageLimits 按链接发送的图片由 Telegram 下载，最大 5MB
+func (t *TelegramNotifier) ImageLimits() ImageLimits {
+	limits := telegramImageLimits
+	limits.MaxBytes = 5 << 20
+	return limits
+}
+
+// sendRequest 发送HTTP请求，返回消息ID
+func (t *TelegramNotifier) sendRequest(ctx context.Context, apiURL string, requestBody map[string]interface{}) (string, error) {
 	var result TelegramResponse
 
 	resp, err := t.client.R().
@@ -162,21 +396,75 @@ func (t *TelegramNotifier) sendRequest(ctx context.Context, apiURL string, reque
 		SetHeader("Content-Type", "application/json").
 		SetBody(requestBody).
 		SetResult(&result).
+		SetError(&result).
 		Post(apiURL)
 
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+		return "", fmt.Errorf("发送请求失败: %w", err)
 	}
-	body := resp.Body()
-	logger.Debug("telegram response: %s", string(body))
+	return t.parseResponse(resp, &result)
+}
+
+// sendMultipart 以 multipart/form-data 发送请求并上传图片文件，非字符串参数按 JSON 编码
+func (t *TelegramNotifier) sendMultipart(ctx context.Context, apiURL string, requestBody map[string]interface{}, files map[string]*ImageData) (string, error) {
+	var result TelegramResponse
 
-	if !resp.IsSuccess() {
-		return fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode())
+	formData := make(map[string]string, len(requestBody))
+	for key, value := range requestBody {
+		if s, ok := value.(string); ok {
+			formData[key] = s
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", permanentError("编码请求参数 %s 失败: %v", key, err)
+		}
+		formData[key] = string(data)
 	}
 
+	req := t.client.R().
+		SetContext(ctx).
+		SetFormData(formData).
+		SetResult(&result).
+		SetError(&result)
+	for field, file := range files {
+		req.SetMultipartField(field, file.Filename, file.ContentType, bytes.NewReader(file.Data))
+	}
+	resp, err := req.Post(apiURL)
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	return t.parseResponse(resp, &result)
+}
+
+// parseResponse 解析响应，返回消息ID
+func (t *TelegramNotifier) parseResponse(resp *resty.Response, result *TelegramResponse) (string, error) {
+	body := resp.Body()
+	logger.Debug("telegram response: %s", string(body))
+
 	if !result.OK {
-		return fmt.Errorf("发送消息失败: %s (错误代码: %d)", result.Description, result.ErrorCode)
+		if result.ErrorCode != 0 {
+			// 429 为限流，5xx 为服务端错误，其余（chat 不存在、被拉黑、格式错误等）重试无意义
+			retryable := result.ErrorCode == 429 || result.ErrorCode >= 500
+			err := providerError(result.ErrorCode, "发送消息失败: "+result.Description, retryable)
+			if result.Parameters != nil && result.Parameters.RetryAfter > 0 {
+				err.RetryAfter = time.Duration(result.Parameters.RetryAfter) * time.Second
+			}
+			return "", err
+		}
+		if !resp.IsSuccess() {
+			return "", httpStatusError(resp)
+		}
+		return "", fmt.Errorf("发送消息失败: %s", result.Description)
 	}
 
-	return nil
+	// 相册返回消息数组，取第一条消息的ID
+	var msg telegramMessage
+	if err := json.Unmarshal(result.Result, &msg); err != nil {
+		var msgs []telegramMessage
+		if json.Unmarshal(result.Result, &msgs) == nil && len(msgs) > 0 {
+			msg = msgs[0]
+		}
+	}
+	return strconv.FormatInt(msg.MessageID, 10), nil
 }
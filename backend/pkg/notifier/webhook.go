@@ -0,0 +1,484 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/expr"
+	"github.com/jianxcao/notify/backend/pkg/tmplfunc"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Webhook 通用 webhook
+const Webhook config.NotifiersType = "webhook"
+
+// 请求体类型
+const (
+	WebhookContentJSON = "json" // application/json
+	WebhookContentForm = "form" // application/x-www-form-urlencoded
+)
+
+// WebhookConfig 通用 webhook 配置
+//
+// 地址、请求头的值和请求体都是 text/template 模板，可以使用消息模板的全部函数，数据为：
+// 消息的各字段（{{.Title}}、{{.Content}}、{{.Level}} 等）、{{.Targets}}（消息的目标）
+// 和 {{.Payload}}（原始请求数据，例如 {{.Payload.event}}）。
+type WebhookConfig struct {
+	Enabled            bool              `yaml:"enabled" json:"enabled"`
+	URL                string            `yaml:"url" json:"url"`                                // 请求地址
+	Method             string            `yaml:"method" json:"method"`                          // 请求方法，默认 POST
+	Headers            map[string]string `yaml:"headers" json:"headers"`                        // 请求头
+	ContentType        string            `yaml:"content_type" json:"contentType"`               // json（默认）、form 或完整的 Content-Type
+	Body               string            `yaml:"body" json:"body"`                              // 请求体模板，为空时 json 发送消息的全部字段，form 发送主要字段
+	SuccessStatus      string            `yaml:"success_status" json:"successStatus"`           // 成功的状态码，例如 200,201 或 200-299，默认 2xx
+	SuccessPath        string            `yaml:"success_path" json:"successPath"`               // 判断成功的 JSONPath，例如 $.errcode
+	SuccessValue       string            `yaml:"success_value" json:"successValue"`             // SuccessPath 的期望值，为空时要求值为真
+	MessageIDPath      string            `yaml:"message_id_path" json:"messageIdPath"`          // 响应中消息 ID 的 JSONPath
+	Secret             string            `yaml:"secret" json:"secret"`                          // HMAC 签名密钥，为空时不签名
+	SignatureHeader    string            `yaml:"signature_header" json:"signatureHeader"`       // 签名请求头，默认 X-Signature-256
+	SignatureAlgorithm string            `yaml:"signature_algorithm" json:"signatureAlgorithm"` // 签名算法：sha256（默认）、sha1、sha512
+	Timeout            int               `yaml:"timeout" json:"timeout"`                        // 超时秒数，默认 30
+	Proxy              string            `yaml:"proxy" json:"proxy"`                            // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(Webhook, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg WebhookConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewWebhookNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "Webhook",
+		Description: "按模板向任意地址发送 HTTP 请求，用于没有专门通知服务的系统",
+		Fields: []FieldSchema{
+			{Name: "url", Label: "请求地址", Type: "string", Required: true, Placeholder: "https://example.com/hook", Hint: "支持模板，例如 https://example.com/hook?level={{.Level}}"},
+			{Name: "method", Label: "请求方法", Type: "select", Options: []string{"POST", "PUT", "PATCH", "GET", "DELETE"}, Default: "POST"},
+			{Name: "headers", Label: "请求头", Type: "textarea", Hint: "每行一个，格式: 名称: 值，值支持模板"},
+			{Name: "content_type", Label: "请求体类型", Type: "select", Options: []string{WebhookContentJSON, WebhookContentForm}, Default: WebhookContentJSON, Hint: "也可以填写完整的 Content-Type，例如 text/plain"},
+			{Name: "body", Label: "请求体模板", Type: "textarea", Hint: "text/template 模板，可以使用 {{.Title}}、{{.Content}}、{{.Payload}} 等；json 中的字符串用 {{json .Content}} 转义。为空时发送消息的全部字段"},
+			{Name: "success_status", Label: "成功状态码", Type: "string", Placeholder: "200-299", Hint: "多个用逗号分隔，支持范围，默认 2xx"},
+			{Name: "success_path", Label: "成功判断 JSONPath", Type: "string", Placeholder: "$.errcode", Hint: "可选，按响应 JSON 中的值判断是否成功"},
+			{Name: "success_value", Label: "成功值", Type: "string", Hint: "JSONPath 的期望值，例如 0；为空时要求值为真"},
+			{Name: "message_id_path", Label: "消息 ID JSONPath", Type: "string", Placeholder: "$.data.id", Hint: "可选，记录为服务商消息 ID"},
+			{Name: "secret", Label: "签名密钥", Type: "password", Secret: true, Hint: "可选，设置后对请求体做 HMAC 签名"},
+			{Name: "signature_header", Label: "签名请求头", Type: "string", Placeholder: "X-Signature-256", Hint: "值的格式为 sha256=<十六进制签名>"},
+			{Name: "signature_algorithm", Label: "签名算法", Type: "select", Options: []string{"sha256", "sha1", "sha512"}, Default: "sha256"},
+			{Name: "timeout", Label: "超时（秒）", Type: "number", Default: 30},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+	})
+}
+
+// WebhookNotifier 通用 webhook 通知服务
+type WebhookNotifier struct {
+	config WebhookConfig
+	client *resty.Client
+
+	url     *template.Template
+	headers map[string]*template.Template
+	body    *template.Template
+	status  [][2]int // 成功状态码范围，闭区间
+	err     error    // 模板或配置的解析错误，由 Validate 返回
+}
+
+// webhookData 模板数据：嵌入消息以便直接使用 {{.Title}} 等字段
+type webhookData struct {
+	*NotificationMessage
+	Targets []string
+	Payload map[string]any
+}
+
+// webhookFuncs 在消息模板函数之外增加 json 转义
+var webhookFuncs = func() template.FuncMap {
+	funcs := template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}
+	for name, fn := range tmplfunc.FuncMap {
+		funcs[name] = fn
+	}
+	return funcs
+}()
+
+// NewWebhookNotifier 创建通用 webhook 通知服务实例
+func NewWebhookNotifier(cfg WebhookConfig) *WebhookNotifier {
+	cfg.Method = strings.ToUpper(strings.TrimSpace(cfg.Method))
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	cfg.ContentType = strings.TrimSpace(cfg.ContentType)
+	if cfg.ContentType == "" {
+		cfg.ContentType = WebhookContentJSON
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = "X-Signature-256"
+	}
+	cfg.SignatureAlgorithm = strings.ToLower(strings.TrimSpace(cfg.SignatureAlgorithm))
+	if cfg.SignatureAlgorithm == "" {
+		cfg.SignatureAlgorithm = "sha256"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30
+	}
+
+	client := resty.New()
+	client.SetTimeout(time.Duration(cfg.Timeout) * time.Second)
+	if cfg.Proxy != "" {
+		client.SetProxy(cfg.Proxy)
+	}
+
+	w := &WebhookNotifier{config: cfg, client: supportDryRun(client)}
+	w.err = w.compile()
+	return w
+}
+
+// compile 解析模板和成功条件
+func (w *WebhookNotifier) compile() error {
+	var err error
+	if w.url, err = template.New("url").Funcs(webhookFuncs).Parse(w.config.URL); err != nil {
+		return fmt.Errorf("webhook 地址模板解析失败: %w", err)
+	}
+	w.headers = make(map[string]*template.Template, len(w.config.Headers))
+	for name, value := range w.config.Headers {
+		if w.headers[name], err = template.New(name).Funcs(webhookFuncs).Parse(value); err != nil {
+			return fmt.Errorf("webhook 请求头 %s 模板解析失败: %w", name, err)
+		}
+	}
+	if w.config.Body != "" {
+		if w.body, err = template.New("body").Funcs(webhookFuncs).Parse(w.config.Body); err != nil {
+			return fmt.Errorf("webhook 请求体模板解析失败: %w", err)
+		}
+	}
+	if w.status, err = parseStatusRanges(w.config.SuccessStatus); err != nil {
+		return err
+	}
+	for _, path := range []string{w.config.SuccessPath, w.config.MessageIDPath} {
+		if _, err := parseJSONPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name 返回服务名称
+func (w *WebhookNotifier) Name() string {
+	return string(Webhook)
+}
+
+// IsEnabled 检查服务是否启用
+func (w *WebhookNotifier) IsEnabled() bool {
+	return w.config.Enabled
+}
+
+// GroupTargets 目标作为模板数据传给请求，每条消息只发送一次请求
+func (w *WebhookNotifier) GroupTargets() bool {
+	return true
+}
+
+// Validate 验证配置
+func (w *WebhookNotifier) Validate() error {
+	if !w.config.Enabled {
+		return nil
+	}
+	if w.config.URL == "" {
+		return fmt.Errorf("webhook 地址不能为空")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	switch w.config.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return fmt.Errorf("webhook 请求方法 %s 无效", w.config.Method)
+	}
+	switch w.config.SignatureAlgorithm {
+	case "sha256", "sha1", "sha512":
+	default:
+		return fmt.Errorf("webhook 签名算法 %s 无效，可选 sha256、sha1、sha512", w.config.SignatureAlgorithm)
+	}
+	return nil
+}
+
+// Send 发送通知消息
+func (w *WebhookNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	start := time.Now()
+	if !w.config.Enabled {
+		return resultsFor(targets, start, "", permanentError("webhook 未启用"))
+	}
+	if err := w.Validate(); err != nil {
+		return resultsFor(targets, start, "", permanentError("%s", err.Error()))
+	}
+	id, err := w.send(ctx, message, targets)
+	return resultsFor(targets, start, id, err)
+}
+
+// send 渲染并发送请求，返回响应中的消息 ID
+func (w *WebhookNotifier) send(ctx context.Context, message *NotificationMessage, targets []string) (string, error) {
+	data := webhookData{NotificationMessage: message, Targets: targets, Payload: Payload(ctx)}
+
+	rawURL, err := execTemplate(w.url, data)
+	if err != nil {
+		return "", permanentError("渲染 webhook 地址失败: %v", err)
+	}
+	rawURL = strings.TrimSpace(rawURL)
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", permanentError("webhook 地址 %q 无效", rawURL)
+	}
+
+	body, contentType, err := w.buildBody(data)
+	if err != nil {
+		return "", err
+	}
+
+	req := w.client.R().SetContext(ctx)
+	if len(body) > 0 {
+		req.SetHeader("Content-Type", contentType).SetBody(body)
+	}
+	for name, tmpl := range w.headers {
+		value, err := execTemplate(tmpl, data)
+		if err != nil {
+			return "", permanentError("渲染 webhook 请求头 %s 失败: %v", name, err)
+		}
+		req.SetHeader(name, strings.TrimSpace(value))
+	}
+	if w.config.Secret != "" {
+		req.SetHeader(w.config.SignatureHeader, w.sign(body))
+	}
+
+	resp, err := req.Execute(w.config.Method, rawURL)
+	if err != nil {
+		return "", fmt.Errorf("发送 webhook 请求失败: %w", err)
+	}
+	// 预览模式的模拟响应不是目标服务的响应，不检查
+	if dryRunRecorder(ctx) != nil {
+		return "", nil
+	}
+	return w.checkResponse(resp)
+}
+
+// buildBody 渲染请求体，返回请求体和 Content-Type
+func (w *WebhookNotifier) buildBody(data webhookData) ([]byte, string, error) {
+	contentType := w.config.ContentType
+	switch contentType {
+	case WebhookContentJSON:
+		contentType = "application/json"
+	case WebhookContentForm:
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	if w.body == nil {
+		if w.config.Method == http.MethodGet || w.config.Method == http.MethodDelete {
+			return nil, "", nil
+		}
+		return w.defaultBody(data), contentType, nil
+	}
+
+	text, err := execTemplate(w.body, data)
+	if err != nil {
+		return nil, "", permanentError("渲染 webhook 请求体失败: %v", err)
+	}
+	body := []byte(strings.TrimSpace(text))
+	if w.config.ContentType == WebhookContentJSON && len(body) > 0 && !json.Valid(body) {
+		return nil, "", permanentError("webhook 请求体不是有效的 JSON，字符串请使用 {{json .Content}} 转义: %s", truncateString(string(body)))
+	}
+	return body, contentType, nil
+}
+
+// defaultBody 未配置请求体模板时的请求体：json 为消息的全部字段和目标，form 为主要字段
+func (w *WebhookNotifier) defaultBody(data webhookData) []byte {
+	if w.config.ContentType == WebhookContentForm {
+		values := url.Values{}
+		for key, value := range map[string]string{
+			"title":     data.Title,
+			"content":   data.Content,
+			"url":       data.URL,
+			"image":     data.Image,
+			"level":     string(data.Level),
+			"timestamp": data.Timestamp,
+			"targets":   strings.Join(data.Targets, ","),
+		} {
+			if value != "" {
+				values.Set(key, value)
+			}
+		}
+		return []byte(values.Encode())
+	}
+	body, _ := json.Marshal(struct {
+		*NotificationMessage
+		Targets []string `json:"targets,omitempty"`
+	}{data.NotificationMessage, data.Targets})
+	return body
+}
+
+// sign 计算请求体的 HMAC 签名，格式为 <算法>=<十六进制签名>
+func (w *WebhookNotifier) sign(body []byte) string {
+	var fn func() hash.Hash
+	switch w.config.SignatureAlgorithm {
+	case "sha1":
+		fn = sha1.New
+	case "sha512":
+		fn = sha512.New
+	default:
+		fn = sha256.New
+	}
+	mac := hmac.New(fn, []byte(w.config.Secret))
+	mac.Write(body)
+	return w.config.SignatureAlgorithm + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkResponse 按状态码和 JSONPath 判断是否成功
+func (w *WebhookNotifier) checkResponse(resp *resty.Response) (string, error) {
+	if !w.successStatus(resp.StatusCode()) {
+		pe := httpStatusError(resp)
+		if text := strings.TrimSpace(resp.String()); text != "" {
+			pe.Message += "，响应: " + truncateString(text)
+		}
+		return "", pe
+	}
+	if w.config.SuccessPath == "" && w.config.MessageIDPath == "" {
+		return "", nil
+	}
+
+	var result any
+	decoder := json.NewDecoder(bytes.NewReader(resp.Body()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		if w.config.SuccessPath == "" {
+			return "", nil
+		}
+		return "", permanentError("webhook 响应不是有效的 JSON: %s", truncateString(resp.String()))
+	}
+
+	if w.config.SuccessPath != "" {
+		path, _ := parseJSONPath(w.config.SuccessPath)
+		value := expr.Lookup(result, path)
+		if !matchSuccessValue(value, w.config.SuccessValue) {
+			return "", providerError("WEBHOOK_REJECTED", fmt.Sprintf("响应中 %s 的值为 %v，响应: %s", w.config.SuccessPath, value, truncateString(resp.String())), false)
+		}
+	}
+	if w.config.MessageIDPath != "" {
+		path, _ := parseJSONPath(w.config.MessageIDPath)
+		if value := expr.Lookup(result, path); value != nil {
+			return fmt.Sprint(value), nil
+		}
+	}
+	return "", nil
+}
+
+// successStatus 判断状态码是否表示成功
+func (w *WebhookNotifier) successStatus(status int) bool {
+	if len(w.status) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, r := range w.status {
+		if status >= r[0] && status <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSuccessValue 比较 JSONPath 的值：expected 为空时要求值为真（不为 null、false、0 或空字符串）
+func matchSuccessValue(value any, expected string) bool {
+	if expected == "" {
+		switch v := value.(type) {
+		case nil:
+			return false
+		case bool:
+			return v
+		case string:
+			return v != ""
+		case json.Number:
+			f, err := v.Float64()
+			return err != nil || f != 0
+		}
+		return true
+	}
+	if value == nil {
+		return expected == "null"
+	}
+	if n, ok := value.(json.Number); ok {
+		if a, err := n.Float64(); err == nil {
+			if b, err := strconv.ParseFloat(expected, 64); err == nil {
+				return a == b
+			}
+		}
+	}
+	return fmt.Sprint(value) == expected
+}
+
+// parseStatusRanges 解析状态码列表，例如 "200,201" 或 "200-299"
+func parseStatusRanges(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, item := range splitList(s) {
+		from, to, isRange := strings.Cut(item, "-")
+		low, err := strconv.Atoi(strings.TrimSpace(from))
+		high := low
+		if err == nil && isRange {
+			high, err = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err != nil || low < 100 || high > 599 || low > high {
+			return nil, fmt.Errorf("webhook 成功状态码 %q 格式错误，例如 200,201 或 200-299", item)
+		}
+		ranges = append(ranges, [2]int{low, high})
+	}
+	return ranges, nil
+}
+
+// parseJSONPath 将 JSONPath（$.data.items[0].id 或 $['data']['id']）转换为路径，开头的 $ 可以省略
+func parseJSONPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	var segments []string
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q 格式错误：缺少 ]", path)
+			}
+			segments = append(segments, strings.Trim(path[1:end], `'"`))
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			segments = append(segments, path[:end])
+			path = path[end:]
+		}
+	}
+	return segments, nil
+}
+
+// execTemplate 执行模板，去掉未定义字段输出的 <no value>
+func execTemplate(tmpl *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+}
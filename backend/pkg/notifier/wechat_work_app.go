@@ -1,26 +1,65 @@
 package notifier
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/logger"
 
 	"github.com/go-resty/resty/v2"
 )
 
+// WechatWorkAPPBot 企业微信应用
+const WechatWorkAPPBot config.NotifiersType = "wechatWorkAPPBot"
+
+// WechatWorkConfig 企业微信应用配置
+type WechatWorkConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	CorpID  string `yaml:"corp_id" json:"corpId"`
+	AgentID string `yaml:"agent_id" json:"agentId"`
+	Secret  string `yaml:"secret" json:"secret"`
+	Targets string `yaml:"targets" json:"targets"`
+	Proxy   string `yaml:"proxy" json:"proxy"` // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(WechatWorkAPPBot, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg WechatWorkConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewWechatWorkNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "企业微信应用",
+		Description: "在企业微信管理后台创建自建应用，企业ID 在「我的企业」中查看，应用ID 和应用密钥为应用详情页的 AgentId 和 Secret；消息中的 targets 为成员 ID",
+		Fields: []FieldSchema{
+			{Name: "corp_id", Label: "企业ID", Type: "string", Required: true},
+			{Name: "agent_id", Label: "应用ID", Type: "string", Required: true},
+			{Name: "secret", Label: "应用密钥", Type: "password", Required: true, Secret: true},
+			{Name: "targets", Label: "目标", Type: "string", Hint: "用户id，多个用逗号分隔"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// 每个应用对同一成员每分钟最多 30 条
+		RateLimit: &config.RateLimit{PerTarget: "30/m"},
+	})
+}
+
 // WechatWorkNotifier 企业微信通知服务
 type WechatWorkNotifier struct {
-	config      config.WechatWorkConfig
+	config      WechatWorkConfig
 	accessToken string
 	client      *resty.Client
 	baseURL     string
 }
 
 // NewWechatWorkNotifier 创建企业微信通知服务实例
-func NewWechatWorkNotifier(cfg config.WechatWorkConfig) *WechatWorkNotifier {
+func NewWechatWorkNotifier(cfg WechatWorkConfig) *WechatWorkNotifier {
 	client := resty.New()
 	client.SetTimeout(30 * time.Second)
 	client.SetRetryCount(3)
@@ -33,14 +72,14 @@ func NewWechatWorkNotifier(cfg config.WechatWorkConfig) *WechatWorkNotifier {
 
 	return &WechatWorkNotifier{
 		config:  cfg,
-		client:  client,
+		client:  supportDryRun(client),
 		baseURL: baseURL,
 	}
 }
 
 // Name 返回服务名称
 func (w *WechatWorkNotifier) Name() string {
-	return string(config.WechatWorkAPPBot)
+	return string(WechatWorkAPPBot)
 }
 
 // IsEnabled 检查服务是否启用
@@ -77,8 +116,28 @@ type TokenResponse struct {
 
 // MessageResponse 消息发送响应结构
 type MessageResponse struct {
-	ErrCode int    `json:"errcode"`
-	ErrMsg  string `json:"errmsg"`
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+	InvalidUser string `json:"invaliduser"` // 无效或无权限的用户，多个用 | 分隔
+	MsgID       string `json:"msgid"`
+}
+
+// wechatWorkRetryable 企业微信可以重试的错误码：系统繁忙、调用频率超限、access_token 失效
+func wechatWorkRetryable(errCode int) bool {
+	switch errCode {
+	case -1, 45009, 45033, 40014, 42001:
+		return true
+	}
+	return false
+}
+
+// wechatWorkError 创建企业微信错误，调用频率超限时按分钟窗口等待后重试
+func wechatWorkError(errCode int, message string) *ProviderError {
+	err := providerError(errCode, message, wechatWorkRetryable(errCode))
+	if errCode == 45009 {
+		err.RetryAfter = time.Minute
+	}
+	return err
 }
 
 // getAccessToken 获取访问令牌
@@ -93,27 +152,35 @@ func (w *WechatWorkNotifier) getAccessToken(ctx context.Context) error {
 		}).
 		SetResult(&result).
 		Get(fmt.Sprintf("%s/cgi-bin/gettoken", w.baseURL))
-	fmt.Println("body", resp.RawBody())
 	if err != nil {
 		return fmt.Errorf("请求失败: %w", err)
 	}
 
 	if !resp.IsSuccess() {
-		return fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode())
+		return httpStatusError(resp)
 	}
 
 	if result.ErrCode != 0 {
-		return fmt.Errorf("获取访问令牌失败: %s", result.ErrMsg)
+		return wechatWorkError(result.ErrCode, "获取访问令牌失败: "+result.ErrMsg)
 	}
 
 	w.accessToken = result.AccessToken
 	return nil
 }
 
+// CheckCredentials 获取访问令牌，检查企业ID和应用密钥
+func (w *WechatWorkNotifier) CheckCredentials(ctx context.Context) (string, error) {
+	if err := w.getAccessToken(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("获取访问令牌成功（应用ID: %s）", w.config.AgentID), nil
+}
+
 // Send 发送通知消息
-func (w *WechatWorkNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) error {
+func (w *WechatWorkNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	start := time.Now()
 	if !w.config.Enabled {
-		return fmt.Errorf("企业微信通知服务未启用")
+		return resultsFor(targets, start, "", permanentError("企业微信通知服务未启用"))
 	}
 	if len(targets) == 0 && w.config.Targets != "" {
 		targets = strings.Split(w.config.Targets, ",")
@@ -121,71 +188,162 @@ func (w *WechatWorkNotifier) Send(ctx context.Context, message *NotificationMess
 
 	// 获取访问令牌
 	if err := w.getAccessToken(ctx); err != nil {
-		return fmt.Errorf("获取访问令牌失败: %w", err)
+		return resultsFor(targets, start, "", fmt.Errorf("获取访问令牌失败: %w", err))
+	}
+
+	// 内网图片和 data: URI 无法作为图文消息的图片地址，上传为临时素材后以图片消息单独发送
+	message, uploads := SeparateUploadImages(ctx, message)
+	var requests []map[string]interface{}
+	for _, image := range uploads {
+		mediaID, err := w.uploadImage(ctx, image)
+		if err != nil {
+			logger.Error("上传企业微信图片失败", "image", AbbreviateImage(image), "error", err)
+			continue
+		}
+		requests = append(requests, w.setReceivers(map[string]interface{}{
+			"msgtype": "image",
+			"image":   map[string]string{"media_id": mediaID},
+		}, targets))
+	}
+
+	// 文本和 markdown 消息超长时拆分为多条，模板卡片和图文消息由构建函数截断
+	parts := []*NotificationMessage{message}
+	if !useWechatWorkTemplateCard(message) && len(message.AllImages()) == 0 {
+		parts = SplitMessage(message, w.Limits(), func(m *NotificationMessage) string {
+			if m.Format == FormatMarkdown {
+				return buildWechatWorkMarkdown(m)
+			}
+			return buildWechatWorkText(m)
+		})
 	}
-	// TODO: 消息超过2048字符的处理
-	// 如果有图片，发送图文消息，否则发送文本消息
-	if message.Image != "" {
-		return w.sendNewsMessage(ctx, message, targets)
+	for _, part := range parts {
+		requests = append(requests, w.buildRequestBody(part, targets))
 	}
 
-	return w.sendTextMessage(ctx, message, targets)
+	// 一次请求发送给所有用户，企业微信通过 invaliduser 返回发送失败的用户
+	invalid := map[string]bool{}
+	messageID, sent, err := sendParts(ctx, targets, len(requests), func(i int) (string, error) {
+		result, err := w.sendMessage(ctx, requests[i])
+		if err != nil {
+			return "", err
+		}
+		for _, user := range strings.Split(result.InvalidUser, "|") {
+			if user != "" {
+				invalid[user] = true
+			}
+		}
+		return result.MsgID, nil
+	})
+	if err != nil {
+		return partResults(targets, start, messageID, sent, len(requests), err)
+	}
+	results := resultsFor(targets, start, messageID, nil)
+	for i := range results {
+		if invalid[results[i].Target] {
+			results[i] = NewResult(results[i].Target, start, "", providerError("invaliduser", "用户不存在或不在应用可见范围内", false))
+		}
+	}
+	return results
 }
 
-// sendTextMessage 发送文本消息
-func (w *WechatWorkNotifier) sendTextMessage(ctx context.Context, message *NotificationMessage, targets []string) error {
-	// 构建消息内容
-	content := fmt.Sprintf("%s\n%s", message.Title, message.Content)
+// Limits 消息长度限制：文本和 markdown 消息最长 2048 字节
+func (w *WechatWorkNotifier) Limits() Limits {
+	return Limits{Text: 2048, Bytes: true}
+}
 
-	// 构建发送消息的请求体
-	requestBody := map[string]interface{}{
-		"touser":  "@all", // 默认发送给所有人，可以根据targets参数调整
-		"msgtype": "text",
-		"agentid": w.config.AgentID,
-		"text": map[string]string{
-			"content": content,
-		},
+// buildRequestBody 根据消息内容选择消息类型：
+// 带字段或按钮时发送模板卡片，有图片时发送图文消息，markdown 格式发送 markdown 消息，否则发送文本消息
+func (w *WechatWorkNotifier) buildRequestBody(message *NotificationMessage, targets []string) map[string]interface{} {
+	var requestBody map[string]interface{}
+	switch {
+	case useWechatWorkTemplateCard(message):
+		requestBody = map[string]interface{}{
+			"msgtype":       "template_card",
+			"template_card": buildWechatWorkTemplateCard(message),
+		}
+	case message.Image != "" || len(message.Images) > 0:
+		requestBody = map[string]interface{}{
+			"msgtype": "news",
+			"news": map[string]interface{}{
+				"articles": buildWechatWorkArticles(message, ""),
+			},
+		}
+	case message.Format == FormatMarkdown:
+		requestBody = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": buildWechatWorkMarkdown(message),
+			},
+		}
+	default:
+		requestBody = map[string]interface{}{
+			"msgtype": "text",
+			"text": map[string]string{
+				"content": buildWechatWorkText(message),
+			},
+		}
 	}
 
+	return w.setReceivers(requestBody, targets)
+}
+
+// setReceivers 设置接收人和应用ID
+func (w *WechatWorkNotifier) setReceivers(requestBody map[string]interface{}, targets []string) map[string]interface{} {
+	requestBody["touser"] = "@all" // 默认发送给所有人
+	requestBody["agentid"] = w.config.AgentID
 	// 如果指定了目标用户
 	if len(targets) > 0 {
 		requestBody["touser"] = joinStrings(targets, "|")
 	}
+	return requestBody
+}
 
-	return w.sendMessage(ctx, requestBody)
+// wechatWorkImageLimits 企业微信图片素材限制：最大 10MB，支持 JPG、PNG
+var wechatWorkImageLimits = ImageLimits{MaxBytes: 10 << 20, Formats: []string{ImageJPEG, ImagePNG}}
+
+// ImageLimits 图文消息的图片链接与图片素材的限制相同
+func (w *WechatWorkNotifier) ImageLimits() ImageLimits {
+	return wechatWorkImageLimits
 }
 
-// sendNewsMessage 发送图文消息
-func (w *WechatWorkNotifier) sendNewsMessage(ctx context.Context, message *NotificationMessage, targets []string) error {
-	// 构建图文消息
-	articles := []map[string]interface{}{
-		{
-			"title":       message.Title,
-			"description": message.Content,
-			"url":         message.URL, // 使用通知消息中的URL
-			"picurl":      message.Image,
-		},
-	}
+// MediaResponse 上传临时素材响应结构
+type MediaResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+	MediaID string `json:"media_id"`
+}
 
-	requestBody := map[string]interface{}{
-		"touser":  "@all",
-		"msgtype": "news",
-		"agentid": w.config.AgentID,
-		"news": map[string]interface{}{
-			"articles": articles,
-		},
+// uploadImage 获取图片并上传为临时素材，返回 media_id
+func (w *WechatWorkNotifier) uploadImage(ctx context.Context, src string) (string, error) {
+	image, err := FetchImage(ctx, src, wechatWorkImageLimits)
+	if err != nil {
+		return "", err
 	}
 
-	// 如果指定了目标用户
-	if len(targets) > 0 {
-		requestBody["touser"] = joinStrings(targets, "|")
+	var result MediaResponse
+	resp, err := w.client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"access_token": w.accessToken,
+			"type":         "image",
+		}).
+		SetMultipartField("media", image.Filename, image.ContentType, bytes.NewReader(image.Data)).
+		SetResult(&result).
+		Post(fmt.Sprintf("%s/cgi-bin/media/upload", w.baseURL))
+	if err != nil {
+		return "", fmt.Errorf("上传请求失败: %w", err)
 	}
-
-	return w.sendMessage(ctx, requestBody)
+	if !resp.IsSuccess() {
+		return "", httpStatusError(resp)
+	}
+	if result.ErrCode != 0 {
+		return "", wechatWorkError(result.ErrCode, "上传临时素材失败: "+result.ErrMsg)
+	}
+	return result.MediaID, nil
 }
 
 // sendMessage 发送消息到企业微信
-func (w *WechatWorkNotifier) sendMessage(ctx context.Context, requestBody map[string]interface{}) error {
+func (w *WechatWorkNotifier) sendMessage(ctx context.Context, requestBody map[string]interface{}) (*MessageResponse, error) {
 	var result MessageResponse
 
 	resp, err := w.client.R().
@@ -197,18 +355,18 @@ func (w *WechatWorkNotifier) sendMessage(ctx context.Context, requestBody map[st
 		Post(fmt.Sprintf("%s/cgi-bin/message/send", w.baseURL))
 
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 
 	if !resp.IsSuccess() {
-		return fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode())
+		return nil, httpStatusError(resp)
 	}
 
 	if result.ErrCode != 0 {
-		return fmt.Errorf("发送消息失败: %s", result.ErrMsg)
+		return nil, wechatWorkError(result.ErrCode, "发送消息失败: "+result.ErrMsg)
 	}
 
-	return nil
+	return &result, nil
 }
 
 // joinStrings 连接字符串切片
@@ -0,0 +1,137 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 企业微信应用消息和群机器人消息共用的消息结构
+
+// useWechatWorkTemplateCard 带键值字段、自定义按钮或级别时使用模板卡片；模板卡片必须有点击跳转链接
+func useWechatWorkTemplateCard(message *NotificationMessage) bool {
+	if len(message.AllActions()) == 0 {
+		return false
+	}
+	return len(message.Fields) > 0 || len(message.Actions) > 0 || message.Level != ""
+}
+
+// buildWechatWorkTemplateCard 构建模板卡片：有图片时为图文展示型（news_notice），否则为文本通知型（text_notice）
+func buildWechatWorkTemplateCard(message *NotificationMessage) map[string]interface{} {
+	actions := message.AllActions()
+	card := map[string]interface{}{
+		"card_type": "text_notice",
+		"main_title": map[string]interface{}{
+			"title": message.DisplayTitle(),
+			"desc":  message.Timestamp,
+		},
+		"card_action": map[string]interface{}{
+			"type": 1,
+			"url":  actions[0].URL,
+		},
+	}
+
+	if images := message.AllImages(); len(images) > 0 {
+		card["card_type"] = "news_notice"
+		card["card_image"] = map[string]interface{}{"url": images[0]}
+		if content := message.PlainContent(); content != "" {
+			card["quote_area"] = map[string]interface{}{"type": 0, "quote_text": content}
+		}
+	} else {
+		card["sub_title_text"] = message.PlainContent()
+	}
+
+	// 企业微信限制：二级标题+文本列表最多 6 项，跳转列表最多 3 项
+	if len(message.Fields) > 0 {
+		fields := message.Fields
+		if len(fields) > 6 {
+			fields = fields[:6]
+		}
+		list := make([]map[string]interface{}, len(fields))
+		for i, field := range fields {
+			list[i] = map[string]interface{}{"keyname": field.Key, "value": field.Value}
+		}
+		card["horizontal_content_list"] = list
+	}
+	if len(actions) > 3 {
+		actions = actions[:3]
+	}
+	jumps := make([]map[string]interface{}, len(actions))
+	for i, action := range actions {
+		jumps[i] = map[string]interface{}{"type": 1, "title": action.Label, "url": action.URL}
+	}
+	card["jump_list"] = jumps
+
+	return card
+}
+
+// buildWechatWorkArticles 构建图文消息，每张图片一篇（最多 8 篇）；没有链接时使用 fallbackURL
+func buildWechatWorkArticles(message *NotificationMessage, fallbackURL string) []map[string]interface{} {
+	url := fallbackURL
+	if actions := message.AllActions(); len(actions) > 0 {
+		url = actions[0].URL
+	}
+
+	description := message.PlainContent()
+	if fields := message.FieldsText(""); fields != "" {
+		description += "\n" + fields
+	}
+
+	images := message.AllImages()
+	if len(images) > 8 {
+		images = images[:8]
+	}
+	articles := []map[string]interface{}{}
+	for i, image := range images {
+		article := map[string]interface{}{
+			"title":  message.DisplayTitle(),
+			"url":    url,
+			"picurl": image,
+		}
+		if i == 0 {
+			article["description"] = description
+		} else {
+			article["title"] = fmt.Sprintf("%s (%d)", message.DisplayTitle(), i+1)
+		}
+		if article["url"] == "" {
+			article["url"] = image
+		}
+		articles = append(articles, article)
+	}
+	return articles
+}
+
+// buildWechatWorkText 构建文本消息内容，按钮以超链接形式附在末尾
+func buildWechatWorkText(message *NotificationMessage) string {
+	parts := []string{message.DisplayTitle(), message.PlainContent(), message.FieldsText("")}
+	for _, action := range message.AllActions() {
+		parts = append(parts, fmt.Sprintf(`<a href="%s">%s</a>`, action.URL, action.Label))
+	}
+	return joinNonEmpty(parts, "\n")
+}
+
+// buildWechatWorkMarkdown 构建 markdown 消息内容
+func buildWechatWorkMarkdown(message *NotificationMessage) string {
+	var parts []string
+	if title := message.DisplayTitle(); title != "" {
+		parts = append(parts, "**"+title+"**")
+	}
+	parts = append(parts, message.RenderContent(DialectWechatWork), message.FieldsText("**"))
+	for _, action := range message.AllActions() {
+		parts = append(parts, fmt.Sprintf("[%s](%s)", action.Label, action.URL))
+	}
+	if message.Timestamp != "" {
+		parts = append(parts, "⏰ "+message.Timestamp)
+	}
+	return joinNonEmpty(parts, "\n\n")
+}
+
+// joinNonEmpty 跳过空字符串连接
+func joinNonEmpty(parts []string, sep string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
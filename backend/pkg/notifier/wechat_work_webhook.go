@@ -2,6 +2,9 @@ package notifier
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -12,15 +15,46 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
+// WechatWorkWebhookBot 企业微信群机器人
+const WechatWorkWebhookBot config.NotifiersType = "wechatWorkWebhookBot"
+
+// WechatWorkWebhookConfig 企业微信群机器人配置
+type WechatWorkWebhookConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Key     string `yaml:"key" json:"key"`     // 群机器人的 key
+	Proxy   string `yaml:"proxy" json:"proxy"` // 代理服务器地址，格式: http://proxy.example.com:8080
+}
+
+func init() {
+	Register(WechatWorkWebhookBot, func(instance config.NotifierInstance) (Notifier, error) {
+		var cfg WechatWorkWebhookConfig
+		if err := DecodeConfig(instance.Config, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Enabled = instance.Enabled
+		n := NewWechatWorkWebhookNotifier(cfg)
+		return n, n.Validate()
+	}, Schema{
+		DisplayName: "企业微信群机器人",
+		Description: "在群聊的「群机器人」中添加自定义机器人，Key 为 Webhook 地址中 key 参数的值",
+		Fields: []FieldSchema{
+			{Name: "key", Label: "群机器人 Key", Type: "password", Required: true, Secret: true, Hint: "例如: xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"},
+			{Name: "proxy", Label: "代理服务器", Type: "string", Hint: "可选，格式: http://proxy.example.com:8080"},
+		},
+		// 每个机器人每分钟最多 20 条
+		RateLimit: &config.RateLimit{Rate: "20/m"},
+	})
+}
+
 // WechatWorkWebhookNotifier 企业微信群机器人通知服务
 type WechatWorkWebhookNotifier struct {
-	config  config.WechatWorkWebhookConfig
+	config  WechatWorkWebhookConfig
 	client  *resty.Client
 	baseURL string
 }
 
 // NewWechatWorkWebhookNotifier 创建企业微信群机器人通知服务实例
-func NewWechatWorkWebhookNotifier(cfg config.WechatWorkWebhookConfig) *WechatWorkWebhookNotifier {
+func NewWechatWorkWebhookNotifier(cfg WechatWorkWebhookConfig) *WechatWorkWebhookNotifier {
 	client := resty.New()
 	client.SetTimeout(30 * time.Second)
 	client.SetRetryCount(3)
@@ -33,14 +67,14 @@ func NewWechatWorkWebhookNotifier(cfg config.WechatWorkWebhookConfig) *WechatWor
 
 	return &WechatWorkWebhookNotifier{
 		config:  cfg,
-		client:  client,
+		client:  supportDryRun(client),
 		baseURL: baseURL,
 	}
 }
 
 // Name 返回服务名称
 func (w *WechatWorkWebhookNotifier) Name() string {
-	return string(config.WechatWorkWebhookBot)
+	return string(WechatWorkWebhookBot)
 }
 
 // IsEnabled 检查服务是否启用
@@ -61,20 +95,48 @@ func (w *WechatWorkWebhookNotifier) Validate() error {
 	return nil
 }
 
+// GroupTargets 群机器人只能发送到所在的群，不区分目标，按目标拆分会重复发送
+func (w *WechatWorkWebhookNotifier) GroupTargets() bool {
+	return true
+}
+
 // Send 发送通知消息
-func (w *WechatWorkWebhookNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) error {
+func (w *WechatWorkWebhookNotifier) Send(ctx context.Context, message *NotificationMessage, targets []string) Results {
+	start := time.Now()
 	if err := w.Validate(); err != nil {
-		return err
+		return Results{NewResult("", start, "", permanentError("%s", err.Error()))}
+	}
+
+	// 内网图片和 data: URI 无法作为图文消息的图片地址，以 base64 图片消息单独发送
+	message, uploads := SeparateUploadImages(ctx, message)
+	for _, image := range uploads {
+		if err := w.sendImageMessage(ctx, image); err != nil {
+			return Results{NewResult("", start, "", fmt.Errorf("发送图片消息失败: %w", err))}
+		}
 	}
 
 	// 发送消息
-	if message.Image != "" {
-		return w.SendNewsdownMessage(ctx, message)
+	switch {
+	case useWechatWorkTemplateCard(message):
+		return Results{NewResult("", start, "", w.sendTemplateCardMessage(ctx, message))}
+	case message.Image != "" || len(message.Images) > 0:
+		return Results{NewResult("", start, "", w.SendNewsdownMessage(ctx, message))}
+	}
+	send, parts := w.sendMarkdownMessage, SplitMessage(message, w.Limits(), w.buildMarkdown)
+	if message.Format == FormatPlain || message.Format == FormatHTML {
+		send, parts = w.SendTextMessage, SplitMessage(message, Limits{Text: 2048, Bytes: true}, w.buildText)
 	}
-	return w.sendMarkdownMessage(ctx, message)
+	_, sent, err := sendParts(ctx, nil, len(parts), func(i int) (string, error) {
+		return "", send(ctx, parts[i])
+	})
+	return partResults(nil, start, "", sent, len(parts), err)
+}
+
+// Limits 消息长度限制：markdown_v2 消息最长 4096 字节，文本消息最长 2048 字节
+func (w *WechatWorkWebhookNotifier) Limits() Limits {
+	return Limits{Text: 4096, Bytes: true}
 }
 
-// buildMessage 构建消息内容
 func (w *WechatWorkWebhookNotifier) SendTextMessage(ctx context.Context, message *NotificationMessage) error {
 	// 构建完整的 webhook URL
 	webhookURL := fmt.Sprintf("%s/cgi-bin/webhook/send?key=%s", w.baseURL, w.config.Key)
@@ -83,7 +145,7 @@ func (w *WechatWorkWebhookNotifier) SendTextMessage(ctx context.Context, message
 	payload := map[string]interface{}{
 		"msgtype": "text",
 		"text": map[string]interface{}{
-			"content": message.Title + "\n" + message.Content,
+			"content": w.buildText(message),
 		},
 	}
 	resp, err := w.client.R().
@@ -102,22 +164,11 @@ func (w *WechatWorkWebhookNotifier) SendTextMessage(ctx context.Context, message
 func (w *WechatWorkWebhookNotifier) SendNewsdownMessage(ctx context.Context, message *NotificationMessage) error {
 	// 构建完整的 webhook URL
 	webhookURL := fmt.Sprintf("%s/cgi-bin/webhook/send?key=%s", w.baseURL, w.config.Key)
-	url := message.URL
-	if url == "" {
-		url = message.Image
-	}
-	// 企业微信群机器人消息格式
+	// 企业微信群机器人消息格式，没有链接时点击打开图片
 	payload := map[string]interface{}{
 		"msgtype": "news",
 		"news": map[string]interface{}{
-			"articles": []map[string]interface{}{
-				{
-					"title":       message.Title,
-					"description": message.Content,
-					"url":         url,
-					"picurl":      message.Image,
-				},
-			},
+			"articles": buildWechatWorkArticles(message, ""),
 		},
 	}
 
@@ -135,37 +186,76 @@ func (w *WechatWorkWebhookNotifier) SendNewsdownMessage(ctx context.Context, mes
 }
 
 // sendWebhookMessage 发送 webhook 消息
+func (w *WechatWorkWebhookNotifier) sendWebhookMessage(ctx context.Context, payload map[string]interface{}) error {
+	webhookURL := fmt.Sprintf("%s/cgi-bin/webhook/send?key=%s", w.baseURL, w.config.Key)
+	resp, err := w.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(payload).
+		Post(webhookURL)
+	if err != nil {
+		return fmt.Errorf("发送企业微信群机器人消息失败: %w", err)
+	}
+	return w.checkResp(resp)
+}
+
+// sendMarkdownMessage 发送 markdown_v2 消息
 func (w *WechatWorkWebhookNotifier) sendMarkdownMessage(ctx context.Context, message *NotificationMessage) error {
+	// 构建完整的 webhook URL
+	webhookURL := fmt.Sprintf("%s/cgi-bin/webhook/send?key=%s", w.baseURL, w.config.Key)
 
-	var content strings.Builder
-	if message.Image != "" {
-		content.WriteString(fmt.Sprintf("![%s](%s)\n\n", message.Title, message.Image))
-	}
-	// 添加标题
-	if message.Title != "" {
-		content.WriteString(fmt.Sprintf("**%s**\n\n", message.Title))
+	// 企业微信群机器人消息格式
+	payload := map[string]interface{}{
+		"msgtype": "markdown_v2",
+		"markdown_v2": map[string]interface{}{
+			"content": w.buildMarkdown(message),
+		},
 	}
 
-	// 添加内容
-	if message.Content != "" {
-		content.WriteString(message.Content)
-		content.WriteString("\n\n")
+	resp, err := w.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(payload).
+		Post(webhookURL)
+
+	if err != nil {
+		return fmt.Errorf("发送企业微信群机器人消息失败: %w", err)
 	}
 
-	// 添加时间戳
-	if message.Timestamp != "" {
-		content.WriteString(fmt.Sprintf("⏰ %s", message.Timestamp))
+	return w.checkResp(resp)
+}
+
+// wechatWorkWebhookImageLimits 群机器人图片消息限制：最大 2MB，支持 JPG、PNG
+var wechatWorkWebhookImageLimits = ImageLimits{MaxBytes: 2 << 20, Formats: []string{ImageJPEG, ImagePNG}}
+
+// ImageLimits 图文消息的图片链接与图片消息的限制相同
+func (w *WechatWorkWebhookNotifier) ImageLimits() ImageLimits {
+	return wechatWorkWebhookImageLimits
+}
+
+// sendImageMessage 获取图片并以 base64 图片消息发送
+func (w *WechatWorkWebhookNotifier) sendImageMessage(ctx context.Context, src string) error {
+	image, err := FetchImage(ctx, src, wechatWorkWebhookImageLimits)
+	if err != nil {
+		return permanentError("获取图片失败: %v", err)
 	}
+	sum := md5.Sum(image.Data)
+	return w.sendWebhookMessage(ctx, map[string]interface{}{
+		"msgtype": "image",
+		"image": map[string]interface{}{
+			"base64": base64.StdEncoding.EncodeToString(image.Data),
+			"md5":    hex.EncodeToString(sum[:]),
+		},
+	})
+}
 
-	// 构建完整的 webhook URL
+// sendTemplateCardMessage 发送模板卡片消息
+func (w *WechatWorkWebhookNotifier) sendTemplateCardMessage(ctx context.Context, message *NotificationMessage) error {
 	webhookURL := fmt.Sprintf("%s/cgi-bin/webhook/send?key=%s", w.baseURL, w.config.Key)
 
-	// 企业微信群机器人消息格式
 	payload := map[string]interface{}{
-		"msgtype": "markdown_v2",
-		"markdown_v2": map[string]interface{}{
-			"content": content.String(),
-		},
+		"msgtype":       "template_card",
+		"template_card": buildWechatWorkTemplateCard(message),
 	}
 
 	resp, err := w.client.R().
@@ -181,9 +271,54 @@ func (w *WechatWorkWebhookNotifier) sendMarkdownMessage(ctx context.Context, mes
 	return w.checkResp(resp)
 }
 
+// buildMarkdown 构建 markdown_v2 消息内容：标题、正文、键值字段、按钮链接和时间戳
+func (w *WechatWorkWebhookNotifier) buildMarkdown(message *NotificationMessage) string {
+	var content strings.Builder
+	if message.Image != "" {
+		content.WriteString(fmt.Sprintf("![%s](%s)\n\n", message.Title, message.Image))
+	}
+	// 添加标题
+	if title := message.DisplayTitle(); title != "" {
+		content.WriteString(fmt.Sprintf("**%s**\n\n", title))
+	}
+
+	// 添加内容
+	if message.Content != "" {
+		content.WriteString(message.RenderContent(DialectMarkdown))
+		content.WriteString("\n\n")
+	}
+
+	// 添加键值字段
+	if fields := message.FieldsText("**"); fields != "" {
+		content.WriteString(fields)
+		content.WriteString("\n\n")
+	}
+
+	// 添加按钮链接
+	for _, action := range message.AllActions() {
+		content.WriteString(fmt.Sprintf("[%s](%s)\n\n", action.Label, action.URL))
+	}
+
+	// 添加时间戳
+	if message.Timestamp != "" {
+		content.WriteString(fmt.Sprintf("⏰ %s", message.Timestamp))
+	}
+
+	return content.String()
+}
+
+// buildText 构建文本消息内容，群机器人文本消息不支持超链接，按钮以“名称: 链接”形式附在末尾
+func (w *WechatWorkWebhookNotifier) buildText(message *NotificationMessage) string {
+	parts := []string{message.DisplayTitle(), message.PlainContent(), message.FieldsText("")}
+	for _, action := range message.AllActions() {
+		parts = append(parts, action.Label+": "+action.URL)
+	}
+	return joinNonEmpty(parts, "\n")
+}
+
 func (w *WechatWorkWebhookNotifier) checkResp(resp *resty.Response) error {
 	if resp.StatusCode() != 200 {
-		return fmt.Errorf("企业微信群机器人API返回错误状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+		return httpStatusError(resp)
 	}
 
 	// 解析响应
@@ -195,7 +330,7 @@ func (w *WechatWorkWebhookNotifier) checkResp(resp *resty.Response) error {
 	// 检查是否成功
 	if errcode, ok := result["errcode"].(float64); ok && errcode != 0 {
 		errmsg, _ := result["errmsg"].(string)
-		return fmt.Errorf("企业微信群机器人返回错误: errcode=%v, errmsg=%s", errcode, errmsg)
+		return wechatWorkError(int(errcode), "企业微信群机器人返回错误: "+errmsg)
 	}
 	return nil
 }
@@ -0,0 +1,683 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/store"
+)
+
+const (
+	bucketDeliveries = "outbox_deliveries" // 所有投递记录
+	bucketQueue      = "outbox_queue"      // 待投递索引: deliveryID -> 下次投递时间
+)
+
+// State 投递状态
+type State string
+
+const (
+	StatePending   State = "pending"   // 等待投递（包括等待重试）
+	StateSending   State = "sending"   // 投递中
+	StateSent      State = "sent"      // 投递成功
+	StateDead      State = "dead"      // 超过最大重试次数或不可重试的错误，进入死信
+	StateCancelled State = "cancelled" // 已取消（故障转移到下一组或消息已确认）
+)
+
+// ErrClosed 出站队列已停止接收新的投递
+var ErrClosed = errors.New("出站队列已关闭")
+
+// Delivery 一条消息发往某个通知服务（某个目标）的投递记录
+type Delivery struct {
+	ID            string                        `json:"id"`
+	MessageID     string                        `json:"messageId"`
+	AppID         string                        `json:"appId"`
+	Notifier      string                        `json:"notifier"`
+	Route         string                        `json:"route,omitempty"`      // 匹配的路由规则名称
+	Stage         int                           `json:"stage,omitempty"`      // 故障转移的阶段，0 为首次发送
+	Escalation    int                           `json:"escalation,omitempty"` // 升级的步骤（从 1 开始），0 表示不是升级通知
+	Targets       []string                      `json:"targets"`              // 为空表示使用通知服务的默认目标
+	Message       *notifier.NotificationMessage `json:"message"`
+	State         State                         `json:"state"`
+	Attempts      int                           `json:"attempts"`
+	MaxAttempts   int                           `json:"maxAttempts"`
+	NextAttemptAt time.Time                     `json:"nextAttemptAt"`
+	LastError     string                        `json:"lastError,omitempty"`
+	Results       notifier.Results              `json:"results,omitempty"` // 各目标最近一次的发送结果
+	CreatedAt     time.Time                     `json:"createdAt"`
+	UpdatedAt     time.Time                     `json:"updatedAt"`
+	SentAt        *time.Time                    `json:"sentAt,omitempty"`
+	Throttled     bool                          `json:"throttled,omitempty"` // 因限速推迟，尚未实际发送
+}
+
+// Sender 执行一次实际投递
+type Sender func(ctx context.Context, d *Delivery) error
+
+// permanentError 不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记错误不可重试，投递将直接进入死信
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// delayError 推迟投递：本次未实际发送（例如触发了限速），不计入投递次数
+type delayError struct {
+	wait time.Duration
+}
+
+func (e *delayError) Error() string { return fmt.Sprintf("推迟 %s 后发送", e.wait) }
+
+// Delay 推迟投递，wait 后重新发送，不计入投递次数
+func Delay(wait time.Duration) error {
+	return &delayError{wait: wait}
+}
+
+// retryAfterError 带有服务商重试提示的错误
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter 标记服务商要求的重试等待时间，重试时按该时间等待而不是指数退避
+func RetryAfter(err error, after time.Duration) error {
+	if err == nil || after <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, after: after}
+}
+
+// Options 出站队列配置
+type Options struct {
+	Workers        int           // 并发投递的协程数
+	MaxAttempts    int           // 最大投递次数，超过后进入死信
+	BaseBackoff    time.Duration // 首次重试等待时间，之后按指数增长
+	MaxBackoff     time.Duration // 最长重试等待时间
+	AttemptTimeout time.Duration // 单次投递超时
+	PollInterval   time.Duration // 扫描到期投递的间隔
+
+	// OnUpdate 每次投递尝试结束或状态被修改后回调，用于同步消息历史
+	OnUpdate func(d Delivery)
+}
+
+func (o *Options) setDefaults() {
+	if o.Workers <= 0 {
+		o.Workers = 10
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 8
+	}
+	if o.BaseBackoff <= 0 {
+		o.BaseBackoff = 30 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Minute
+	}
+	if o.AttemptTimeout <= 0 {
+		o.AttemptTimeout = 5 * time.Minute
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+}
+
+// Outbox 持久化出站队列：每条投递落盘后由工作协程发送，失败按指数退避重试，进程重启后继续投递
+type Outbox struct {
+	store  *store.Store
+	sender Sender
+	opts   Options
+
+	mu       sync.Mutex
+	closed   bool
+	inflight map[string]bool
+	waiters  map[string][]chan Delivery
+
+	jobs         chan string
+	wakeup       chan struct{}
+	stopDispatch chan struct{}
+	dispatchDone chan struct{}
+	workers      sync.WaitGroup
+
+	// ctx 在强制停止时取消，用于中断正在进行的投递
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New 创建出站队列
+func New(st *store.Store, sender Sender, opts Options) *Outbox {
+	opts.setDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Outbox{
+		store:        st,
+		sender:       sender,
+		opts:         opts,
+		inflight:     make(map[string]bool),
+		waiters:      make(map[string][]chan Delivery),
+		jobs:         make(chan string, opts.Workers),
+		wakeup:       make(chan struct{}, 1),
+		stopDispatch: make(chan struct{}),
+		dispatchDone: make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Start 恢复上次未完成的投递并启动工作协程
+func (o *Outbox) Start() error {
+	var queued []string
+	err := o.store.ForEach(bucketQueue, func(key string, _ []byte) error {
+		queued = append(queued, key)
+		return nil
+	})
+
+	var interrupted []Delivery
+	for _, id := range queued {
+		if err != nil {
+			break
+		}
+		var d Delivery
+		var ok bool
+		if ok, err = o.store.Get(bucketDeliveries, id, &d); ok && d.State == StateSending {
+			interrupted = append(interrupted, d)
+		}
+	}
+	if err == nil && len(interrupted) > 0 {
+		// 上次进程退出时正在投递的记录，重新放回队列
+		err = o.store.Update(func(tx *store.Tx) error {
+			for _, d := range interrupted {
+				d.State = StatePending
+				d.UpdatedAt = time.Now()
+				if err := tx.Put(bucketDeliveries, d.ID, d); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("恢复出站队列失败: %w", err)
+	}
+
+	if len(queued) > 0 {
+		logger.Info("恢复未完成的投递", "pending", len(queued), "interrupted", len(interrupted))
+	}
+
+	for i := 0; i < o.opts.Workers; i++ {
+		o.workers.Add(1)
+		go o.worker()
+	}
+	go o.dispatch()
+	return nil
+}
+
+// Stop 停止接收新投递，等待正在进行的投递完成；ctx 超时后中断投递，未完成的记录在下次启动时继续
+func (o *Outbox) Stop(ctx context.Context) error {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return nil
+	}
+	o.closed = true
+	o.mu.Unlock()
+
+	close(o.stopDispatch)
+	<-o.dispatchDone
+
+	done := make(chan struct{})
+	go func() {
+		o.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		o.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Enqueue 持久化投递记录并唤醒工作协程
+func (o *Outbox) Enqueue(deliveries ...*Delivery) error {
+	o.mu.Lock()
+	closed := o.closed
+	o.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	now := time.Now()
+	err := o.store.Update(func(tx *store.Tx) error {
+		for _, d := range deliveries {
+			d.State = StatePending
+			if d.MaxAttempts <= 0 {
+				d.MaxAttempts = o.opts.MaxAttempts
+			}
+			if d.NextAttemptAt.IsZero() {
+				d.NextAttemptAt = now
+			}
+			d.CreatedAt = now
+			d.UpdatedAt = now
+			if err := tx.Put(bucketDeliveries, d.ID, d); err != nil {
+				return err
+			}
+			if err := tx.Put(bucketQueue, d.ID, d.NextAttemptAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("写入出站队列失败: %w", err)
+	}
+
+	o.notifyDispatcher()
+	return nil
+}
+
+// Wait 等待投递完成首次尝试或因限速被推迟，返回各投递的最新状态；ctx 结束时返回已完成的部分
+func (o *Outbox) Wait(ctx context.Context, ids []string) map[string]Delivery {
+	results := make(map[string]Delivery, len(ids))
+	ch := make(chan Delivery, len(ids))
+
+	o.mu.Lock()
+	waiting := 0
+	for _, id := range ids {
+		var d Delivery
+		if ok, _ := o.store.Get(bucketDeliveries, id, &d); ok && (d.Attempts > 0 || d.Throttled) && d.State != StateSending {
+			results[id] = d
+			continue
+		}
+		o.waiters[id] = append(o.waiters[id], ch)
+		waiting++
+	}
+	o.mu.Unlock()
+
+	for waiting > 0 {
+		select {
+		case d := <-ch:
+			results[d.ID] = d
+			waiting--
+		case <-ctx.Done():
+			o.removeWaiter(ch)
+			return results
+		}
+	}
+	return results
+}
+
+// Get 获取投递记录
+func (o *Outbox) Get(id string) (Delivery, bool, error) {
+	var d Delivery
+	ok, err := o.store.Get(bucketDeliveries, id, &d)
+	return d, ok, err
+}
+
+// List 按创建时间倒序列出投递记录，state 为空时不过滤
+func (o *Outbox) List(state State, limit int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := o.store.ForEachReverse(bucketDeliveries, func(_ string, data []byte) error {
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil
+		}
+		if state != "" && d.State != state {
+			return nil
+		}
+		deliveries = append(deliveries, d)
+		if limit > 0 && len(deliveries) >= limit {
+			return store.ErrStop
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// ListByMessage 按投递顺序列出某条消息的所有投递记录
+func (o *Outbox) ListByMessage(messageID string) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := o.store.ForEachPrefix(bucketDeliveries, messageID+"-", func(_ string, data []byte) error {
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil
+		}
+		deliveries = append(deliveries, d)
+		return nil
+	})
+	return deliveries, err
+}
+
+// Retry 将死信重新放回队列
+func (o *Outbox) Retry(id string) (Delivery, error) {
+	var d Delivery
+	ok, err := o.store.Get(bucketDeliveries, id, &d)
+	if err != nil {
+		return d, err
+	}
+	if !ok {
+		return d, fmt.Errorf("投递记录 %s 不存在", id)
+	}
+	if d.State != StateDead {
+		return d, fmt.Errorf("投递记录 %s 当前状态为 %s，只有死信可以重试", id, d.State)
+	}
+
+	now := time.Now()
+	d.State = StatePending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.UpdatedAt = now
+	err = o.store.Update(func(tx *store.Tx) error {
+		if err := tx.Put(bucketDeliveries, d.ID, d); err != nil {
+			return err
+		}
+		return tx.Put(bucketQueue, d.ID, d.NextAttemptAt)
+	})
+	if err != nil {
+		return d, err
+	}
+	if o.opts.OnUpdate != nil {
+		o.opts.OnUpdate(d)
+	}
+	o.notifyDispatcher()
+	return d, nil
+}
+
+// Cancel 取消等待中的投递，正在发送或已完成的投递不受影响，返回被取消的投递
+func (o *Outbox) Cancel(ids ...string) ([]Delivery, error) {
+	var cancelled []Delivery
+	now := time.Now()
+	// 持有锁避免与调度协程同时取出同一条投递
+	o.mu.Lock()
+	err := o.store.Update(func(tx *store.Tx) error {
+		for _, id := range ids {
+			var d Delivery
+			ok, err := tx.Get(bucketDeliveries, id, &d)
+			if err != nil {
+				return err
+			}
+			if !ok || d.State != StatePending || o.inflight[id] {
+				continue
+			}
+			d.State = StateCancelled
+			d.UpdatedAt = now
+			if err := tx.Put(bucketDeliveries, d.ID, d); err != nil {
+				return err
+			}
+			if err := tx.Delete(bucketQueue, d.ID); err != nil {
+				return err
+			}
+			cancelled = append(cancelled, d)
+		}
+		return nil
+	})
+	o.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("取消投递失败: %w", err)
+	}
+	if o.opts.OnUpdate != nil {
+		for _, d := range cancelled {
+			o.opts.OnUpdate(d)
+		}
+	}
+	return cancelled, nil
+}
+
+// DeleteByMessage 删除某条消息已完成的投递记录，仍在队列中的投递会保留
+func (o *Outbox) DeleteByMessage(messageID string) error {
+	var finished []string
+	err := o.store.ForEachPrefix(bucketDeliveries, messageID+"-", func(key string, data []byte) error {
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil || d.State == StateSent || d.State == StateDead || d.State == StateCancelled {
+			finished = append(finished, key)
+		}
+		return nil
+	})
+	if err != nil || len(finished) == 0 {
+		return err
+	}
+	return o.store.Update(func(tx *store.Tx) error {
+		for _, id := range finished {
+			if err := tx.Delete(bucketDeliveries, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// notifyDispatcher 唤醒调度协程立即扫描
+func (o *Outbox) notifyDispatcher() {
+	select {
+	case o.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 定期扫描到期的投递并分发给工作协程
+func (o *Outbox) dispatch() {
+	defer close(o.dispatchDone)
+	defer close(o.jobs)
+
+	ticker := time.NewTicker(o.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !o.dispatchDue() {
+			return
+		}
+		select {
+		case <-o.stopDispatch:
+			return
+		case <-o.wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchDue 分发所有到期的投递，收到停止信号时返回 false
+func (o *Outbox) dispatchDue() bool {
+	now := time.Now()
+	var due []string
+	err := o.store.ForEach(bucketQueue, func(key string, data []byte) error {
+		var next time.Time
+		if err := json.Unmarshal(data, &next); err != nil || !next.After(now) {
+			due = append(due, key)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("扫描出站队列失败", "error", err)
+		return true
+	}
+
+	for _, id := range due {
+		o.mu.Lock()
+		if o.inflight[id] {
+			o.mu.Unlock()
+			continue
+		}
+		o.inflight[id] = true
+		o.mu.Unlock()
+
+		select {
+		case o.jobs <- id:
+		case <-o.stopDispatch:
+			o.mu.Lock()
+			delete(o.inflight, id)
+			o.mu.Unlock()
+			return false
+		}
+	}
+	return true
+}
+
+// worker 工作协程
+func (o *Outbox) worker() {
+	defer o.workers.Done()
+	for id := range o.jobs {
+		o.mu.Lock()
+		closed := o.closed
+		o.mu.Unlock()
+		if closed {
+			// 已停止：不再开始新的投递，留给下次启动
+			o.finish(id, nil)
+			continue
+		}
+		o.process(id)
+	}
+}
+
+// process 执行一次投递并更新状态
+func (o *Outbox) process(id string) {
+	var d Delivery
+	ok, err := o.store.Get(bucketDeliveries, id, &d)
+	if err != nil || !ok || (d.State != StatePending && d.State != StateSending) {
+		if err != nil {
+			logger.Error("读取投递记录失败", "id", id, "error", err)
+		} else {
+			// 记录已不存在或已完成，清理队列索引
+			_ = o.store.Delete(bucketQueue, id)
+		}
+		o.finish(id, nil)
+		return
+	}
+
+	d.State = StateSending
+	d.Attempts++
+	d.UpdatedAt = time.Now()
+	if err := o.store.Put(bucketDeliveries, d.ID, d); err != nil {
+		logger.Error("更新投递记录失败", "id", id, "error", err)
+		o.finish(id, nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(o.ctx, o.opts.AttemptTimeout)
+	sendErr := o.sender(ctx, &d)
+	cancel()
+
+	now := time.Now()
+	d.UpdatedAt = now
+	d.Throttled = false
+	removeFromQueue := true
+	var delay *delayError
+	var retryAfter *retryAfterError
+	switch {
+	case sendErr == nil:
+		d.State = StateSent
+		d.LastError = ""
+		d.SentAt = &now
+	case o.ctx.Err() != nil:
+		// 强制停止导致的中断不计入重试次数
+		d.State = StatePending
+		d.Attempts--
+		d.NextAttemptAt = now
+		removeFromQueue = false
+	case errors.As(sendErr, &delay):
+		d.State = StatePending
+		d.Attempts--
+		d.Throttled = true
+		d.NextAttemptAt = now.Add(delay.wait)
+		removeFromQueue = false
+	case IsPermanent(sendErr) || d.Attempts >= d.MaxAttempts:
+		d.State = StateDead
+		d.LastError = sendErr.Error()
+		logger.Error("投递进入死信", "id", d.ID, "notifier", d.Notifier, "attempts", d.Attempts, "error", sendErr)
+	default:
+		d.State = StatePending
+		d.LastError = sendErr.Error()
+		d.NextAttemptAt = now.Add(o.backoff(d.Attempts))
+		if errors.As(sendErr, &retryAfter) {
+			d.NextAttemptAt = now.Add(retryAfter.after)
+		}
+		removeFromQueue = false
+		logger.Warn("投递失败，等待重试", "id", d.ID, "notifier", d.Notifier, "attempts", d.Attempts, "next", d.NextAttemptAt.Format(time.RFC3339), "error", sendErr)
+	}
+
+	err = o.store.Update(func(tx *store.Tx) error {
+		if err := tx.Put(bucketDeliveries, d.ID, d); err != nil {
+			return err
+		}
+		if removeFromQueue {
+			return tx.Delete(bucketQueue, d.ID)
+		}
+		return tx.Put(bucketQueue, d.ID, d.NextAttemptAt)
+	})
+	if err != nil {
+		logger.Error("更新投递记录失败", "id", id, "error", err)
+	}
+
+	o.finish(id, &d)
+	if o.opts.OnUpdate != nil {
+		o.opts.OnUpdate(d)
+	}
+}
+
+// finish 释放投递的占用标记并通知等待者
+func (o *Outbox) finish(id string, d *Delivery) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.inflight, id)
+	// 限速推迟的投递也通知等待者，同步请求不必等到实际发送
+	if d == nil || d.State == StateSending || (d.Attempts == 0 && !d.Throttled) {
+		return
+	}
+	for _, ch := range o.waiters[id] {
+		ch <- *d
+	}
+	delete(o.waiters, id)
+}
+
+// removeWaiter 移除等待通道
+func (o *Outbox) removeWaiter(ch chan Delivery) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for id, chans := range o.waiters {
+		kept := chans[:0]
+		for _, c := range chans {
+			if c != ch {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 {
+			delete(o.waiters, id)
+		} else {
+			o.waiters[id] = kept
+		}
+	}
+}
+
+// backoff 计算第 attempts 次失败后的等待时间
+func (o *Outbox) backoff(attempts int) time.Duration {
+	wait := o.opts.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= o.opts.MaxBackoff {
+			return o.opts.MaxBackoff
+		}
+	}
+	return wait
+}
@@ -31,12 +31,39 @@ type Output struct {
 	// 多个目标
 	Targets []string `json:"targets"`
 
+	// 内容格式：plain/markdown/html，为空时由通知服务决定
+	Format string `json:"format,omitempty"`
+
+	// 消息级别：info/success/warning/error/critical
+	Level string `json:"level,omitempty"`
+
+	// 更多图片URL
+	Images []string `json:"images,omitempty"`
+
+	// 按钮
+	Actions []Action `json:"actions,omitempty"`
+
+	// 键值字段
+	Fields []Field `json:"fields,omitempty"`
+
 	// 元数据信息
 	Meta *MetaData `json:"meta"`
 	// 是否需要通知
 	IsNotify bool `json:"isNotify"`
 }
 
+// Action 消息按钮
+type Action struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// Field 消息键值字段
+type Field struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
 // MetaData 元数据结构
 type MetaData struct {
 	// 原始请求数据
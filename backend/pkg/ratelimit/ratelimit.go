@@ -0,0 +1,260 @@
+// Package ratelimit 通知服务的发送限速：令牌桶限制整个通知服务和每个目标的速率，
+// 并发数限制同时进行的请求；服务商返回限流提示时暂停发送
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+)
+
+// idleTTL 目标的令牌桶空闲超过该时间后清理
+const idleTTL = time.Hour
+
+// Rate 每秒产生的令牌数，0 表示不限制
+type Rate float64
+
+// ParseRate 解析速率，格式为 次数/单位，单位为 s、m、h 或时长（例如 20/m、30/s、1/5s），0 或空表示不限制
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	count, per, ok := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("速率 %q 格式错误，应为 次数/单位，例如 20/m", s)
+	}
+
+	var unit time.Duration
+	switch per = strings.TrimSpace(per); per {
+	case "s", "sec", "second":
+		unit = time.Second
+	case "m", "min", "minute":
+		unit = time.Minute
+	case "h", "hour":
+		unit = time.Hour
+	default:
+		if unit, err = time.ParseDuration(per); err != nil || unit <= 0 {
+			return 0, fmt.Errorf("速率 %q 的单位无效，可选 s、m、h 或时长", s)
+		}
+	}
+	return Rate(n / unit.Seconds()), nil
+}
+
+// bucket 令牌桶
+type bucket struct {
+	rate   Rate
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate Rate, burst int, now time.Time) *bucket {
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// refill 按经过的时间补充令牌
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * float64(b.rate)
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// wait 获取一个令牌还需要等待的时间，不消耗令牌
+func (b *bucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / float64(b.rate) * float64(time.Second))
+}
+
+// Limiter 单个通知服务的限速器
+type Limiter struct {
+	cfg         config.RateLimit
+	rate        Rate
+	perTarget   Rate
+	burst       int
+	concurrency int
+
+	mu          sync.Mutex
+	global      *bucket
+	targets     map[string]*bucket
+	active      int
+	pausedUntil time.Time
+	lastPrune   time.Time
+}
+
+// New 根据配置创建限速器
+func New(cfg config.RateLimit) (*Limiter, error) {
+	rate, err := ParseRate(cfg.Rate)
+	if err != nil {
+		return nil, err
+	}
+	perTarget, err := ParseRate(cfg.PerTarget)
+	if err != nil {
+		return nil, fmt.Errorf("每个目标的%w", err)
+	}
+	if cfg.Burst < 0 || cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("突发条数和并发数不能小于 0")
+	}
+	burst := cfg.Burst
+	if burst == 0 {
+		burst = 1
+	}
+
+	now := time.Now()
+	l := &Limiter{
+		cfg:         cfg,
+		rate:        rate,
+		perTarget:   perTarget,
+		burst:       burst,
+		concurrency: cfg.Concurrency,
+		targets:     make(map[string]*bucket),
+		lastPrune:   now,
+	}
+	if rate > 0 {
+		l.global = newBucket(rate, burst, now)
+	}
+	return l, nil
+}
+
+// Acquire 为发送到 targets 的一次投递申请配额：可以发送时返回释放并发配额的函数，
+// 否则返回需要等待的时间，不占用任何配额
+func (l *Limiter) Acquire(targets []string) (release func(), wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	if now.Before(l.pausedUntil) {
+		return nil, l.pausedUntil.Sub(now)
+	}
+	if l.concurrency > 0 && l.active >= l.concurrency {
+		// 无法预知正在进行的请求何时结束，稍后再试
+		return nil, 200 * time.Millisecond
+	}
+
+	var buckets []*bucket
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if l.perTarget > 0 {
+		if len(targets) == 0 {
+			// 使用通知服务的默认目标
+			targets = []string{""}
+		}
+		for _, target := range targets {
+			b, ok := l.targets[target]
+			if !ok {
+				b = newBucket(l.perTarget, l.burst, now)
+				l.targets[target] = b
+			}
+			buckets = append(buckets, b)
+		}
+	}
+	for _, b := range buckets {
+		if w := b.wait(now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return nil, wait
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	l.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.active--
+			l.mu.Unlock()
+		})
+	}, 0
+}
+
+// Pause 服务商返回限流提示时，在 d 时间内暂停整个通知服务的发送
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// prune 清理长时间空闲的目标令牌桶，调用方需持有锁
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < idleTTL {
+		return
+	}
+	l.lastPrune = now
+	for target, b := range l.targets {
+		if now.Sub(b.last) > idleTTL {
+			delete(l.targets, target)
+		}
+	}
+}
+
+// Registry 按通知服务名称保存限速器，配置变化时重新创建
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// NewRegistry 创建限速器注册表
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Get 获取通知服务的限速器；未配置任何限制时也返回限速器，用于服务商限流时暂停发送
+func (r *Registry) Get(name string, cfg config.RateLimit) (*Limiter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.limiters[name]; ok && l.cfg == cfg {
+		return l, nil
+	}
+	l, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.limiters[name] = l
+	return l, nil
+}
+
+// Merge 用实例配置覆盖类型默认值，实例未设置的字段沿用默认值
+func Merge(defaults, override *config.RateLimit) config.RateLimit {
+	var cfg config.RateLimit
+	if defaults != nil {
+		cfg = *defaults
+	}
+	if override == nil {
+		return cfg
+	}
+	if override.Rate != "" {
+		cfg.Rate = override.Rate
+	}
+	if override.Burst != 0 {
+		cfg.Burst = override.Burst
+	}
+	if override.PerTarget != "" {
+		cfg.PerTarget = override.PerTarget
+	}
+	if override.Concurrency != 0 {
+		cfg.Concurrency = override.Concurrency
+	}
+	return cfg
+}
@@ -0,0 +1,212 @@
+// Package schedule 免打扰时段和定时发送：判断消息是否处于免打扰时段及时段的结束时间，
+// 解析通知接口的 send_at、delay 参数。推迟的投递由出站队列按投递时间持久化保存
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+)
+
+// 免打扰时段内的处理方式
+const (
+	ActionDefer = "defer" // 推迟到时段结束后发送（默认）
+	ActionDrop  = "drop"  // 丢弃
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// window 解析后的时段，时间为当天的分钟数
+type window struct {
+	start, end int
+	days       map[time.Weekday]bool // 为空表示每天
+}
+
+// contains 判断以 day 为开始日期的时段是否包含 t，返回时段结束时间
+func (w window) contains(t time.Time, day time.Time) (time.Time, bool) {
+	if len(w.days) > 0 && !w.days[day.Weekday()] {
+		return time.Time{}, false
+	}
+	// 按日历时间计算边界，夏令时切换当天一天不是 24 小时，不能直接加分钟数
+	y, m, d := day.Date()
+	start := time.Date(y, m, d, w.start/60, w.start%60, 0, 0, day.Location())
+	endDay := d
+	if w.end <= w.start {
+		// 跨天，开始和结束相同表示全天
+		endDay++
+	}
+	end := time.Date(y, m, endDay, w.end/60, w.end%60, 0, 0, day.Location())
+	if t.Before(start) || !t.Before(end) {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// Quiet 解析后的免打扰配置
+type Quiet struct {
+	loc     *time.Location
+	windows []window
+	action  string
+	bypass  notifier.Level
+}
+
+// Parse 解析免打扰配置
+func Parse(q *config.QuietHours) (*Quiet, error) {
+	quiet := &Quiet{loc: time.Local, action: ActionDefer}
+	if q.Timezone != "" {
+		loc, err := time.LoadLocation(q.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("时区 %q 无效: %w", q.Timezone, err)
+		}
+		quiet.loc = loc
+	}
+
+	switch strings.ToLower(q.Action) {
+	case "", ActionDefer:
+	case ActionDrop:
+		quiet.action = ActionDrop
+	default:
+		return nil, fmt.Errorf("处理方式 %q 无效，可选 defer、drop", q.Action)
+	}
+
+	if q.Bypass != "" {
+		if quiet.bypass = notifier.ParseLevel(q.Bypass); quiet.bypass == "" {
+			return nil, fmt.Errorf("级别 %q 无效", q.Bypass)
+		}
+	}
+
+	for i, w := range q.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个时段的开始时间: %w", i+1, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个时段的结束时间: %w", i+1, err)
+		}
+		parsed := window{start: start, end: end}
+		for _, day := range w.Days {
+			wd, ok := parseWeekday(day)
+			if !ok {
+				return nil, fmt.Errorf("第 %d 个时段的星期 %q 无效，可选 mon、tue、wed、thu、fri、sat、sun", i+1, day)
+			}
+			if parsed.days == nil {
+				parsed.days = make(map[time.Weekday]bool)
+			}
+			parsed.days[wd] = true
+		}
+		quiet.windows = append(quiet.windows, parsed)
+	}
+	return quiet, nil
+}
+
+// parseWeekday 解析星期，支持 mon、monday 等英文名称
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) > 3 {
+		s = s[:3]
+	}
+	wd, ok := weekdays[s]
+	return wd, ok
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回当天的分钟数，允许 24:00
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute > 0) {
+		return 0, fmt.Errorf("时间 %q 格式错误，应为 HH:MM", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// Check 判断级别为 level 的消息在 now 时刻是否处于免打扰时段，处于时段内时返回处理方式和可以发送的时间
+func (q *Quiet) Check(level notifier.Level, now time.Time) (action string, until time.Time, quiet bool) {
+	if q.bypass != "" && level.Rank() >= q.bypass.Rank() {
+		return "", time.Time{}, false
+	}
+
+	t := now.In(q.loc)
+	// 相邻或重叠的时段连续推迟，限制次数避免全天免打扰时无限循环
+	for i := 0; i < 7*len(q.windows)+1; i++ {
+		end, ok := q.covering(t)
+		if !ok {
+			break
+		}
+		t, quiet = end, true
+	}
+	if !quiet {
+		return "", time.Time{}, false
+	}
+	return q.action, t, true
+}
+
+// covering 查找包含 t 的时段，返回最晚的结束时间
+func (q *Quiet) covering(t time.Time) (time.Time, bool) {
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	yesterday := today.AddDate(0, 0, -1)
+
+	var until time.Time
+	found := false
+	for _, w := range q.windows {
+		for _, day := range []time.Time{yesterday, today} {
+			if end, ok := w.contains(t, day); ok && end.After(until) {
+				until, found = end, true
+			}
+		}
+	}
+	return until, found
+}
+
+// CheckConfig 判断消息是否处于配置的免打扰时段，未配置时返回 false
+func CheckConfig(q *config.QuietHours, level notifier.Level, now time.Time) (action string, until time.Time, quiet bool, err error) {
+	if q == nil || len(q.Windows) == 0 {
+		return "", time.Time{}, false, nil
+	}
+	parsed, err := Parse(q)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	action, until, quiet = parsed.Check(level, now)
+	return action, until, quiet, nil
+}
+
+// ParseSendAt 解析定时发送时间：sendAt 为 RFC3339 时间、"2006-01-02 15:04:05"（本地时间）或 Unix 时间戳，
+// delay 为时长（例如 30m）或秒数；都为空时返回零值
+func ParseSendAt(sendAt, delay string, now time.Time) (time.Time, error) {
+	sendAt, delay = strings.TrimSpace(sendAt), strings.TrimSpace(delay)
+	switch {
+	case sendAt != "":
+		if ts, err := strconv.ParseInt(sendAt, 10, 64); err == nil {
+			if ts > 1e12 {
+				// 毫秒时间戳
+				return time.UnixMilli(ts), nil
+			}
+			return time.Unix(ts, 0), nil
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05"} {
+			if t, err := time.ParseInLocation(layout, sendAt, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("发送时间 %q 格式错误", sendAt)
+	case delay != "":
+		if seconds, err := strconv.ParseFloat(delay, 64); err == nil {
+			return now.Add(time.Duration(seconds * float64(time.Second))), nil
+		}
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("延迟 %q 格式错误: %w", delay, err)
+		}
+		return now.Add(d), nil
+	}
+	return time.Time{}, nil
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupAckRoutes 设置消息确认路由
+func (s *HTTPServer) setupAckRoutes(api *gin.RouterGroup) {
+	// 消息中的确认链接（无需认证，通过令牌校验）。GET 只显示确认页面，
+	// 避免链接预览、邮件安全扫描等自动访问链接时误确认
+	api.GET("/ack/:id", s.handleAckPage)
+	api.POST("/ack/:id", s.handleAckWithToken)
+}
+
+// AckResponse 确认结果
+type AckResponse struct {
+	MessageID string     `json:"messageId"`
+	AckedAt   *time.Time `json:"ackedAt"`
+	AckedBy   string     `json:"ackedBy,omitempty"`
+}
+
+// ackPage 确认页面：GET 时显示确认按钮，按钮提交后显示确认结果
+var ackPage = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>确认消息</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; background: #f5f5f5; margin: 0; }
+.card { max-width: 420px; margin: 15vh auto 0; background: #fff; border-radius: 8px; padding: 32px; box-shadow: 0 1px 4px rgba(0,0,0,.1); text-align: center; }
+h1 { font-size: 20px; margin: 0 0 12px; }
+p { color: #666; margin: 0 0 24px; word-break: break-all; }
+button { background: #1976d2; color: #fff; border: 0; border-radius: 4px; padding: 10px 32px; font-size: 16px; cursor: pointer; }
+.error { color: #d32f2f; }
+</style>
+</head>
+<body>
+<div class="card">
+{{- if .Done}}
+<h1>已确认</h1>
+<p>消息 {{.ID}} 已由 {{.By}} 于 {{.At}} 确认，不会再升级通知</p>
+{{- else if .Error}}
+<h1 class="error">确认失败</h1>
+<p>{{.Error}}</p>
+{{- else}}
+<h1>确认消息</h1>
+<p>确认后消息 {{.ID}} 不会再升级通知</p>
+<form method="post">
+<button type="submit">确认</button>
+</form>
+{{- end}}
+</div>
+</body>
+</html>`))
+
+type ackPageData struct {
+	ID    string
+	Done  bool
+	By    string
+	At    string
+	Error string
+}
+
+// handleAckPage 显示确认页面，页面中的表单提交到当前地址 (GET /ack/:id?token=令牌&by=确认人)
+func (s *HTTPServer) handleAckPage(c *gin.Context) {
+	renderAckPage(c, http.StatusOK, ackPageData{ID: c.Param("id")})
+}
+
+// handleAckWithToken 通过确认链接确认消息 (POST /ack/:id?token=令牌&by=确认人)
+func (s *HTTPServer) handleAckWithToken(c *gin.Context) {
+	id := c.Param("id")
+	by := c.Query("by")
+	if by == "" {
+		by = "link"
+	}
+
+	record, err := s.app.AcknowledgeWithToken(id, c.Query("token"), by)
+	if err != nil {
+		writeAckError(c, id, err)
+		return
+	}
+	if wantsHTML(c) {
+		data := ackPageData{ID: record.ID, Done: true, By: record.AckedBy}
+		if record.AckedAt != nil {
+			data.At = record.AckedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		renderAckPage(c, http.StatusOK, data)
+		return
+	}
+	c.JSON(http.StatusOK, NewSuccessRes(AckResponse{MessageID: record.ID, AckedAt: record.AckedAt, AckedBy: record.AckedBy}))
+}
+
+// writeAckError 输出确认失败的响应
+func writeAckError(c *gin.Context, id string, err error) {
+	status, res := http.StatusOK, NewErrorRes(SYSTEM_ERROR, err.Error())
+	switch {
+	case errors.Is(err, app.ErrMessageNotFound):
+		status, res = http.StatusNotFound, NewErrorRes(MESSAGE_NOT_FOUND, fmt.Sprintf("消息 %s 不存在", id))
+	case errors.Is(err, app.ErrInvalidAckToken):
+		status, res = http.StatusForbidden, NewErrorRes(PERMISSION_ERROR, err.Error())
+	}
+	if wantsHTML(c) {
+		renderAckPage(c, status, ackPageData{ID: id, Error: res.Msg})
+		return
+	}
+	c.JSON(status, res)
+}
+
+// wantsHTML 请求来自确认页面的表单（浏览器）时返回页面，接口调用返回 JSON
+func wantsHTML(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+func renderAckPage(c *gin.Context, status int, data ackPageData) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := ackPage.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/jianxcao/notify/backend/pkg/app"
+	"github.com/jianxcao/notify/backend/pkg/circuit"
 	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
 
 	"github.com/gin-gonic/gin"
 )
@@ -35,6 +38,12 @@ func (s *HTTPServer) setupAdminRoutes(api *gin.RouterGroup) {
 
 		// 插件管理
 		s.setupPluginManagementRoutes(admin)
+
+		// 出站队列管理 (定义在 outbox_routes.go)
+		s.setupOutboxManagementRoutes(admin)
+
+		// 消息状态查询 (定义在 message_routes.go)
+		s.setupMessageManagementRoutes(admin)
 	}
 }
 
@@ -42,11 +51,13 @@ func (s *HTTPServer) setupAdminRoutes(api *gin.RouterGroup) {
 func (s *HTTPServer) setupAppManagementRoutes(admin *gin.RouterGroup) {
 	apps := admin.Group("/apps")
 	{
-		apps.GET("", s.handleGetApps)                // 获取所有应用
-		apps.POST("", s.handleCreateApp)             // 创建新应用
-		apps.GET("/:appid", s.handleGetAppConfig)    // 获取单个应用配置
-		apps.PUT("/:appid", s.handleUpdateAppConfig) // 更新应用配置
-		apps.DELETE("/:appid", s.handleDeleteApp)    // 删除应用
+		apps.GET("", s.handleGetApps)                    // 获取所有应用
+		apps.POST("", s.handleCreateApp)                 // 创建新应用
+		apps.GET("/:appid", s.handleGetAppConfig)        // 获取单个应用配置
+		apps.PUT("/:appid", s.handleUpdateAppConfig)     // 更新应用配置
+		apps.DELETE("/:appid", s.handleDeleteApp)        // 删除应用
+		apps.POST("/:appid/test", s.handleTestApp)       // 使用示例数据测试应用 (定义在 test_routes.go)
+		apps.POST("/:appid/preview", s.handleAppPreview) // 预览各通知服务将要发送的请求 (定义在 test_routes.go)
 	}
 }
 
@@ -58,7 +69,11 @@ func (s *HTTPServer) setupNotifierManagementRoutes(admin *gin.RouterGroup) {
 		notifiers.GET("/:notifier", s.handleGetNotifierConfig)    // 获取单个通知服务配置
 		notifiers.PUT("/:notifier", s.handleUpdateNotifierConfig) // 更新通知服务配置
 		notifiers.DELETE("/:notifier", s.handleDeleteNotifier)    // 删除通知服务
+		notifiers.POST("/:notifier/test", s.handleTestNotifier)   // 测试通知服务 (定义在 test_routes.go)
 	}
+
+	// 通知服务类型元数据（字段描述、必填项、敏感项），供前端渲染配置表单
+	admin.GET("/notifier-types", s.handleGetNotifierTypes)
 }
 
 // setupTemplateManagementRoutes 设置模板管理路由
@@ -105,6 +120,12 @@ func (s *HTTPServer) handleUpdateAppConfig(c *gin.Context) {
 	// 确保 AppID 与路径参数一致
 	updateReq.AppID = appID
 
+	// 路由、过滤等表达式在发送时出错只会记录日志，保存前校验
+	if err := app.ValidateApp(s.config, appID, updateReq); err != nil {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, err.Error()))
+		return
+	}
+
 	// 直接使用 updateReq 参数更新应用配置
 	if err := s.configManager.UpdateAppConfig(updateReq); err != nil {
 		c.JSON(http.StatusOK, NewErrorRes(APP_CONFIG_ERROR, "更新应用配置失败"))
@@ -138,6 +159,11 @@ func (s *HTTPServer) handleCreateApp(c *gin.Context) {
 		return
 	}
 
+	if err := app.ValidateApp(s.config, createReq.AppID, createReq); err != nil {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, err.Error()))
+		return
+	}
+
 	// 使用ConfigManager创建应用，直接使用 AppID 作为 map key
 	if err := s.configManager.CreateApp(createReq.AppID, createReq); err != nil {
 		if err.Error() == fmt.Sprintf("应用 %s 已存在", createReq.AppID) {
@@ -299,7 +325,27 @@ func (s *HTTPServer) handleDeleteTemplate(c *gin.Context) {
 
 // handleGetNotifiers 获取所有通知服务实例
 func (s *HTTPServer) handleGetNotifiers(c *gin.Context) {
-	c.JSON(http.StatusOK, NewSuccessRes(s.config.Notifiers))
+	health := s.app.NotifierHealth()
+	notifiers := make(map[string]NotifierListItem, len(s.config.Notifiers))
+	for name, instance := range s.config.Notifiers {
+		item := NotifierListItem{NotifierInstance: instance}
+		if h, ok := health[name]; ok {
+			item.Health = &h
+		}
+		notifiers[name] = item
+	}
+	c.JSON(http.StatusOK, NewSuccessRes(notifiers))
+}
+
+// NotifierListItem 通知服务列表项：实例配置和健康状态（未启用的通知服务没有健康状态）
+type NotifierListItem struct {
+	config.NotifierInstance
+	Health *circuit.Health `json:"health,omitempty"`
+}
+
+// handleGetNotifierTypes 获取所有已注册的通知服务类型
+func (s *HTTPServer) handleGetNotifierTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, NewSuccessRes(notifier.Types()))
 }
 
 // NotifierConfigResponse 通知服务配置响应结构体（驼峰命名）
@@ -323,10 +369,9 @@ func (s *HTTPServer) handleGetNotifierConfig(c *gin.Context) {
 	// 隐藏敏感信息
 	safeConfig := make(map[string]interface{})
 	for key, value := range notifierInstance.Config {
-		switch key {
-		case "secret", "bot_token", "access_token":
+		if notifier.IsSecretField(notifierInstance.Type, key) {
 			safeConfig[key] = "***"
-		default:
+		} else {
 			safeConfig[key] = value
 		}
 	}
@@ -357,6 +402,16 @@ func (s *HTTPServer) handleUpdateNotifierConfig(c *gin.Context) {
 		return
 	}
 
+	if _, exists := notifier.Lookup(updateReq.Type); !exists {
+		c.JSON(http.StatusOK, NewErrorRes(NOTIFIER_CONFIG_ERROR, fmt.Sprintf("未知的通知服务类型: %s", updateReq.Type)))
+		return
+	}
+	// 校验失败的配置保存后会在初始化时被跳过，保存前拒绝
+	if err := notifier.Validate(updateReq); err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(NOTIFIER_CONFIG_ERROR, fmt.Sprintf("配置校验失败: %v", err)))
+		return
+	}
+
 	// 更新配置
 	newNotifiers := make(map[string]config.NotifierInstance)
 	for k, v := range s.config.Notifiers {
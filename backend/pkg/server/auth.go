@@ -94,11 +94,22 @@ func (am *AuthMiddleware) BasicAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// isAdmin 判断请求是否带有管理员的 Basic 认证，未设置管理员账号时视为管理员（与管理接口一致）
+func (am *AuthMiddleware) isAdmin(r *http.Request) bool {
+	username := config.EnvCfg.NOTIFY_USERNAME
+	password := config.EnvCfg.NOTIFY_PASSWORD
+	if username == "" || password == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok && user == username && pass == password
+}
+
 // validateAppToken 验证应用Token
 func (am *AuthMiddleware) validateAppToken(r *http.Request, expectedToken string) bool {
-	// 从Header中获取Token
+	// 从Header中获取Token，Header 用于管理员认证（预览模式）时从查询参数获取
 	auth := r.Header.Get("Authorization")
-	if auth == "" {
+	if !strings.HasPrefix(auth, "Bearer ") {
 		auth = r.URL.Query().Get("token")
 	}
 	if auth == "" {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/jianxcao/notify/backend/pkg/circuit"
 	"github.com/jianxcao/notify/backend/pkg/config"
 
 	"github.com/gin-gonic/gin"
@@ -38,6 +39,8 @@ type HealthData struct {
 	AdminEndpoints    AdminEndpoints `json:"adminEndpoints"`
 	AdminAuthRequired bool           `json:"adminAuthRequired"` // 新增：admin接口是否需要认证
 	Version           string         `json:"version"`
+	// Notifiers 各通知服务的健康状态，存在熔断的通知服务时 status 为 degraded
+	Notifiers map[string]circuit.Health `json:"notifiers"`
 }
 
 // handleHealth 健康检查
@@ -69,8 +72,19 @@ func (s *HTTPServer) handleHealth(c *gin.Context) {
 	// 检查admin是否需要认证：如果环境变量设置了用户名和密码，则需要认证
 	adminAuthRequired := config.EnvCfg.NOTIFY_USERNAME != "" && config.EnvCfg.NOTIFY_PASSWORD != ""
 
+	status := "healthy"
+	notifiers := s.app.NotifierHealth()
+	for name, health := range notifiers {
+		if health.State != circuit.StateClosed {
+			status = "degraded"
+		}
+		// 健康检查无需认证，错误信息可能包含请求地址中的密钥，只在管理接口中返回
+		health.LastError = ""
+		notifiers[name] = health
+	}
+
 	healthData := HealthData{
-		Status:            "healthy",
+		Status:            status,
 		SupportedApps:     supportedApps,
 		AdminAuthRequired: adminAuthRequired,
 		AdminEndpoints: AdminEndpoints{
@@ -78,7 +92,8 @@ func (s *HTTPServer) handleHealth(c *gin.Context) {
 			Notifiers: "/api/v1/admin/notifiers",
 			Config:    "/api/v1/admin/config",
 		},
-		Version: config.EnvCfg.VERSION,
+		Version:   config.EnvCfg.VERSION,
+		Notifiers: notifiers,
 	}
 
 	c.JSON(http.StatusOK, NewSuccessRes(healthData))
@@ -65,7 +65,7 @@ func (s *HTTPServer) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Prefer")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -94,6 +94,12 @@ func (s *HTTPServer) setupRoutes() {
 
 	// 设置日志流路由 (定义在 log_routes.go)
 	s.setupLogRoutes(api)
+
+	// 设置图片缓存路由 (定义在 media_routes.go)
+	s.setupMediaRoutes(api)
+
+	// 设置消息确认路由 (定义在 ack_routes.go)
+	s.setupAckRoutes(api)
 }
 
 // setupStaticRoutes 设置静态文件路由 (前端资源)
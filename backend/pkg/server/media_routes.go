@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/media"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupMediaRoutes 设置图片缓存路由
+func (s *HTTPServer) setupMediaRoutes(api *gin.RouterGroup) {
+	// 渠道服务器拉取图片（无需认证，通过链接签名校验）
+	api.GET("/media/:id", s.handleGetMedia)
+	api.HEAD("/media/:id", s.handleGetMedia)
+}
+
+// handleGetMedia 获取缓存的图片 (GET /media/:id?e=过期时间&s=签名)
+func (s *HTTPServer) handleGetMedia(c *gin.Context) {
+	cache := s.app.Media()
+	if cache == nil {
+		c.JSON(http.StatusNotFound, NewErrorRes(NOT_FOUND_ERROR, "图片缓存未启用"))
+		return
+	}
+
+	id := c.Param("id")
+	if err := cache.Verify(id, c.Query("e"), c.Query("s")); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, media.ErrExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, NewErrorRes(PERMISSION_ERROR, err.Error()))
+		return
+	}
+
+	data, contentType, err := cache.Get(id)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			c.JSON(http.StatusNotFound, NewErrorRes(NOT_FOUND_ERROR, err.Error()))
+			return
+		}
+		logger.Error("读取缓存图片失败", "id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, NewErrorRes(SERVER_ERROR, err.Error()))
+		return
+	}
+
+	// 图片内容由哈希确定，不会变化
+	c.Header("Cache-Control", "public, max-age=86400, immutable")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/app"
+	"github.com/jianxcao/notify/backend/pkg/history"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupMessageManagementRoutes 设置消息历史管理路由
+func (s *HTTPServer) setupMessageManagementRoutes(admin *gin.RouterGroup) {
+	messages := admin.Group("/messages")
+	{
+		messages.GET("", s.handleGetMessages)               // 查询消息历史
+		messages.GET("/:id", s.handleGetMessage)            // 获取消息详情及投递状态
+		messages.POST("/:id/resend", s.handleResendMessage) // 重新发送消息
+		messages.POST("/:id/ack", s.handleAckMessage)       // 确认消息，取消未发送的升级通知
+	}
+}
+
+// MessageListResponse 消息历史列表响应
+type MessageListResponse struct {
+	Total int              `json:"total"`
+	Items []history.Record `json:"items"`
+}
+
+// handleGetMessages 查询消息历史，支持按应用、通知服务、状态、关键字和时间范围过滤
+func (s *HTTPServer) handleGetMessages(c *gin.Context) {
+	filter := history.Filter{
+		AppID:    c.Query("appId"),
+		Notifier: c.Query("notifier"),
+		Status:   c.Query("status"),
+		Keyword:  c.Query("keyword"),
+	}
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	var err error
+	if filter.From, err = parseTimeQuery(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, fmt.Sprintf("from 参数格式错误: %v", err)))
+		return
+	}
+	if filter.To, err = parseTimeQuery(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, fmt.Sprintf("to 参数格式错误: %v", err)))
+		return
+	}
+
+	records, total, err := s.app.GetHistory().List(filter)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(SYSTEM_ERROR, fmt.Sprintf("读取消息历史失败: %v", err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(MessageListResponse{Total: total, Items: records}))
+}
+
+// handleGetMessage 获取消息详情，包括原始请求、渲染结果和各通知服务、各目标的投递状态
+func (s *HTTPServer) handleGetMessage(c *gin.Context) {
+	id := c.Param("id")
+
+	record, exists, err := s.app.GetHistory().Get(id)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(SYSTEM_ERROR, fmt.Sprintf("读取消息历史失败: %v", err)))
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusOK, NewErrorRes(MESSAGE_NOT_FOUND, fmt.Sprintf("消息 %s 不存在", id)))
+		return
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(record))
+}
+
+// handleResendMessage 重新发送消息，可指定新的通知服务列表
+func (s *HTTPServer) handleResendMessage(c *gin.Context) {
+	id := c.Param("id")
+
+	var resendReq struct {
+		Notifiers []string `json:"notifiers"`
+		Async     bool     `json:"async"`
+	}
+	// 请求体可选
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&resendReq); err != nil {
+			c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, "解析请求失败"))
+			return
+		}
+	}
+
+	if _, exists, _ := s.app.GetHistory().Get(id); !exists {
+		c.JSON(http.StatusOK, NewErrorRes(MESSAGE_NOT_FOUND, fmt.Sprintf("消息 %s 不存在", id)))
+		return
+	}
+
+	result, err := s.app.Resend(c.Request.Context(), id, resendReq.Notifiers, app.SendOptions{Async: resendReq.Async})
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(NOTIFICATION_SEND_FAILED, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(result))
+}
+
+// handleAckMessage 确认消息，请求体可指定确认人
+func (s *HTTPServer) handleAckMessage(c *gin.Context) {
+	id := c.Param("id")
+
+	var ackReq struct {
+		By string `json:"by"`
+	}
+	// 请求体可选
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&ackReq); err != nil {
+			c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, "解析请求失败"))
+			return
+		}
+	}
+	if ackReq.By == "" {
+		ackReq.By = "admin"
+	}
+
+	record, err := s.app.Acknowledge(id, ackReq.By)
+	if err != nil {
+		writeAckError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, NewSuccessRes(record))
+}
+
+// parseTimeQuery 解析时间查询参数，支持 RFC3339 和毫秒时间戳
+func parseTimeQuery(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -1,15 +1,23 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/jianxcao/notify/backend/pkg/app"
 	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/history"
 	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+	"github.com/jianxcao/notify/backend/pkg/schedule"
 
 	"github.com/gin-gonic/gin"
 )
@@ -109,7 +117,8 @@ func (s *HTTPServer) handleSendNotification(c *gin.Context) {
 			}
 		}
 	} else if strings.Contains(strings.ToLower(contentType), "multipart/form-data") {
-		// 解析 multipart/form-data
+		// 解析 multipart/form-data，请求体已经读取过，需要重新设置
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
 		err := c.Request.ParseMultipartForm(32 << 20) // 32MB max memory
 		if err != nil {
 			logger.Error("解析 multipart/form-data 失败", "error", err)
@@ -125,6 +134,19 @@ func (s *HTTPServer) handleSendNotification(c *gin.Context) {
 				}
 			}
 		}
+
+		// 上传的图片文件转换为 data: URI，由通知服务上传到各渠道
+		if c.Request.MultipartForm != nil {
+			for key, files := range c.Request.MultipartForm.File {
+				images, err := readImageFiles(files)
+				if err != nil {
+					logger.Error("读取上传的图片失败", "field", key, "error", err)
+					c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, fmt.Sprintf("读取上传的图片 %s 失败: %v", key, err)))
+					return
+				}
+				rawData[key] = strings.Join(images, "\n")
+			}
+		}
 	} else {
 		// 从JSON body获取原始数据
 		err := json.Unmarshal(body, &rawData)
@@ -137,18 +159,31 @@ func (s *HTTPServer) handleSendNotification(c *gin.Context) {
 
 	logger.Debug("发送通知原始参数", "data", rawData)
 
-	// 发送通知
-	if err := s.app.Send(c.Request.Context(), appConfig, &rawData); err != nil {
-		logger.Error("发送通知失败", "error", err)
-		c.JSON(http.StatusOK, NewErrorRes(NOTIFICATION_SEND_FAILED, err.Error()))
-		return
-	}
+	s.sendNotification(c, appConfig, rawData, c.Request.Method)
+}
 
-	// 返回成功响应
-	c.JSON(http.StatusOK, NewSuccessRes(map[string]interface{}{
-		"appName": appConfig.Name,
-		"method":  "POST",
-	}))
+// readImageFiles 读取上传的图片文件并转换为 data: URI
+func readImageFiles(files []*multipart.FileHeader) ([]string, error) {
+	images := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.Size > notifier.MaxImageSize {
+			return nil, fmt.Errorf("图片 %s 超过大小限制", file.Filename)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if contentType := http.DetectContentType(data); !strings.HasPrefix(contentType, "image/") {
+			return nil, fmt.Errorf("%s 不是图片: %s", file.Filename, contentType)
+		}
+		images = append(images, notifier.EncodeDataURI(data, ""))
+	}
+	return images, nil
 }
 
 // handleSendNotificationByQuery 发送通知 (GET /notify/:appname) - 从query参数获取
@@ -162,16 +197,250 @@ func (s *HTTPServer) handleSendNotificationByQuery(c *gin.Context) {
 			rawData[key] = values[0] // 取第一个值
 		}
 	}
+	// async、dryRun 是控制参数，不作为消息数据
+	delete(rawData, "async")
+	delete(rawData, "dryRun")
 	logger.Debug("发送通知原始参数", "data", rawData)
-	// 发送通知
-	if err := s.app.Send(c.Request.Context(), appConfig, &rawData); err != nil {
+
+	s.sendNotification(c, appConfig, rawData, "GET")
+}
+
+// isAsyncRequest 判断请求是否要求异步处理：?async=1 或 Prefer: respond-async
+func isAsyncRequest(c *gin.Context) bool {
+	switch strings.ToLower(c.Query("async")) {
+	case "1", "true", "yes":
+		return true
+	}
+	for _, prefer := range c.Request.Header.Values("Prefer") {
+		for _, item := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDryRunRequest 判断请求是否为预览模式：?dryRun=1
+func isDryRunRequest(c *gin.Context) bool {
+	switch strings.ToLower(c.Query("dryRun")) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// parseSendAt 读取定时发送参数 send_at、delay（查询参数或请求数据中的字段），
+// 读取后从请求数据中移除，不参与模板渲染
+func parseSendAt(c *gin.Context, rawData map[string]interface{}) (time.Time, error) {
+	param := func(key string) string {
+		value := c.Query(key)
+		if v, ok := rawData[key]; ok {
+			if value == "" {
+				value = strings.TrimSpace(fmt.Sprint(v))
+			}
+			delete(rawData, key)
+		}
+		return value
+	}
+	sendAt, delay := param("send_at"), param("delay")
+	t, err := schedule.ParseSendAt(sendAt, delay, time.Now())
+	if err != nil || !t.After(time.Now()) {
+		// 时间已过时立即发送
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// NotifyResult 单个通知服务、单个目标的发送结果
+type NotifyResult struct {
+	Notifier   string `json:"notifier"`
+	DeliveryID string `json:"deliveryId,omitempty"`
+	Route      string `json:"route,omitempty"`      // 匹配的路由规则名称
+	Stage      int    `json:"stage,omitempty"`      // 故障转移的组序号，0 为首选组
+	Escalation int    `json:"escalation,omitempty"` // 升级步骤序号，0 表示不是升级通知
+	Status     string `json:"status"`               // 投递状态：queued/sending/retrying/sent/failed
+	notifier.Result
+}
+
+// flattenResults 把各投递的发送结果展开为按目标排列的列表
+func flattenResults(deliveries []history.DeliveryStatus) []NotifyResult {
+	results := []NotifyResult{}
+	for _, d := range deliveries {
+		if len(d.Results) == 0 {
+			// 尚未发送或未进入发送队列（通知服务不存在等）
+			results = append(results, NotifyResult{
+				Notifier:   d.Notifier,
+				DeliveryID: d.ID,
+				Route:      d.Route,
+				Stage:      d.Stage,
+				Escalation: d.Escalation,
+				Status:     d.Status,
+				Result: notifier.Result{
+					Target:  strings.Join(d.Targets, ","),
+					Success: d.Status == history.DeliverySent,
+					Error:   d.Error,
+				},
+			})
+			continue
+		}
+		for _, r := range d.Results {
+			results = append(results, NotifyResult{
+				Notifier:   d.Notifier,
+				DeliveryID: d.ID,
+				Route:      d.Route,
+				Stage:      d.Stage,
+				Escalation: d.Escalation,
+				Status:     d.Status,
+				Result:     r,
+			})
+		}
+	}
+	return results
+}
+
+// resultHTTPStatus 根据各目标的结果决定响应状态码：
+// 全部成功 200，部分成功 207，全部失败 502，仍在发送中或定时发送 202，免打扰丢弃的不计入
+func resultHTTPStatus(results []NotifyResult) int {
+	succeeded, failed, dropped := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.Success:
+			succeeded++
+		case r.Status == history.DeliveryFailed || r.Status == history.DeliveryRetrying:
+			failed++
+		case r.Status == history.DeliveryDropped:
+			dropped++
+		}
+	}
+	switch {
+	case failed == 0 && succeeded+dropped == len(results):
+		return http.StatusOK
+	case succeeded > 0 && failed > 0:
+		return http.StatusMultiStatus
+	case failed > 0 && succeeded == 0:
+		return http.StatusBadGateway
+	default:
+		return http.StatusAccepted
+	}
+}
+
+// sendErrorHTTPStatus 根据发送失败的分类决定响应状态码：请求和配置错误为 4xx，其余为 500
+func sendErrorHTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrAppNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, app.ErrAppDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, app.ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, app.ErrAppConfig):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// sendNotification 发送通知并返回响应；异步模式下写入队列后立即返回 202 和消息ID
+//
+// 同步模式下响应中包含每个通知服务、每个目标的发送结果，HTTP 状态码反映整体结果。
+func (s *HTTPServer) sendNotification(c *gin.Context, appConfig config.NotificationApp, rawData map[string]interface{}, method string) {
+	async := isAsyncRequest(c)
+
+	sendAt, err := parseSendAt(c, rawData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, err.Error()))
+		return
+	}
+
+	// 预览模式：返回各通知服务将要发送的请求，不实际发送，需要管理员认证
+	if isDryRunRequest(c) {
+		if !s.authMiddleware.isAdmin(c.Request) {
+			c.JSON(http.StatusUnauthorized, NewErrorRes(AUTH_ERROR, "预览模式需要管理员认证"))
+			return
+		}
+		s.previewNotification(c, appConfig.AppID, rawData)
+		return
+	}
+
+	result, err := s.app.Send(c.Request.Context(), appConfig, &rawData, app.SendOptions{Async: async, SendAt: sendAt})
+	if err != nil {
 		logger.Error("发送通知失败", "error", err)
-		c.JSON(http.StatusOK, NewErrorRes(NOTIFICATION_SEND_FAILED, err.Error()))
+		c.JSON(sendErrorHTTPStatus(err), NewErrorRes(NOTIFICATION_SEND_FAILED, err.Error()))
 		return
 	}
-	// 返回成功响应
-	c.JSON(http.StatusOK, NewSuccessRes(map[string]interface{}{
+
+	data := map[string]interface{}{
 		"appName": appConfig.Name,
-		"method":  "GET",
-	}))
+		"method":  method,
+	}
+	if result.MessageID != "" {
+		data["messageId"] = result.MessageID
+	}
+	if result.Skipped {
+		data["skipped"] = true
+		c.JSON(http.StatusOK, NewSuccessRes(data))
+		return
+	}
+	if result.Filtered {
+		data["status"] = history.MessageFiltered
+		data["filter"] = result.Filter
+		c.JSON(http.StatusOK, NewSuccessRes(data))
+		return
+	}
+	if result.Throttled {
+		data["status"] = history.MessageThrottled
+		data["repeated"] = result.Repeated
+		c.JSON(http.StatusOK, NewSuccessRes(data))
+		return
+	}
+	if result.Batched {
+		data["status"] = history.MessageBatched
+		c.JSON(http.StatusAccepted, NewSuccessRes(data))
+		return
+	}
+	if result.Dropped {
+		data["status"] = history.MessageDropped
+		c.JSON(http.StatusOK, NewSuccessRes(data))
+		return
+	}
+
+	results := flattenResults(result.Deliveries)
+	data["status"] = result.Status
+	data["results"] = results
+
+	// 异步模式下只有全部通知服务都无法投递时才直接报错
+	if async && result.Status != history.MessageFailed {
+		c.Header("Location", "/api/v1/admin/messages/"+result.MessageID)
+		c.JSON(http.StatusAccepted, NewSuccessRes(data))
+		return
+	}
+
+	// 已被故障转移接替的投递和升级通知不影响状态码
+	effective := flattenResults(history.Effective(result.Deliveries))
+	status := resultHTTPStatus(effective)
+	switch status {
+	case http.StatusOK:
+		c.JSON(status, NewSuccessRes(data))
+	case http.StatusAccepted:
+		c.Header("Location", "/api/v1/admin/messages/"+result.MessageID)
+		c.JSON(status, NewSuccessRes(data))
+	default:
+		var msgs []string
+		for _, r := range effective {
+			if !r.Success && r.Error != "" {
+				msg := fmt.Sprintf("通知服务 %s 发送失败: %s", r.Notifier, r.Error)
+				if r.Target != "" {
+					msg = fmt.Sprintf("通知服务 %s 发送到 %s 失败: %s", r.Notifier, r.Target, r.Error)
+				}
+				msgs = append(msgs, msg)
+			}
+		}
+		code := NOTIFICATION_SEND_FAILED
+		if status == http.StatusMultiStatus {
+			code = NOTIFICATION_PARTIAL_FAILED
+		}
+		logger.Warn("发送通知失败", "messageId", result.MessageID, "status", result.Status)
+		c.JSON(status, NewBaseRes(code, strings.Join(msgs, "\n"), data))
+	}
 }
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jianxcao/notify/backend/pkg/outbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupOutboxManagementRoutes 设置出站队列管理路由
+func (s *HTTPServer) setupOutboxManagementRoutes(admin *gin.RouterGroup) {
+	deliveries := admin.Group("/outbox")
+	{
+		deliveries.GET("", s.handleGetDeliveries)            // 获取投递记录，支持 state 过滤（如 dead 查看死信）
+		deliveries.GET("/:id", s.handleGetDelivery)          // 获取单条投递记录
+		deliveries.POST("/:id/retry", s.handleRetryDelivery) // 重新投递死信
+	}
+}
+
+// handleGetDeliveries 获取投递记录
+func (s *HTTPServer) handleGetDeliveries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	state := outbox.State(c.Query("state"))
+
+	deliveries, err := s.app.GetOutbox().List(state, limit)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(SYSTEM_ERROR, fmt.Sprintf("读取投递记录失败: %v", err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(deliveries))
+}
+
+// handleGetDelivery 获取单条投递记录
+func (s *HTTPServer) handleGetDelivery(c *gin.Context) {
+	id := c.Param("id")
+
+	delivery, exists, err := s.app.GetOutbox().Get(id)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(SYSTEM_ERROR, fmt.Sprintf("读取投递记录失败: %v", err)))
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusOK, NewErrorRes(DELIVERY_NOT_FOUND, fmt.Sprintf("投递记录 %s 不存在", id)))
+		return
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(delivery))
+}
+
+// handleRetryDelivery 重新投递死信
+func (s *HTTPServer) handleRetryDelivery(c *gin.Context) {
+	id := c.Param("id")
+
+	delivery, err := s.app.GetOutbox().Retry(id)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(DELIVERY_RETRY_FAILED, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(delivery))
+}
@@ -53,7 +53,11 @@ const (
 	NOTIFIER_IN_USE         = 4005 // 通知服务正在使用中
 
 	// 通知发送相关错误码 (5000-5999)
-	NOTIFICATION_SEND_FAILED = 5001 // 通知发送失败
+	NOTIFICATION_SEND_FAILED    = 5001 // 通知发送失败
+	DELIVERY_NOT_FOUND          = 5002 // 投递记录不存在
+	DELIVERY_RETRY_FAILED       = 5003 // 投递重试失败
+	MESSAGE_NOT_FOUND           = 5004 // 消息不存在
+	NOTIFICATION_PARTIAL_FAILED = 5005 // 部分通知服务或目标发送失败
 
 	// 插件相关错误码 (6000-6999)
 	PLUGIN_NOT_FOUND     = 6001 // 插件不存在
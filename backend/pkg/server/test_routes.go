@@ -0,0 +1,188 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/config"
+	"github.com/jianxcao/notify/backend/pkg/notifier"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotifierTestRequest 通知服务测试请求，未提供的字段使用已保存的配置
+type NotifierTestRequest struct {
+	Type    config.NotifiersType          `json:"type"`
+	Config  map[string]interface{}        `json:"config"`  // 待测试的配置，值为 *** 的密钥字段使用已保存的值
+	Send    bool                          `json:"send"`    // 是否发送测试消息
+	Targets []string                      `json:"targets"` // 测试消息的目标，为空时发送到默认目标
+	Message *notifier.NotificationMessage `json:"message"` // 测试消息，为空时使用默认消息
+}
+
+// NotifierTestResponse 通知服务测试结果
+type NotifierTestResponse struct {
+	Notifier    string           `json:"notifier"`
+	Type        string           `json:"type"`
+	Credentials *CredentialCheck `json:"credentials,omitempty"` // 不支持凭证检查的通知服务为空
+	Results     notifier.Results `json:"results,omitempty"`     // 测试消息的发送结果
+}
+
+// CredentialCheck 凭证检查结果
+type CredentialCheck struct {
+	OK    bool   `json:"ok"`
+	Info  string `json:"info,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// AppTestRequest 应用测试请求
+type AppTestRequest struct {
+	Data map[string]any `json:"data"` // 示例请求数据
+	Send bool           `json:"send"` // 是否发送到匹配的通知服务
+}
+
+// handleTestNotifier 测试通知服务：校验配置、检查凭证，可选发送测试消息；配置可以尚未保存
+func (s *HTTPServer) handleTestNotifier(c *gin.Context) {
+	instanceName := c.Param("notifier")
+
+	var testReq NotifierTestRequest
+	if err := c.ShouldBindJSON(&testReq); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, "解析请求失败"))
+		return
+	}
+
+	instance, err := s.testNotifierInstance(instanceName, testReq)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(NOTIFIER_NOT_FOUND, err.Error()))
+		return
+	}
+	if _, exists := notifier.Lookup(instance.Type); !exists {
+		c.JSON(http.StatusOK, NewErrorRes(NOTIFIER_CONFIG_ERROR, fmt.Sprintf("未知的通知服务类型: %s", instance.Type)))
+		return
+	}
+
+	n, err := notifier.New(instance)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(NOTIFIER_CONFIG_ERROR, fmt.Sprintf("配置校验失败: %v", err)))
+		return
+	}
+
+	res := NotifierTestResponse{Notifier: instanceName, Type: string(instance.Type)}
+	if checker, ok := n.(notifier.CredentialChecker); ok {
+		info, err := checker.CheckCredentials(c.Request.Context())
+		res.Credentials = &CredentialCheck{OK: err == nil, Info: info}
+		if err != nil {
+			res.Credentials.Error = err.Error()
+			c.JSON(http.StatusOK, NewBaseRes(NOTIFIER_TEST_FAILED, fmt.Sprintf("凭证检查失败: %v", err), res))
+			return
+		}
+	}
+
+	if testReq.Send {
+		message := testReq.Message
+		if message == nil {
+			message = &notifier.NotificationMessage{
+				Title:   "测试消息",
+				Content: fmt.Sprintf("这是来自通知服务 %s 的测试消息，收到说明配置正确。", instanceName),
+			}
+		}
+		if message.Timestamp == "" {
+			message.Timestamp = time.Now().Format("2006-01-02 15:04:05")
+		}
+		message = s.app.Media().Rewrite(c.Request.Context(), message, notifier.ImageLimitsOf(n))
+		res.Results = n.Send(c.Request.Context(), message, testReq.Targets)
+		if err := res.Results.Err(); err != nil {
+			c.JSON(http.StatusOK, NewBaseRes(NOTIFIER_TEST_FAILED, fmt.Sprintf("测试消息发送失败: %v", err), res))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, NewSuccessRes(res))
+}
+
+// testNotifierInstance 合并请求中的配置和已保存的配置，生成待测试的通知服务实例
+func (s *HTTPServer) testNotifierInstance(instanceName string, testReq NotifierTestRequest) (config.NotifierInstance, error) {
+	saved, exists := s.config.Notifiers[instanceName]
+	if !exists && (testReq.Type == "" || testReq.Config == nil) {
+		return config.NotifierInstance{}, fmt.Errorf("通知服务实例 %s 不存在", instanceName)
+	}
+
+	instance := saved
+	if testReq.Type != "" {
+		instance.Type = testReq.Type
+	}
+	if testReq.Config != nil {
+		instance.Config = make(map[string]interface{}, len(testReq.Config))
+		for key, value := range testReq.Config {
+			// 获取配置接口隐藏了密钥，未修改时沿用已保存的值
+			if value == "***" && exists && saved.Type == instance.Type && notifier.IsSecretField(instance.Type, key) {
+				value = saved.Config[key]
+			}
+			instance.Config[key] = value
+		}
+	}
+	// 测试未启用的通知服务时也需要实际请求
+	instance.Enabled = true
+	return instance, nil
+}
+
+// handleAppPreview 预览应用：使用示例数据执行完整的处理流程，返回各通知服务将要发送的请求，不实际发送
+func (s *HTTPServer) handleAppPreview(c *gin.Context) {
+	appID := c.Param("appid")
+
+	var testReq AppTestRequest
+	if err := c.ShouldBindJSON(&testReq); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, "解析请求失败"))
+		return
+	}
+
+	if _, _, exists := s.findAppByID(appID); !exists {
+		c.JSON(http.StatusOK, NewErrorRes(APP_NOT_FOUND, fmt.Sprintf("应用 %s 不存在", appID)))
+		return
+	}
+	s.previewNotification(c, appID, testReq.Data)
+}
+
+// previewNotification 在预览模式下处理请求并返回结果
+func (s *HTTPServer) previewNotification(c *gin.Context, appID string, data map[string]any) {
+	result, err := s.app.Preview(c.Request.Context(), appID, data)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(APP_CONFIG_ERROR, fmt.Sprintf("处理消息失败: %v", err)))
+		return
+	}
+	c.JSON(http.StatusOK, NewSuccessRes(result))
+}
+
+// handleTestApp 使用示例数据测试应用的插件或模板渲染和路由规则，可选直接发送到匹配的通知服务
+func (s *HTTPServer) handleTestApp(c *gin.Context) {
+	appID := c.Param("appid")
+
+	var testReq AppTestRequest
+	if err := c.ShouldBindJSON(&testReq); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, NewErrorRes(PARAM_ERROR, "解析请求失败"))
+		return
+	}
+
+	if _, _, exists := s.findAppByID(appID); !exists {
+		c.JSON(http.StatusOK, NewErrorRes(APP_NOT_FOUND, fmt.Sprintf("应用 %s 不存在", appID)))
+		return
+	}
+
+	result, err := s.app.Test(c.Request.Context(), appID, testReq.Data, testReq.Send)
+	if err != nil {
+		c.JSON(http.StatusOK, NewErrorRes(APP_CONFIG_ERROR, fmt.Sprintf("处理消息失败: %v", err)))
+		return
+	}
+
+	for _, d := range result.Dispatches {
+		for _, r := range d.Results {
+			if r.Error != "" || r.Results.Err() != nil {
+				c.JSON(http.StatusOK, NewBaseRes(NOTIFICATION_PARTIAL_FAILED, "部分通知服务测试发送失败", result))
+				return
+			}
+		}
+	}
+	c.JSON(http.StatusOK, NewSuccessRes(result))
+}
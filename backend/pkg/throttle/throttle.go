@@ -0,0 +1,164 @@
+// Package throttle 消息去重与限流：同一去重键在时间窗口内最多发送指定条数，
+// 其余的被抑制并计数，计数在下一条发送的消息或窗口结束时的汇总消息中报告
+package throttle
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/notify/backend/pkg/logger"
+	"github.com/jianxcao/notify/backend/pkg/store"
+)
+
+const bucketThrottle = "throttle"
+
+// idleTTL 窗口结束后未汇总的计数最多保留的时间，超过后丢弃
+const idleTTL = 24 * time.Hour
+
+// Rule 限流规则
+type Rule struct {
+	Window  time.Duration // 时间窗口
+	Max     int           // 窗口内最多发送的条数，小于 1 时按 1 处理
+	Summary bool          // 窗口结束时把被抑制的消息汇总发送一条
+	Persist bool          // 状态写入数据库，重启后保留
+}
+
+// State 单个去重键的限流状态
+type State struct {
+	AppID       string         `json:"appId"`
+	Key         string         `json:"key"`
+	WindowStart time.Time      `json:"windowStart"`
+	Window      time.Duration  `json:"window"`
+	Sent        int            `json:"sent"`       // 当前窗口已发送的条数
+	Suppressed  int            `json:"suppressed"` // 尚未报告的被抑制条数
+	Summary     bool           `json:"summary"`
+	Persist     bool           `json:"persist"`
+	Payload     map[string]any `json:"payload,omitempty"` // 最近一条被抑制消息的请求数据，用于发送汇总
+}
+
+// windowEnd 当前窗口的结束时间
+func (s *State) windowEnd() time.Time {
+	return s.WindowStart.Add(s.Window)
+}
+
+// Decision 限流判断结果
+type Decision struct {
+	Allowed bool
+	// Repeated 允许发送时为此前被抑制、需要在本条消息中报告的条数；被抑制时为当前累计的条数
+	Repeated int
+}
+
+// Throttler 限流器，状态保存在内存中，规则要求时同时写入数据库
+type Throttler struct {
+	store *store.Store
+
+	mu     sync.Mutex
+	states map[string]*State
+}
+
+// New 创建限流器并加载持久化的状态
+func New(st *store.Store) *Throttler {
+	t := &Throttler{store: st, states: make(map[string]*State)}
+	err := st.ForEach(bucketThrottle, func(key string, data []byte) error {
+		var s State
+		if err := json.Unmarshal(data, &s); err != nil {
+			logger.Warn("解析限流状态失败", "key", key, "error", err)
+			return nil
+		}
+		t.states[key] = &s
+		return nil
+	})
+	if err != nil {
+		logger.Error("加载限流状态失败", "error", err)
+	}
+	return t
+}
+
+func stateKey(appID, key string) string {
+	return appID + "/" + key
+}
+
+// Allow 判断消息是否允许发送，被抑制时记录请求数据以便发送汇总
+func (t *Throttler) Allow(appID, key string, rule Rule, payload map[string]any) Decision {
+	now := time.Now()
+	max := rule.Max
+	if max < 1 {
+		max = 1
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := stateKey(appID, key)
+	s, ok := t.states[id]
+	if !ok {
+		s = &State{AppID: appID, Key: key}
+		t.states[id] = s
+	}
+	s.Window, s.Summary, s.Persist = rule.Window, rule.Summary, rule.Persist
+	if !now.Before(s.windowEnd()) {
+		// 新窗口，未报告的抑制计数保留到本条消息
+		s.WindowStart = now
+		s.Sent = 0
+	}
+
+	var decision Decision
+	if s.Sent < max {
+		s.Sent++
+		decision = Decision{Allowed: true, Repeated: s.Suppressed}
+		s.Suppressed = 0
+		s.Payload = nil
+	} else {
+		s.Suppressed++
+		s.Payload = payload
+		decision = Decision{Repeated: s.Suppressed}
+	}
+	t.save(id, s)
+	return decision
+}
+
+// Due 取出窗口已结束、需要发送汇总的状态，并清理过期的状态
+func (t *Throttler) Due() []State {
+	now := time.Now()
+	var due []State
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, s := range t.states {
+		end := s.windowEnd()
+		if now.Before(end) {
+			continue
+		}
+		switch {
+		case s.Suppressed > 0 && s.Summary:
+			due = append(due, *s)
+		case s.Suppressed > 0 && now.Before(end.Add(idleTTL)):
+			// 等待下一条消息报告计数
+			continue
+		}
+		delete(t.states, id)
+		t.remove(id, s)
+	}
+	return due
+}
+
+// save 持久化状态，调用方需持有锁
+func (t *Throttler) save(id string, s *State) {
+	if !s.Persist {
+		return
+	}
+	if err := t.store.Put(bucketThrottle, id, s); err != nil {
+		logger.Warn("保存限流状态失败", "key", id, "error", err)
+	}
+}
+
+// remove 删除持久化的状态，调用方需持有锁
+func (t *Throttler) remove(id string, s *State) {
+	if !s.Persist {
+		return
+	}
+	if err := t.store.Delete(bucketThrottle, id); err != nil {
+		logger.Warn("删除限流状态失败", "key", id, "error", err)
+	}
+}
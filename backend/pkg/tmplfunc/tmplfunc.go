@@ -0,0 +1,106 @@
+// Package tmplfunc 模板中可以使用的函数
+package tmplfunc
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// FuncMap 模板函数，消息模板和 webhook 等通知服务的模板共用
+var FuncMap = template.FuncMap{
+	"strContains":   strings.Contains,
+	"hasSuffix":     strings.HasSuffix,
+	"hasPrefix":     strings.HasPrefix,
+	"strHasSuffix":  strings.HasSuffix,
+	"strHasPrefix":  strings.HasPrefix,
+	"strIndex":      strings.Index,
+	"strLastIndex":  strings.LastIndex,
+	"strReplace":    strings.Replace,
+	"strReplaceAll": strings.ReplaceAll,
+	"strSplit":      strings.Split,
+	"strJoin":       strings.Join,
+	// 时间格式化函数
+	"formatTime": func(timeStr string, layout string) string {
+		if timeStr == "" {
+			return ""
+		}
+		// 解析RFC3339格式的时间字符串
+		t, err := time.Parse(time.RFC3339, timeStr)
+		if err != nil {
+			// 如果解析失败，尝试解析带纳秒的格式
+			t, err = time.Parse(time.RFC3339Nano, timeStr)
+			if err != nil {
+				return timeStr // 如果都解析失败，返回原字符串
+			}
+		}
+		// 转换为本地时间
+		localTime := t.Local()
+		return localTime.Format(layout)
+	},
+	"formatTimeUTC": func(timeStr string, layout string) string {
+		if timeStr == "" {
+			return ""
+		}
+		t, err := time.Parse(time.RFC3339, timeStr)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339Nano, timeStr)
+			if err != nil {
+				return timeStr
+			}
+		}
+		return t.Format(layout)
+	},
+	// 数学运算函数
+	"mul": func(a, b interface{}) float64 {
+		var x, y float64
+		switch v := a.(type) {
+		case int:
+			x = float64(v)
+		case int64:
+			x = float64(v)
+		case float64:
+			x = v
+		case float32:
+			x = float64(v)
+		}
+		switch v := b.(type) {
+		case int:
+			y = float64(v)
+		case int64:
+			y = float64(v)
+		case float64:
+			y = v
+		case float32:
+			y = float64(v)
+		}
+		return x * y
+	},
+	"div": func(a, b interface{}) float64 {
+		var x, y float64
+		switch v := a.(type) {
+		case int:
+			x = float64(v)
+		case int64:
+			x = float64(v)
+		case float64:
+			x = v
+		case float32:
+			x = float64(v)
+		}
+		switch v := b.(type) {
+		case int:
+			y = float64(v)
+		case int64:
+			y = float64(v)
+		case float64:
+			y = v
+		case float32:
+			y = float64(v)
+		}
+		if y == 0 {
+			return 0
+		}
+		return x / y
+	},
+}
@@ -10,9 +10,7 @@ declare module 'vue' {
   export interface GlobalComponents {
     AppEditDialog: typeof import('./src/components/dialog/AppEditDialog.vue')['default']
     CodeBlock: typeof import('./src/components/CodeBlock.vue')['default']
-    DingtalkConfig: typeof import('./src/components/dialog/notifier-configs/DingtalkConfig.vue')['default']
     DynamicTemplateForm: typeof import('./src/components/DynamicTemplateForm.vue')['default']
-    FeishuConfig: typeof import('./src/components/dialog/notifier-configs/FeishuConfig.vue')['default']
     FormRender: typeof import('./src/components/FormRender.vue')['default']
     Layout: typeof import('./src/components/Layout.vue')['default']
     LoadingView: typeof import('./src/components/LoadingView.vue')['default']
@@ -21,11 +19,9 @@ declare module 'vue' {
     PluginSettingsDialog: typeof import('./src/components/dialog/PluginSettingsDialog.vue')['default']
     RouterLink: typeof import('vue-router')['RouterLink']
     RouterView: typeof import('vue-router')['RouterView']
-    TelegramConfig: typeof import('./src/components/dialog/notifier-configs/TelegramConfig.vue')['default']
+    SchemaConfig: typeof import('./src/components/dialog/notifier-configs/SchemaConfig.vue')['default']
     TemplateEditDialog: typeof import('./src/components/dialog/TemplateEditDialog.vue')['default']
     TemplateImportDialog: typeof import('./src/components/dialog/TemplateImportDialog.vue')['default']
     TestNotificationDialog: typeof import('./src/components/dialog/TestNotificationDialog.vue')['default']
-    WechatWorkConfig: typeof import('./src/components/dialog/notifier-configs/WechatWorkConfig.vue')['default']
-    WechatWorkWebhookConfig: typeof import('./src/components/dialog/notifier-configs/WechatWorkWebhookConfig.vue')['default']
   }
 }
@@ -15,6 +15,79 @@ export interface AppAuth {
   token: string
 }
 
+// 通知应用的路由规则
+export interface AppRoute {
+  name?: string
+  match?: string
+  notifiers: string[]
+  targets?: string
+  templateId?: string
+  continue?: boolean
+}
+
+// 通知应用的过滤规则
+export interface AppFilter {
+  allow?: string[]
+  deny?: string[]
+}
+
+// 通知应用的去重与限流配置
+export interface AppThrottle {
+  key?: string
+  window: string
+  max?: number
+  summary?: boolean
+  persist?: boolean
+}
+
+// 通知应用的汇总配置
+export interface AppDigest {
+  key?: string
+  maxWait: string
+  maxItems?: number
+  templateId?: string
+}
+
+// 免打扰时段
+export interface QuietWindow {
+  start: string
+  end: string
+  days?: string[]
+}
+
+// 免打扰配置
+export interface QuietHours {
+  timezone?: string
+  windows: QuietWindow[]
+  action?: 'defer' | 'drop'
+  bypass?: string
+}
+
+// 通知服务的发送限速
+export interface RateLimit {
+  rate?: string
+  burst?: number
+  perTarget?: string
+  concurrency?: number
+}
+
+// 通知服务的健康状态（熔断器）
+export interface NotifierHealth {
+  state: 'closed' | 'open' | 'half-open'
+  consecutiveFailures: number
+  lastSuccessAt?: string
+  lastErrorAt?: string
+  lastError?: string
+  openedAt?: string
+  retryAt?: string
+}
+
+// 未确认时的升级步骤
+export interface EscalationStep {
+  after: string
+  notifiers: string[]
+}
+
 // 通知应用接口
 export interface NotificationApp {
   appId: string
@@ -27,6 +100,13 @@ export interface NotificationApp {
   defaultImage?: string
   auth: AppAuth
   fieldMapping?: FieldMapping
+  routes?: AppRoute[]
+  filter?: AppFilter
+  throttle?: AppThrottle
+  digest?: AppDigest
+  quietHours?: QuietHours
+  fallback?: string[][]
+  escalation?: EscalationStep[]
 }
 
 // 通知服务实例接口
@@ -77,6 +157,12 @@ export type NotifierType =
   | 'telegramAppBot'
   | 'dingTalkAppBot'
   | 'feishuAppBot'
+  | 'email'
+  | 'webhook'
+  | 'slack'
+  | 'mattermost'
+  | 'discordWebhook'
+  | 'bark'
 
 export const NotifierTypeMap = {
   wechatWorkAPPBot: 'wechatWorkAPPBot',
@@ -84,67 +170,37 @@ export const NotifierTypeMap = {
   telegramAppBot: 'telegramAppBot',
   dingTalkAppBot: 'dingTalkAppBot',
   feishuAppBot: 'feishuAppBot',
+  email: 'email',
+  webhook: 'webhook',
+  slack: 'slack',
+  mattermost: 'mattermost',
+  discordWebhook: 'discordWebhook',
+  bark: 'bark',
 } as const
 
-// 通知服务类型选项
-export const notifierTypeOptions = [
-  { title: '企业微信应用', value: NotifierTypeMap.wechatWorkAPPBot },
-  { title: '企业微信群机器人', value: NotifierTypeMap.wechatWorkWebhookBot },
-  { title: 'Telegram', value: NotifierTypeMap.telegramAppBot },
-  { title: '钉钉', value: NotifierTypeMap.dingTalkAppBot },
-  { title: '飞书', value: NotifierTypeMap.feishuAppBot },
-]
-
-// 通知级别
-export type NotificationLevel = 'info' | 'warning' | 'error' | 'success'
-
-// 企业微信应用配置
-export interface WechatWorkConfig {
-  enabled: boolean
-  corp_id: string
-  agent_id: string
-  secret: string
-  targets?: string
-  proxy?: string
-}
-
-// 企业微信群机器人配置
-export interface WechatWorkWebhookConfig {
-  enabled: boolean
-  key: string
-  proxy?: string
-}
-
-// Telegram配置
-export interface TelegramConfig {
-  enabled: boolean
-  bot_token: string
-  chat_id: string
-  proxy?: string
-}
-
-// 钉钉配置
-export interface DingTalkConfig {
-  enabled: boolean
-  access_token: string
-  secret?: string
-  proxy?: string
-  targets?: string
+// 通知服务配置字段描述（/admin/notifier-types）
+export interface NotifierFieldSchema {
+  name: string
+  label: string
+  type: 'string' | 'password' | 'number' | 'bool' | 'select' | 'textarea'
+  required: boolean
+  secret: boolean
+  hint?: string
+  placeholder?: string
+  options?: string[]
+  default?: any
 }
 
-// 飞书配置
-export interface FeishuConfig {
-  enabled: boolean
-  app_id: string
-  app_secret: string
-  targets?: string
-  proxy?: string
+// 已注册的通知服务类型
+export interface NotifierTypeInfo {
+  type: string
+  displayName: string
+  description?: string
+  fields: NotifierFieldSchema[]
+  rateLimit?: RateLimit
+  requiredFields: string[]
+  secretFields: string[]
 }
 
-// 通知服务配置联合类型
-export type NotifierConfig =
-  | WechatWorkConfig
-  | WechatWorkWebhookConfig
-  | TelegramConfig
-  | DingTalkConfig
-  | FeishuConfig
+// 通知级别
+export type NotificationLevel = 'info' | 'warning' | 'error' | 'success'
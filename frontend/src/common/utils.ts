@@ -135,6 +135,12 @@ export function getNotifierTypeName(type: string): string {
     [NotifierTypeMap.wechatWorkAPPBot]: '企业微信',
     [NotifierTypeMap.telegramAppBot]: 'Telegram',
     [NotifierTypeMap.dingTalkAppBot]: '钉钉',
+    [NotifierTypeMap.email]: '邮件',
+    [NotifierTypeMap.webhook]: 'Webhook',
+    [NotifierTypeMap.slack]: 'Slack',
+    [NotifierTypeMap.mattermost]: 'Mattermost',
+    [NotifierTypeMap.discordWebhook]: 'Discord',
+    [NotifierTypeMap.bark]: 'Bark',
   }
   return names[type] || type
 }
@@ -147,6 +153,12 @@ export function getNotifierIcon(type: string): string {
     [NotifierTypeMap.wechatWorkAPPBot]: 'mdi-wechat',
     [NotifierTypeMap.telegramAppBot]: 'mdi-telegram',
     [NotifierTypeMap.dingTalkAppBot]: 'mdi-message-processing',
+    [NotifierTypeMap.email]: 'mdi-email',
+    [NotifierTypeMap.webhook]: 'mdi-webhook',
+    [NotifierTypeMap.slack]: 'mdi-slack',
+    [NotifierTypeMap.mattermost]: 'mdi-chat',
+    [NotifierTypeMap.discordWebhook]: 'mdi-discord',
+    [NotifierTypeMap.bark]: 'mdi-cellphone-message',
   }
   return icons[type] || 'mdi-bell'
 }
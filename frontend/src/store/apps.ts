@@ -121,11 +121,14 @@ export const useAppsStore = defineStore('apps', () => {
   const sendTestNotification = async (appId: string, testData: any) => {
     loading.value = true
     try {
-      const response = await http.post(`/notify/${appId}`, testData)
+      // 全部发送失败时接口返回 502，响应体中仍包含各目标的发送结果
+      const response = await http.post(`/notify/${appId}`, testData, {
+        validateStatus: (status) => (status >= 200 && status < 300) || status === 502,
+      })
       if (response.code === 0) {
         toast.success('测试通知发送成功')
       } else {
-        if (response.code === 5001) {
+        if (response.code === 5001 || response.code === 5005) {
           const msg = (response.msg || '发送测试通知失败').split('\n')
           msg.forEach((m) => toast.error(m))
         } else {
@@ -142,6 +145,36 @@ export const useAppsStore = defineStore('apps', () => {
     }
   }
 
+  // 使用示例数据测试应用的渲染和路由，send 为 true 时直接发送到匹配的通知服务（不写入消息历史）
+  const testApp = async (appId: string, data: Record<string, any>, send = false) => {
+    try {
+      const response = await http.post(`/admin/apps/${appId}/test`, { data, send })
+      if (response.code !== 0) {
+        toast.error(response.msg || '测试应用失败')
+      }
+      return response
+    } catch (error: any) {
+      console.error('测试应用失败:', error)
+      toast.error('测试应用失败')
+      throw error
+    }
+  }
+
+  // 预览各通知服务将要发送的请求，不实际发送
+  const previewApp = async (appId: string, data: Record<string, any>) => {
+    try {
+      const response = await http.post(`/admin/apps/${appId}/preview`, { data })
+      if (response.code !== 0) {
+        toast.error(response.msg || '预览失败')
+      }
+      return response
+    } catch (error: any) {
+      console.error('预览失败:', error)
+      toast.error('预览失败')
+      throw error
+    }
+  }
+
   // 获取应用列表（仅name和enabled状态）
   const getAppsList = () => {
     return Object.entries(apps.value).map(([appId, app]) => ({
@@ -164,6 +197,8 @@ export const useAppsStore = defineStore('apps', () => {
     updateApp,
     deleteApp,
     sendTestNotification,
+    testApp,
+    previewApp,
     getAppsList,
   }
 })
@@ -2,7 +2,7 @@ import { defineStore } from 'pinia'
 import http from '@/common/axiosConfig'
 import { ref } from 'vue'
 import { useToast } from 'vue-toast-notification'
-import { NotifierTypeMap } from '@/common/types'
+import type { NotifierHealth, NotifierTypeInfo, QuietHours, RateLimit } from '@/common/types'
 
 const toast = useToast()
 
@@ -10,11 +10,15 @@ export interface INotifierInstance {
   type: string
   enabled: boolean
   config: Record<string, any>
+  quietHours?: QuietHours
+  rateLimit?: RateLimit
+  health?: NotifierHealth
 }
 
 export const useNotifiersStore = defineStore('notifiers', () => {
   // 状态
   const notifiers = ref<Record<string, INotifierInstance>>({})
+  const notifierTypes = ref<NotifierTypeInfo[]>([])
   const loading = ref(false)
 
   // 获取所有通知服务
@@ -93,30 +97,45 @@ export const useNotifiersStore = defineStore('notifiers', () => {
     }
   }
 
-  // 获取通知服务类型列表
-  const getNotifierTypes = () => {
-    return [
-      {
-        type: NotifierTypeMap.wechatWorkAPPBot,
-        name: '企业微信',
-        fields: ['agent_id', 'corp_id', 'secret'],
-      },
-      {
-        type: NotifierTypeMap.telegramAppBot,
-        name: 'Telegram',
-        fields: ['bot_token', 'chat_id'],
-      },
-      {
-        type: NotifierTypeMap.dingTalkAppBot,
-        name: '钉钉',
-        fields: ['webhook_url', 'secret'],
-      },
-    ]
+  // 测试通知服务：校验配置并检查凭证，send 为 true 时发送测试消息；config 可以是尚未保存的配置
+  const testNotifier = async (
+    notifierName: string,
+    config?: Pick<INotifierInstance, 'type' | 'config'>,
+    options: { send?: boolean; targets?: string[] } = {},
+  ) => {
+    try {
+      const response = await http.post(`/admin/notifiers/${notifierName}/test`, { ...config, ...options })
+      if (response.code === 0) {
+        toast.success(`通知服务 ${notifierName} 测试通过`)
+      } else {
+        toast.error(response.msg || '测试通知服务失败')
+      }
+      return response
+    } catch (error: any) {
+      console.error('测试通知服务失败:', error)
+      toast.error('测试通知服务失败')
+      throw error
+    }
+  }
+
+  // 获取已注册的通知服务类型和配置字段描述，类型在运行期间不变，只请求一次
+  const fetchNotifierTypes = async () => {
+    if (notifierTypes.value.length > 0) return notifierTypes.value
+    try {
+      const response = await http.get('/admin/notifier-types')
+      notifierTypes.value = response.data || []
+    } catch (error: any) {
+      console.error('获取通知服务类型失败:', error)
+      toast.error('获取通知服务类型失败')
+      throw error
+    }
+    return notifierTypes.value
   }
 
   return {
     // 状态
     notifiers,
+    notifierTypes,
     loading,
 
     // 方法
@@ -124,6 +143,7 @@ export const useNotifiersStore = defineStore('notifiers', () => {
     getNotifier,
     updateNotifier,
     deleteNotifier,
-    getNotifierTypes,
+    testNotifier,
+    fetchNotifierTypes,
   }
 })
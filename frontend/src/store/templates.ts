@@ -14,6 +14,23 @@ export interface IMessageTemplate {
   image: string
   url: string
   targets: string
+  format?: string
+  level?: string
+  images?: string
+  actions?: ITemplateAction[] | null
+  fields?: ITemplateField[] | null
+}
+
+// 模板中的按钮，名称和链接均支持模板语法
+export interface ITemplateAction {
+  label: string
+  url: string
+}
+
+// 模板中的键值字段，键和值均支持模板语法
+export interface ITemplateField {
+  key: string
+  value: string
 }
 
 export const useTemplatesStore = defineStore('templates', () => {